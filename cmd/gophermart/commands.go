@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/gostuding/goMarket/internal/config"
+	"github.com/gostuding/goMarket/internal/storage"
+)
+
+const (
+	commandsUsage = `usage: gophermart <command> [flags] [arguments]
+commands:
+  serve                                      запуск сервера (по умолчанию)
+  migrate up|down|status [flags] [N]         миграции структуры БД
+  user create [flags] <login> [password]     добавление пользователя
+  user block|unblock [flags] <login>         блокировка пользователя
+  user reset-password [flags] <login> [password]
+                                             смена пароля пользователя
+  balance recompute [flags]                  пересчёт балансов по заказам, переводам и списаниям
+  orders recheck [flags] <number>            повторный запрос начислений по заказу
+  campaign create [flags] [file]             добавление маркетинговой кампании из json (файл или stdin)
+  campaign list [flags]                      список кампаний с промокодами
+  export [flags] [file]                      выгрузка пользователей, заказов и списаний в json
+  config print [flags]                       вывод итоговой конфигурации (секреты скрыты)`
+)
+
+type adminStorage interface {
+	CreateUser(context.Context, string, string) (int, error)
+	BlockUser(context.Context, string, bool) error
+	ResetPassword(context.Context, string, string) error
+	RecomputeBalances(context.Context) (int64, error)
+	RecheckOrder(context.Context, string) error
+	Export(context.Context, io.Writer) error
+	CreateCampaign(context.Context, *storage.Campaigns) error
+	GetCampaigns(context.Context) ([]storage.Campaigns, error)
+	Close() error
+}
+
+// commandStorage opens storage for administrative command.
+func commandStorage(name string, args []string, minArgs int) (*config.Config, adminStorage, error) {
+	cfg, err := config.NewConfig(name, args)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(cfg.Args) < minArgs {
+		return nil, nil, fmt.Errorf("not enough arguments for '%s'. %s", name, commandsUsage)
+	}
+	strg, err := storage.NewPSQLStorage(cfg.StorageCfg, cfg.LoyaltyCfg)
+	if err != nil {
+		return nil, nil, fmt.Errorf("create storage error: %w", err)
+	}
+	return cfg, strg, nil
+}
+
+func closeStorage(strg adminStorage) {
+	if err := strg.Close(); err != nil {
+		fmt.Fprintf(os.Stderr, "close storage error: %v\n", err)
+	}
+}
+
+// readPassword returns password from arguments or from the first line of stdin.
+func readPassword(args []string, index int) (string, error) {
+	if len(args) > index {
+		return args[index], nil
+	}
+	fmt.Fprint(os.Stderr, "password: ")
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", fmt.Errorf("read password error: %w", err)
+	}
+	pwd := strings.TrimRight(line, "\r\n")
+	if pwd == "" {
+		return "", errors.New("password is empty")
+	}
+	return pwd, nil
+}
+
+func runUser(args []string) error {
+	if len(args) == 0 {
+		return errors.New(commandsUsage)
+	}
+	action := args[0]
+	cfg, strg, err := commandStorage("user "+action, args[1:], 1)
+	if err != nil {
+		return err
+	}
+	defer closeStorage(strg)
+	ctx := context.Background()
+	login := cfg.Args[0]
+	switch action {
+	case "create":
+		pwd, err := readPassword(cfg.Args, 1)
+		if err != nil {
+			return err
+		}
+		uid, err := strg.CreateUser(ctx, login, pwd)
+		if err != nil {
+			return fmt.Errorf("create user error: %w", err)
+		}
+		fmt.Printf("user '%s' created, id: %d\n", login, uid)
+	case "block", "unblock":
+		if err = strg.BlockUser(ctx, login, action == "block"); err != nil {
+			return fmt.Errorf("%s user error: %w", action, err)
+		}
+		fmt.Printf("user '%s' %sed\n", login, action)
+	case "reset-password":
+		pwd, err := readPassword(cfg.Args, 1)
+		if err != nil {
+			return err
+		}
+		if err = strg.ResetPassword(ctx, login, pwd); err != nil {
+			return fmt.Errorf("reset password error: %w", err)
+		}
+		fmt.Printf("user '%s' password changed\n", login)
+	default:
+		return fmt.Errorf("unknown user action '%s'. %s", action, commandsUsage)
+	}
+	return nil
+}
+
+func runBalance(args []string) error {
+	if len(args) == 0 || args[0] != "recompute" {
+		return errors.New(commandsUsage)
+	}
+	_, strg, err := commandStorage("balance recompute", args[1:], 0)
+	if err != nil {
+		return err
+	}
+	defer closeStorage(strg)
+	count, err := strg.RecomputeBalances(context.Background())
+	if err != nil {
+		return err //nolint:wrapcheck // <- wrapped in storage
+	}
+	fmt.Printf("balances recomputed for %d users\n", count)
+	return nil
+}
+
+func runOrders(args []string) error {
+	if len(args) == 0 || args[0] != "recheck" {
+		return errors.New(commandsUsage)
+	}
+	cfg, strg, err := commandStorage("orders recheck", args[1:], 1)
+	if err != nil {
+		return err
+	}
+	defer closeStorage(strg)
+	if err = strg.RecheckOrder(context.Background(), cfg.Args[0]); err != nil {
+		return err //nolint:wrapcheck // <- wrapped in storage
+	}
+	fmt.Printf("order '%s' returned to accrual queue\n", cfg.Args[0])
+	return nil
+}
+
+func runCampaign(args []string) error {
+	if len(args) == 0 {
+		return errors.New(commandsUsage)
+	}
+	action := args[0]
+	cfg, strg, err := commandStorage("campaign "+action, args[1:], 0)
+	if err != nil {
+		return err
+	}
+	defer closeStorage(strg)
+	ctx := context.Background()
+	switch action {
+	case "create":
+		in := io.Reader(os.Stdin)
+		if len(cfg.Args) > 0 {
+			file, err := os.Open(cfg.Args[0])
+			if err != nil {
+				return fmt.Errorf("open campaign file error: %w", err)
+			}
+			defer file.Close() //nolint:errcheck // <- senselessly
+			in = file
+		}
+		var campaign storage.Campaigns
+		if err = json.NewDecoder(in).Decode(&campaign); err != nil {
+			return fmt.Errorf("decode campaign error: %w", err)
+		}
+		if err = strg.CreateCampaign(ctx, &campaign); err != nil {
+			return err //nolint:wrapcheck // <- wrapped in storage
+		}
+		fmt.Printf("campaign '%s' created, id: %d\n", campaign.Name, campaign.ID)
+	case "list":
+		campaigns, err := strg.GetCampaigns(ctx)
+		if err != nil {
+			return err //nolint:wrapcheck // <- wrapped in storage
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err = encoder.Encode(campaigns); err != nil {
+			return fmt.Errorf("write campaigns error: %w", err)
+		}
+	default:
+		return fmt.Errorf("unknown campaign action '%s'. %s", action, commandsUsage)
+	}
+	return nil
+}
+
+func runExport(args []string) error {
+	cfg, strg, err := commandStorage("export", args, 0)
+	if err != nil {
+		return err
+	}
+	defer closeStorage(strg)
+	out := io.Writer(os.Stdout)
+	if len(cfg.Args) > 0 {
+		file, err := os.Create(cfg.Args[0])
+		if err != nil {
+			return fmt.Errorf("create export file error: %w", err)
+		}
+		defer file.Close() //nolint:errcheck // <- senselessly
+		out = file
+	}
+	return strg.Export(context.Background(), out) //nolint:wrapcheck // <- wrapped in storage
+}
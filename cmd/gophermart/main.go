@@ -1,59 +1,29 @@
 package main
 
 import (
-	"flag"
+	"context"
+	"fmt"
 	"log"
 	"os"
+	"strings"
 
+	"github.com/gostuding/goMarket/internal/config"
 	"github.com/gostuding/goMarket/internal/logger"
+	"github.com/gostuding/goMarket/internal/metrics"
 	"github.com/gostuding/goMarket/internal/server"
 	"github.com/gostuding/goMarket/internal/storage"
+	"github.com/gostuding/goMarket/internal/tracing"
 )
 
-type Config struct {
-	ServerCfg  *server.ServerConfig
-	StorageCfg *storage.StorageConfig
-}
-
-func envValue(value string, name string) string {
-	env, ok := os.LookupEnv(name)
-	if ok {
-		return env
-	}
-	return value
-}
-
-func NewConfig() *Config {
-	cfg := Config{
-		ServerCfg:  server.NewServerConfig(),
-		StorageCfg: storage.NewStorageConfig(),
-	}
-	key := "default"
-	cfg.ServerCfg.ServerAddress = envValue(cfg.ServerCfg.ServerAddress, "RUN_ADDRESS")
-	cfg.ServerCfg.AccuralAddress = envValue(cfg.ServerCfg.AccuralAddress, "ACCRUAL_SYSTEM_ADDRESS")
-	key = envValue(key, "TOKEN_KEY")
-	cfg.StorageCfg.DBConnect = envValue(cfg.StorageCfg.DBConnect, "DATABASE_URI")
-
-	flag.StringVar(&cfg.ServerCfg.ServerAddress, "a", cfg.ServerCfg.ServerAddress,
-		"адрес и порт запуска сервиса в формате ip:port")
-	flag.StringVar(&cfg.ServerCfg.AccuralAddress, "r", cfg.ServerCfg.AccuralAddress,
-		"адрес системы расчёта начислений")
-	flag.IntVar(&cfg.ServerCfg.AccrualRequestInterval, "ri", cfg.ServerCfg.AccrualRequestInterval,
-		"интервал запросов к системе расчета начислений (секунды)")
-	flag.IntVar(&cfg.ServerCfg.AuthTokenLiveTime, "t", cfg.ServerCfg.AuthTokenLiveTime,
-		"время жизни токена авторизации (секунды)")
-	flag.StringVar(&key, "k", key, "ключ для формарования токена авторизации")
-	flag.StringVar(&cfg.StorageCfg.DBConnect, "d", cfg.StorageCfg.DBConnect,
-		"строка для подключения к базе данных")
-	flag.IntVar(&cfg.StorageCfg.DBConnectionPull, "pc", cfg.StorageCfg.DBConnectionPull,
-		"максимальное количество открытых соединений с БД")
-	flag.Parse()
-	cfg.ServerCfg.AuthSecretKey = []byte(key)
-	return &cfg
-}
+var (
+	buildVersion = "N/A"
+	buildCommit  = "N/A"
+	buildDate    = "N/A"
+)
 
 // @title Gophermart API
 // @version 1.0
+// @description API для микросервиса накопительной системы лояльности «Гофермарт»
 // @contact.name API Support
 // @contact.email mag-nat1@yandex.ru
 // @host localhost:8080
@@ -61,20 +31,91 @@ func NewConfig() *Config {
 // @securityDefinitions.apikey ApiKeyAuth
 // @in header
 // @name Authorization
-// @description API для микросервиса накопительной системы лояльности «Гофермарт».
 
 func main() {
-	logger, err := logger.NewLogger()
+	command, args := "serve", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
+	}
+	var err error
+	switch command {
+	case "serve":
+		err = runServe(args)
+	case "migrate":
+		err = runMigrate(args)
+	case "user":
+		err = runUser(args)
+	case "balance":
+		err = runBalance(args)
+	case "orders":
+		err = runOrders(args)
+	case "campaign":
+		err = runCampaign(args)
+	case "export":
+		err = runExport(args)
+	case "config":
+		err = runConfig(args)
+	default:
+		err = fmt.Errorf("unknown command '%s'. %s", command, commandsUsage)
+	}
 	if err != nil {
-		log.Fatalf("Init logger error: %v", err)
+		log.Fatalf("%s error: %v", command, err)
 	}
-	cfg := NewConfig()
-	strg, err := storage.NewPSQLStorage(cfg.StorageCfg)
+}
+
+func runServe(args []string) error {
+	cfg, err := config.NewConfig("serve", args)
 	if err != nil {
-		logger.Fatalf("Create storage error: %v", err)
+		return err
+	}
+	logger, level, err := logger.NewLogger(cfg.LoggerCfg)
+	if err != nil {
+		return fmt.Errorf("init logger error: %w", err)
+	}
+	defer logger.Sync() //nolint:errcheck // <- senselessly
+	shutdownTracing, err := tracing.NewProvider(context.Background(), cfg.TracingCfg)
+	if err != nil {
+		return fmt.Errorf("init tracing error: %w", err)
+	}
+	defer func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			logger.Warnf("Shutdown tracing error: %v", err)
+		}
+	}()
+	strg, err := storage.NewPSQLStorage(cfg.StorageCfg, cfg.LoyaltyCfg)
+	if err != nil {
+		return fmt.Errorf("create storage error: %w", err)
+	}
+	metrics.SetBuildInfo(buildVersion, buildCommit, buildDate)
+	if db, err := strg.DB(); err != nil {
+		logger.Warnf("database stats metrics error: %v", err)
+	} else if err = metrics.RegisterDBStats(db); err != nil {
+		logger.Warnf("database stats metrics error: %v", err)
+	}
+	reload := func() (*server.ServerConfig, error) {
+		newCfg, err := config.NewConfig("serve", args)
+		if err != nil {
+			return nil, err //nolint:wrapcheck // <- wrapped in config
+		}
+		if err = level.UnmarshalText([]byte(newCfg.LoggerCfg.Level)); err != nil {
+			return nil, fmt.Errorf("log level error: %w", err)
+		}
+		return newCfg.ServerCfg, nil
+	}
+	if err = server.RunServer(cfg.ServerCfg, strg, logger, level, reload); err != nil {
+		return fmt.Errorf("run server error: %w", err)
+	}
+	return nil
+}
+
+// runConfig prints effective configuration with secrets redacted.
+func runConfig(args []string) error {
+	if len(args) == 0 || args[0] != "print" {
+		return fmt.Errorf("unknown config action. %s", commandsUsage)
 	}
-	err = server.RunServer(cfg.ServerCfg, strg, logger)
+	cfg, err := config.NewConfig("config print", args[1:])
 	if err != nil {
-		logger.Fatalf("Run server error: %v", err)
+		return err
 	}
+	return cfg.Print(os.Stdout) //nolint:wrapcheck // <- error is already wrapped
 }
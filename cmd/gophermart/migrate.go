@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/gostuding/goMarket/internal/config"
+	"github.com/gostuding/goMarket/internal/storage"
+)
+
+func runMigrate(args []string) error {
+	if len(args) == 0 {
+		return errors.New(commandsUsage)
+	}
+	action := args[0]
+	cfg, err := config.NewConfig("migrate "+action, args[1:])
+	if err != nil {
+		return err
+	}
+	db, err := storage.OpenDB(cfg.StorageCfg)
+	if err != nil {
+		return fmt.Errorf("open database error: %w", err)
+	}
+	defer db.Close() //nolint:errcheck // <- senselessly
+	ctx := context.Background()
+	switch action {
+	case "up":
+		done, err := storage.MigrateUp(ctx, db)
+		if err != nil {
+			return fmt.Errorf("migrate up error: %w", err)
+		}
+		fmt.Printf("applied migrations: %v\n", done)
+	case "down":
+		steps := 1
+		if len(cfg.Args) > 0 {
+			if steps, err = strconv.Atoi(cfg.Args[0]); err != nil || steps < 1 {
+				return fmt.Errorf("migrate down steps incorrect: '%s'", cfg.Args[0])
+			}
+		}
+		done, err := storage.MigrateDown(ctx, db, steps)
+		if err != nil {
+			return fmt.Errorf("migrate down error: %w", err)
+		}
+		fmt.Printf("rolled back migrations: %v\n", done)
+	case "status":
+		status, err := storage.MigrationsStatus(ctx, db)
+		if err != nil {
+			return fmt.Errorf("migrations status error: %w", err)
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err = encoder.Encode(status); err != nil {
+			return fmt.Errorf("migrations status print error: %w", err)
+		}
+	default:
+		return fmt.Errorf("unknown migrate action '%s'. %s", action, commandsUsage)
+	}
+	return nil
+}
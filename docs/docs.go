@@ -51,30 +51,37 @@ const docTemplate = `{
                     "401": {
                         "description": "Пользователь не авторизован"
                     },
+                    "429": {
+                        "description": "Превышено ограничение частоты запросов"
+                    },
                     "500": {
                         "description": "Внутренняя ошибка сервиса"
                     }
                 }
             }
         },
-        "/user/balance/withdraw": {
+        "/user/balance/holds": {
             "post": {
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
                 ],
+                "description": "Резерв уменьшает доступный баланс, но не текущий. Резерв без подтверждения или отмены истекает.",
                 "consumes": [
                     "application/json"
                 ],
+                "produces": [
+                    "application/json"
+                ],
                 "tags": [
-                    "Списание баллов"
+                    "Резервирование баллов"
                 ],
-                "summary": "Запрос на списание баллов в счёт другого заказа",
+                "summary": "Резервирование баллов в счёт заказа",
                 "parameters": [
                     {
-                        "description": "Номер заказа в счет которого списываются баллы",
-                        "name": "withdraw",
+                        "description": "Номер заказа и сумма резерва",
+                        "name": "hold",
                         "in": "body",
                         "required": true,
                         "schema": {
@@ -86,26 +93,56 @@ const docTemplate = `{
                         "description": "Токен авторизации",
                         "name": "Authorization",
                         "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Ключ идемпотентности. Повтор запроса с ключом возвращает первый ответ",
+                        "name": "Idempotency-Key",
+                        "in": "header"
                     }
                 ],
                 "responses": {
-                    "200": {
-                        "description": "Списание успешно добавлено"
+                    "201": {
+                        "description": "Резерв создан",
+                        "schema": {
+                            "$ref": "#/definitions/storage.Holds"
+                        }
                     },
                     "400": {
-                        "description": "Ошибка в теле запроса. Тело запроса не соответствует формату json"
+                        "description": "Ошибка в теле запроса или нет суммы заказа (withdraw-order-total-required)",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
                     },
                     "401": {
                         "description": "Пользователь не авторизован"
                     },
                     "402": {
-                        "description": "Недостаточно средств"
+                        "description": "Недостаточно доступных средств или баллы недоступны (withdraw-cooling-off)",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Превышен лимит списаний (withdraw-daily-limit, withdraw-monthly-limit)",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
                     },
                     "409": {
-                        "description": "Заказ уже был зарегистрирован ранее"
+                        "description": "По заказу уже есть резерв или списание, или запрос с ключом идемпотентности ещё выполняется"
+                    },
+                    "413": {
+                        "description": "Превышен размер тела запроса"
                     },
                     "422": {
-                        "description": "Номер заказа не прошёл проверку подлинности"
+                        "description": "Неверный номер заказа, ключ идемпотентности, сумма (withdraw-below-min, ...)",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "Превышено ограничение частоты запросов"
                     },
                     "500": {
                         "description": "Внутренняя ошибка сервиса"
@@ -113,41 +150,92 @@ const docTemplate = `{
                 }
             }
         },
-        "/user/login": {
+        "/user/balance/holds/{order}/capture": {
             "post": {
-                "consumes": [
-                    "application/json"
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
                 ],
                 "tags": [
-                    "Авторизация"
+                    "Резервирование баллов"
                 ],
-                "summary": "Авторизация пользователя в микросервисе",
+                "summary": "Подтверждение резерва и списание баллов",
                 "parameters": [
                     {
-                        "description": "Логи и пароль пользователя в формате json",
-                        "name": "params",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/server.LoginPassword"
-                        }
+                        "type": "string",
+                        "description": "Номер заказа резерва",
+                        "name": "order",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Токен авторизации",
+                        "name": "Authorization",
+                        "in": "header"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Успешная авторизация",
-                        "headers": {
-                            "Authorization": {
-                                "type": "string",
-                                "description": "Токен авторизации"
-                            }
-                        }
+                        "description": "Резерв переведён в списание"
                     },
-                    "400": {
-                        "description": "Ошибка в теле запроса. Тело запроса не соответствует json формату"
+                    "401": {
+                        "description": "Пользователь не авторизован"
+                    },
+                    "404": {
+                        "description": "Активный резерв не найден"
+                    },
+                    "409": {
+                        "description": "Резерв истёк или списание по заказу уже зарегистрировано"
+                    },
+                    "429": {
+                        "description": "Превышено ограничение частоты запросов"
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервиса"
+                    }
+                }
+            }
+        },
+        "/user/balance/holds/{order}/release": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "tags": [
+                    "Резервирование баллов"
+                ],
+                "summary": "Отмена резерва баллов",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Номер заказа резерва",
+                        "name": "order",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Токен авторизации",
+                        "name": "Authorization",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Резерв отменён, баллы доступны для списания"
                     },
                     "401": {
-                        "description": "Логин или пароль не найден"
+                        "description": "Пользователь не авторизован"
+                    },
+                    "404": {
+                        "description": "Активный резерв не найден"
+                    },
+                    "429": {
+                        "description": "Превышено ограничение частоты запросов"
                     },
                     "500": {
                         "description": "Внутренняя ошибка сервиса"
@@ -155,13 +243,14 @@ const docTemplate = `{
                 }
             }
         },
-        "/user/orders": {
-            "get": {
+        "/user/balance/transfer": {
+            "post": {
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
                 ],
+                "description": "Перевод выполняется после подтверждения запросом /user/balance/transfer/{id}/confirm.",
                 "consumes": [
                     "application/json"
                 ],
@@ -169,60 +258,87 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Заказы"
+                    "Переводы баллов"
                 ],
-                "summary": "Запрос списка заказов, зарегистрированных за пользователем",
+                "summary": "Создание перевода баллов другому пользователю",
                 "parameters": [
+                    {
+                        "description": "Логин получателя и сумма перевода",
+                        "name": "transfer",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/server.Transfer"
+                        }
+                    },
                     {
                         "type": "string",
                         "description": "Токен авторизации",
                         "name": "Authorization",
                         "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Ключ идемпотентности. Повтор запроса с ключом возвращает первый ответ",
+                        "name": "Idempotency-Key",
+                        "in": "header"
                     }
                 ],
                 "responses": {
-                    "200": {
-                        "description": "Список зарегистрированных за пользователем заказов",
+                    "201": {
+                        "description": "Перевод ожидает подтверждения",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/storage.Orders"
-                            }
+                            "$ref": "#/definitions/storage.Transfers"
                         }
                     },
-                    "204": {
-                        "description": "Нет данных для ответа"
+                    "400": {
+                        "description": "Ошибка в теле запроса, сумма не положительная или перевод самому себе"
                     },
                     "401": {
                         "description": "Пользователь не авторизован"
                     },
+                    "402": {
+                        "description": "Недостаточно доступных средств"
+                    },
+                    "404": {
+                        "description": "Получатель не найден"
+                    },
+                    "409": {
+                        "description": "Запрос с ключом идемпотентности ещё выполняется"
+                    },
+                    "413": {
+                        "description": "Превышен размер тела запроса"
+                    },
+                    "422": {
+                        "description": "Ключ идемпотентности использован с другим запросом"
+                    },
+                    "429": {
+                        "description": "Превышено ограничение частоты запросов"
+                    },
                     "500": {
                         "description": "Внутренняя ошибка сервиса"
                     }
                 }
-            },
+            }
+        },
+        "/user/balance/transfer/{id}/confirm": {
             "post": {
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
                 ],
-                "consumes": [
-                    "application/json"
-                ],
                 "tags": [
-                    "Заказы"
+                    "Переводы баллов"
                 ],
-                "summary": "Добавление номера заказа пользователя",
+                "summary": "Подтверждение перевода баллов",
                 "parameters": [
                     {
-                        "description": "Номер заказа",
-                        "name": "order",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "type": "string"
-                        }
+                        "type": "integer",
+                        "description": "Идентификатор перевода",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
                     },
                     {
                         "type": "string",
@@ -233,22 +349,28 @@ const docTemplate = `{
                 ],
                 "responses": {
                     "200": {
-                        "description": "Заказ уже был добавлен пользователем ранее"
-                    },
-                    "202": {
-                        "description": "Заказ успешно зарегистрирован за пользователем"
+                        "description": "Перевод выполнен"
                     },
                     "400": {
-                        "description": "Ошибка в теле запроса. Тело запроса пустое"
+                        "description": "Некорректный идентификатор перевода"
                     },
                     "401": {
                         "description": "Пользователь не авторизован"
                     },
+                    "402": {
+                        "description": "Недостаточно доступных средств"
+                    },
+                    "403": {
+                        "description": "Превышен дневной лимит переводов"
+                    },
+                    "404": {
+                        "description": "Ожидающий подтверждения перевод не найден"
+                    },
                     "409": {
-                        "description": "Заказ зарегистрирован за другим пользователем"
+                        "description": "Время подтверждения перевода истекло"
                     },
-                    "422": {
-                        "description": "Номер заказа не прошёл проверку подлинности"
+                    "429": {
+                        "description": "Превышено ограничение частоты запросов"
                     },
                     "500": {
                         "description": "Внутренняя ошибка сервиса"
@@ -256,41 +378,82 @@ const docTemplate = `{
                 }
             }
         },
-        "/user/register": {
+        "/user/balance/withdraw": {
             "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
                 "consumes": [
                     "application/json"
                 ],
                 "tags": [
-                    "Авторизация"
+                    "Списание баллов"
                 ],
-                "summary": "Регистрация нового пользователя в микросервисе",
+                "summary": "Запрос на списание баллов в счёт другого заказа",
                 "parameters": [
                     {
-                        "description": "Логи и пароль пользователя в формате json",
-                        "name": "params",
+                        "description": "Номер заказа в счет которого списываются баллы",
+                        "name": "withdraw",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/server.LoginPassword"
+                            "$ref": "#/definitions/server.Withdraw"
                         }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Токен авторизации",
+                        "name": "Authorization",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Ключ идемпотентности. Повтор запроса с ключом возвращает первый ответ",
+                        "name": "Idempotency-Key",
+                        "in": "header"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Успешная регистрация пользователя",
-                        "headers": {
-                            "Authorization": {
-                                "type": "string",
-                                "description": "Токен авторизации"
-                            }
-                        }
+                        "description": "Списание успешно добавлено. Списание можно отменить в течение окна отмены"
                     },
                     "400": {
-                        "description": "Ошибка в теле запроса. Тело запроса не соответствует json формату"
+                        "description": "Ошибка в теле запроса или нет суммы заказа (withdraw-order-total-required)",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Пользователь не авторизован"
+                    },
+                    "402": {
+                        "description": "Недостаточно средств или баллы ещё не доступны (withdraw-cooling-off)",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Превышен лимит списаний (withdraw-daily-limit, withdraw-monthly-limit)",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
                     },
                     "409": {
-                        "description": "Такой логин уже используется другим пользователем"
+                        "description": "По заказу уже есть списание или активный резерв, или запрос с ключом идемпотентности ещё выполняется"
+                    },
+                    "413": {
+                        "description": "Превышен размер тела запроса"
+                    },
+                    "422": {
+                        "description": "Неверный номер заказа, ключ идемпотентности, сумма (withdraw-below-min, ...)",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "Превышено ограничение частоты запросов"
                     },
                     "500": {
                         "description": "Внутренняя ошибка сервиса"
@@ -298,20 +461,21 @@ const docTemplate = `{
                 }
             }
         },
-        "/user/withdrawals": {
+        "/user/history": {
             "get": {
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
                 ],
+                "description": "Начисления по заказам, списания, переводы и сгорание баллов, отсортированные по времени.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Списание баллов"
+                    "Баланс пользователя"
                 ],
-                "summary": "Запрос списка списаний баллов",
+                "summary": "Запрос истории изменений баланса",
                 "parameters": [
                     {
                         "type": "string",
@@ -322,11 +486,11 @@ const docTemplate = `{
                 ],
                 "responses": {
                     "200": {
-                        "description": "Список списаний",
+                        "description": "История изменений баланса",
                         "schema": {
                             "type": "array",
                             "items": {
-                                "$ref": "#/definitions/storage.Withdraws"
+                                "$ref": "#/definitions/storage.HistoryItem"
                             }
                         }
                     },
@@ -336,51 +500,727 @@ const docTemplate = `{
                     "401": {
                         "description": "Пользователь не авторизован"
                     },
+                    "429": {
+                        "description": "Превышено ограничение частоты запросов"
+                    },
                     "500": {
                         "description": "Внутренняя ошибка сервиса"
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "server.LoginPassword": {
-            "description": "Модель для отправки логина и пароля пользователя",
-            "type": "object",
-            "properties": {
-                "login": {
-                    "description": "Логин пользователя",
-                    "type": "string"
-                },
-                "password": {
-                    "description": "Пароль пользователя",
-                    "type": "string"
-                }
-            }
-        },
-        "server.Withdraw": {
-            "type": "object",
-            "properties": {
-                "order": {
-                    "type": "string"
-                },
-                "sum": {
-                    "type": "number"
-                }
-            }
-        },
-        "storage.BalanceStruct": {
-            "type": "object",
-            "properties": {
-                "current": {
-                    "type": "number"
-                },
-                "withdrawn": {
-                    "type": "number"
-                }
-            }
         },
-        "storage.Orders": {
+        "/user/login": {
+            "post": {
+                "consumes": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Авторизация"
+                ],
+                "summary": "Авторизация пользователя в микросервисе",
+                "parameters": [
+                    {
+                        "description": "Логи и пароль пользователя в формате json",
+                        "name": "params",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/server.LoginPassword"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Успешная авторизация",
+                        "headers": {
+                            "Authorization": {
+                                "type": "string",
+                                "description": "Токен авторизации"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Ошибка в теле запроса. Тело запроса не соответствует json формату"
+                    },
+                    "401": {
+                        "description": "Логин или пароль не найден"
+                    },
+                    "413": {
+                        "description": "Превышен размер тела запроса"
+                    },
+                    "429": {
+                        "description": "Превышено ограничение частоты запросов"
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервиса"
+                    }
+                }
+            }
+        },
+        "/user/orders": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Заказы"
+                ],
+                "summary": "Запрос списка заказов, зарегистрированных за пользователем",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Токен авторизации",
+                        "name": "Authorization",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Список зарегистрированных за пользователем заказов",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/storage.Orders"
+                            }
+                        }
+                    },
+                    "204": {
+                        "description": "Нет данных для ответа"
+                    },
+                    "401": {
+                        "description": "Пользователь не авторизован"
+                    },
+                    "429": {
+                        "description": "Превышено ограничение частоты запросов"
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервиса"
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "consumes": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Заказы"
+                ],
+                "summary": "Добавление номера заказа пользователя",
+                "parameters": [
+                    {
+                        "description": "Номер заказа",
+                        "name": "order",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Токен авторизации",
+                        "name": "Authorization",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Ключ идемпотентности. Повтор запроса с ключом возвращает первый ответ",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Заказ уже был добавлен пользователем ранее"
+                    },
+                    "202": {
+                        "description": "Заказ успешно зарегистрирован за пользователем"
+                    },
+                    "400": {
+                        "description": "Ошибка в теле запроса. Тело запроса пустое"
+                    },
+                    "401": {
+                        "description": "Пользователь не авторизован"
+                    },
+                    "409": {
+                        "description": "Заказ зарегистрирован за другим пользователем или запрос с ключом идемпотентности ещё выполняется"
+                    },
+                    "413": {
+                        "description": "Превышен размер тела запроса"
+                    },
+                    "422": {
+                        "description": "Номер заказа не прошёл проверку подлинности или ключ идемпотентности использован с другим запросом"
+                    },
+                    "429": {
+                        "description": "Превышено ограничение частоты запросов"
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервиса"
+                    }
+                }
+            }
+        },
+        "/user/orders/bulk": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Номера передаются json массивом строк или текстом, по одному номеру в строке.\nРезультат возвращается для каждого номера в порядке запроса.\nНомера регистрируются пачками, каждая пачка в отдельной транзакции.\nПри ошибке хранилища после сохранения части пачек ответ 500 содержит результаты номеров,\nномера, не зарегистрированные до ошибки, возвращаются со статусом error.",
+                "consumes": [
+                    "application/json",
+                    "text/plain"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Заказы"
+                ],
+                "summary": "Пакетная загрузка номеров заказов пользователя",
+                "parameters": [
+                    {
+                        "description": "Номера заказов",
+                        "name": "orders",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Токен авторизации",
+                        "name": "Authorization",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Ключ идемпотентности. Повтор запроса с ключом возвращает первый ответ",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "207": {
+                        "description": "Результаты регистрации номеров",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/server.OrderResult"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Ошибка в теле запроса. Тело запроса пустое или не соответствует формату"
+                    },
+                    "401": {
+                        "description": "Пользователь не авторизован"
+                    },
+                    "409": {
+                        "description": "Запрос с ключом идемпотентности ещё выполняется"
+                    },
+                    "413": {
+                        "description": "Превышен размер тела запроса"
+                    },
+                    "422": {
+                        "description": "Ключ идемпотентности использован с другим запросом"
+                    },
+                    "429": {
+                        "description": "Превышено ограничение частоты запросов"
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервиса. Если часть пачек сохранена - результаты номеров\".",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/server.OrderResult"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/user/promo": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Начисляет бонус кампании промокода. Каждый пользователь может активировать код один раз.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Баланс пользователя"
+                ],
+                "summary": "Активация промокода",
+                "parameters": [
+                    {
+                        "description": "Промокод",
+                        "name": "promo",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/server.Promo"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Токен авторизации",
+                        "name": "Authorization",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Бонус начислен",
+                        "schema": {
+                            "$ref": "#/definitions/storage.PromoResult"
+                        }
+                    },
+                    "400": {
+                        "description": "Ошибка в теле запроса"
+                    },
+                    "401": {
+                        "description": "Пользователь не авторизован"
+                    },
+                    "403": {
+                        "description": "Кампания промокода не предназначена для пользователя"
+                    },
+                    "404": {
+                        "description": "Промокод не найден"
+                    },
+                    "409": {
+                        "description": "Промокод уже активирован пользователем"
+                    },
+                    "410": {
+                        "description": "Промокод израсходован, кампания не активна или её бюджет исчерпан"
+                    },
+                    "413": {
+                        "description": "Превышен размер тела запроса"
+                    },
+                    "429": {
+                        "description": "Превышено ограничение частоты запросов"
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервиса"
+                    }
+                }
+            }
+        },
+        "/user/referrals": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Статус приглашения: PENDING - ожидает первого обработанного заказа, REWARDED - бонус начислен,\nREJECTED - отклонено (reason: self, same_ip, cap, blocked).",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Баланс пользователя"
+                ],
+                "summary": "Запрос реферального кода и приглашённых пользователей",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Токен авторизации",
+                        "name": "Authorization",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Реферальный код и приглашённые пользователи",
+                        "schema": {
+                            "$ref": "#/definitions/storage.ReferralsStruct"
+                        }
+                    },
+                    "401": {
+                        "description": "Пользователь не авторизован"
+                    },
+                    "429": {
+                        "description": "Превышено ограничение частоты запросов"
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервиса"
+                    }
+                }
+            }
+        },
+        "/user/register": {
+            "post": {
+                "consumes": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Авторизация"
+                ],
+                "summary": "Регистрация нового пользователя в микросервисе",
+                "parameters": [
+                    {
+                        "description": "Логи и пароль пользователя в формате json",
+                        "name": "params",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/server.LoginPassword"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Успешная регистрация пользователя",
+                        "headers": {
+                            "Authorization": {
+                                "type": "string",
+                                "description": "Токен авторизации"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Ошибка в теле запроса. Тело запроса не соответствует json формату или реферальный код не найден"
+                    },
+                    "409": {
+                        "description": "Такой логин уже используется другим пользователем"
+                    },
+                    "413": {
+                        "description": "Превышен размер тела запроса"
+                    },
+                    "429": {
+                        "description": "Превышено ограничение частоты запросов"
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервиса"
+                    }
+                }
+            }
+        },
+        "/user/tier": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Текущий уровень, множитель начислений и прогресс до следующего уровня за расчётный период.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Баланс пользователя"
+                ],
+                "summary": "Запрос уровня пользователя",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Токен авторизации",
+                        "name": "Authorization",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Уровень пользователя",
+                        "schema": {
+                            "$ref": "#/definitions/storage.TierStruct"
+                        }
+                    },
+                    "401": {
+                        "description": "Пользователь не авторизован"
+                    },
+                    "429": {
+                        "description": "Превышено ограничение частоты запросов"
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервиса"
+                    }
+                }
+            }
+        },
+        "/user/withdrawals": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Списание баллов"
+                ],
+                "summary": "Запрос списка списаний баллов",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Токен авторизации",
+                        "name": "Authorization",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Список списаний со статусами PENDING, COMPLETED, CANCELLED, REFUNDED",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/storage.Withdraws"
+                            }
+                        }
+                    },
+                    "204": {
+                        "description": "Нет данных для ответа"
+                    },
+                    "401": {
+                        "description": "Пользователь не авторизован"
+                    },
+                    "429": {
+                        "description": "Превышено ограничение частоты запросов"
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервиса"
+                    }
+                }
+            }
+        },
+        "/user/withdrawals/{order}/cancel": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "tags": [
+                    "Списание баллов"
+                ],
+                "summary": "Отмена списания баллов пользователем",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Номер заказа списания",
+                        "name": "order",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Токен авторизации",
+                        "name": "Authorization",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Списание отменено, баллы возвращены на баланс"
+                    },
+                    "401": {
+                        "description": "Пользователь не авторизован"
+                    },
+                    "404": {
+                        "description": "Списание не найдено"
+                    },
+                    "409": {
+                        "description": "Списание не в статусе PENDING или окно отмены истекло"
+                    },
+                    "429": {
+                        "description": "Превышено ограничение частоты запросов"
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервиса"
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "problem.Problem": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "type": "string"
+                },
+                "detail": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "integer"
+                },
+                "title": {
+                    "type": "string"
+                },
+                "type": {
+                    "type": "string"
+                }
+            }
+        },
+        "server.LoginPassword": {
+            "description": "Модель для отправки логина и пароля пользователя",
+            "type": "object",
+            "properties": {
+                "login": {
+                    "description": "Логин пользователя",
+                    "type": "string"
+                },
+                "password": {
+                    "description": "Пароль пользователя",
+                    "type": "string"
+                },
+                "referral": {
+                    "description": "Реферальный код пригласившего пользователя (при регистрации)",
+                    "type": "string"
+                }
+            }
+        },
+        "server.OrderResult": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "description": "Код ответа, как у запроса регистрации одного заказа",
+                    "type": "integer"
+                },
+                "number": {
+                    "description": "Номер заказа",
+                    "type": "string"
+                },
+                "status": {
+                    "description": "Результат: accepted, already_yours, owned_by_another, invalid или error",
+                    "type": "string"
+                }
+            }
+        },
+        "server.Promo": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "description": "Промокод",
+                    "type": "string"
+                }
+            }
+        },
+        "server.Transfer": {
+            "type": "object",
+            "properties": {
+                "login": {
+                    "description": "Логин получателя",
+                    "type": "string"
+                },
+                "sum": {
+                    "description": "Сумма перевода",
+                    "type": "number"
+                }
+            }
+        },
+        "server.Withdraw": {
+            "type": "object",
+            "properties": {
+                "order": {
+                    "type": "string"
+                },
+                "order_total": {
+                    "description": "Сумма заказа, обязательна при ограничении доли оплаты баллами",
+                    "type": "number"
+                },
+                "sum": {
+                    "type": "number"
+                }
+            }
+        },
+        "storage.BalanceStruct": {
+            "type": "object",
+            "properties": {
+                "available": {
+                    "type": "number"
+                },
+                "current": {
+                    "type": "number"
+                },
+                "expiring_at": {
+                    "type": "string"
+                },
+                "expiring_soon": {
+                    "type": "number"
+                },
+                "held": {
+                    "type": "number"
+                },
+                "withdrawn": {
+                    "type": "number"
+                }
+            }
+        },
+        "storage.HistoryItem": {
+            "type": "object",
+            "properties": {
+                "campaign": {
+                    "type": "string"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "login": {
+                    "type": "string"
+                },
+                "order": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "sum": {
+                    "type": "number"
+                },
+                "type": {
+                    "type": "string"
+                }
+            }
+        },
+        "storage.Holds": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "expires_at": {
+                    "type": "string"
+                },
+                "order": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "sum": {
+                    "type": "number"
+                }
+            }
+        },
+        "storage.Orders": {
             "type": "object",
             "properties": {
                 "accrual": {
@@ -392,20 +1232,135 @@ const docTemplate = `{
                 "status": {
                     "type": "string"
                 },
+                "tier_bonus": {
+                    "type": "number"
+                },
                 "uploaded_at": {
                     "type": "string"
                 }
             }
         },
+        "storage.PromoResult": {
+            "type": "object",
+            "properties": {
+                "campaign": {
+                    "type": "string"
+                },
+                "code": {
+                    "type": "string"
+                },
+                "sum": {
+                    "type": "number"
+                }
+            }
+        },
+        "storage.Referrals": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "login": {
+                    "type": "string"
+                },
+                "reason": {
+                    "type": "string"
+                },
+                "reward": {
+                    "type": "number"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "updated_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "storage.ReferralsStruct": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "type": "string"
+                },
+                "referrals": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/storage.Referrals"
+                    }
+                }
+            }
+        },
+        "storage.TierStruct": {
+            "type": "object",
+            "properties": {
+                "basis": {
+                    "type": "string"
+                },
+                "multiplier": {
+                    "type": "number"
+                },
+                "next": {
+                    "type": "string"
+                },
+                "next_threshold": {
+                    "type": "number"
+                },
+                "period_days": {
+                    "type": "integer"
+                },
+                "progress": {
+                    "type": "number"
+                },
+                "remaining": {
+                    "type": "number"
+                },
+                "tier": {
+                    "type": "string"
+                }
+            }
+        },
+        "storage.Transfers": {
+            "type": "object",
+            "properties": {
+                "confirmed_at": {
+                    "type": "string"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "expires_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "login": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "sum": {
+                    "type": "number"
+                }
+            }
+        },
         "storage.Withdraws": {
             "type": "object",
             "properties": {
+                "cancel_until": {
+                    "type": "string"
+                },
                 "order": {
                     "type": "string"
                 },
                 "processed_at": {
                     "type": "string"
                 },
+                "status": {
+                    "type": "string"
+                },
                 "sum": {
                     "type": "number"
                 }
@@ -0,0 +1,514 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/gostuding/goMarket/internal/logger"
+	"github.com/gostuding/goMarket/internal/server"
+	"github.com/gostuding/goMarket/internal/storage"
+	"github.com/gostuding/goMarket/internal/tracing"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	configFlag    = "config"
+	configEnv     = "CONFIG"
+	redactedValue = "***"
+	defaultKey    = "default"
+)
+
+var dsnPasswordRegexp = regexp.MustCompile(`(password\s*=\s*)('[^']*'|\S+)`)
+
+// Config is the effective service configuration.
+// Values are applied in order: defaults < config file < environment < flags.
+type Config struct {
+	ServerCfg  *server.ServerConfig   `json:"server"`
+	StorageCfg *storage.StorageConfig `json:"storage"`
+	LoyaltyCfg *storage.LoyaltyConfig `json:"loyalty"`
+	LoggerCfg  *logger.LoggerConfig   `json:"logger"`
+	TracingCfg *tracing.TracingConfig `json:"tracing"`
+	TokenKey   string                 `json:"token_key"`
+	File       string                 `json:"-"`
+	Args       []string               `json:"-"`
+}
+
+func newDefaultConfig() *Config {
+	return &Config{
+		ServerCfg:  server.NewServerConfig(),
+		StorageCfg: storage.NewStorageConfig(),
+		LoyaltyCfg: storage.NewLoyaltyConfig(),
+		LoggerCfg:  logger.NewLoggerConfig(),
+		TracingCfg: tracing.NewTracingConfig(),
+		TokenKey:   defaultKey,
+	}
+}
+
+type stringList struct {
+	values *[]string
+}
+
+func (s stringList) String() string {
+	if s.values == nil {
+		return ""
+	}
+	return strings.Join(*s.values, ",")
+}
+
+func (s stringList) Set(value string) error {
+	items := make([]string, 0)
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	*s.values = items
+	return nil
+}
+
+type int64Map struct {
+	values *map[string]int64
+}
+
+func (m int64Map) String() string {
+	if m.values == nil {
+		return ""
+	}
+	items := make([]string, 0, len(*m.values))
+	for key, value := range *m.values {
+		items = append(items, fmt.Sprintf("%s=%d", key, value))
+	}
+	sort.Strings(items)
+	return strings.Join(items, ",")
+}
+
+func (m int64Map) Set(value string) error {
+	items := make(map[string]int64)
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		key, number, ok := strings.Cut(item, "=")
+		if !ok {
+			return fmt.Errorf("value '%s' must be in key=number format", item)
+		}
+		size, err := strconv.ParseInt(strings.TrimSpace(number), 10, 64)
+		if err != nil {
+			return fmt.Errorf("value '%s' number error: %w", item, err)
+		}
+		items[strings.TrimSpace(key)] = size
+	}
+	*m.values = items
+	return nil
+}
+
+type stringMap struct {
+	values *map[string]string
+}
+
+func (m stringMap) String() string {
+	if m.values == nil {
+		return ""
+	}
+	items := make([]string, 0, len(*m.values))
+	for key, value := range *m.values {
+		items = append(items, key+"="+value)
+	}
+	sort.Strings(items)
+	return strings.Join(items, ",")
+}
+
+func (m stringMap) Set(value string) error {
+	items := make(map[string]string)
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		key, val, ok := strings.Cut(item, "=")
+		if !ok {
+			return fmt.Errorf("value '%s' must be in key=value format", item)
+		}
+		items[strings.TrimSpace(key)] = strings.TrimSpace(val)
+	}
+	*m.values = items
+	return nil
+}
+
+// option binds flag to its environment variable.
+type option struct {
+	flag string
+	env  string
+}
+
+func newFlagSet(name string, cfg *Config) (*flag.FlagSet, []option) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.StringVar(&cfg.File, configFlag, cfg.File, "файл конфигурации в формате yaml, json или toml")
+	fs.StringVar(&cfg.ServerCfg.ServerAddress, "a", cfg.ServerCfg.ServerAddress,
+		"адрес и порт запуска сервиса в формате ip:port")
+	fs.StringVar(&cfg.ServerCfg.AccuralAddress, "r", cfg.ServerCfg.AccuralAddress,
+		"адрес системы расчёта начислений")
+	fs.IntVar(&cfg.ServerCfg.AccrualRequestInterval, "ri", cfg.ServerCfg.AccrualRequestInterval,
+		"интервал запросов к системе расчета начислений (секунды)")
+	fs.IntVar(&cfg.ServerCfg.AccrualWorkers, "accrual-workers", cfg.ServerCfg.AccrualWorkers,
+		"количество одновременных запросов к системе расчета начислений")
+	fs.Var(stringList{values: &cfg.ServerCfg.CORSOrigins}, "cors-origins",
+		"список разрешённых CORS источников через запятую")
+	fs.Var(stringList{values: &cfg.ServerCfg.TrustedProxies}, "trusted-proxies",
+		"адреса или сети (CIDR) доверенных прокси через запятую, только от них принимается X-Forwarded-For")
+	fs.IntVar(&cfg.ServerCfg.AuthTokenLiveTime, "t", cfg.ServerCfg.AuthTokenLiveTime,
+		"время жизни токена авторизации (секунды)")
+	fs.StringVar(&cfg.TokenKey, "k", cfg.TokenKey, "ключ для формарования токена авторизации")
+	fs.StringVar(&cfg.StorageCfg.DBConnect, "d", cfg.StorageCfg.DBConnect,
+		"строка для подключения к базе данных")
+	fs.IntVar(&cfg.StorageCfg.DBConnectionPull, "pc", cfg.StorageCfg.DBConnectionPull,
+		"максимальное количество открытых соединений с БД")
+	fs.BoolVar(&cfg.StorageCfg.AutoMigrate, "auto-migrate", cfg.StorageCfg.AutoMigrate,
+		"применять миграции структуры БД при запуске сервера")
+	fs.StringVar(&cfg.ServerCfg.AdminAddress, "admin-address", cfg.ServerCfg.AdminAddress,
+		"адрес и порт административного сервиса в формате ip:port (пустое значение - не запускать)")
+	fs.StringVar(&cfg.ServerCfg.TLSCertFile, "tls-cert", cfg.ServerCfg.TLSCertFile,
+		"файл TLS сертификата (вместе с -tls-key включает https)")
+	fs.StringVar(&cfg.ServerCfg.TLSKeyFile, "tls-key", cfg.ServerCfg.TLSKeyFile, "файл ключа TLS сертификата")
+	fs.StringVar(&cfg.ServerCfg.TLSMinVersion, "tls-min-version", cfg.ServerCfg.TLSMinVersion,
+		"минимальная версия TLS (1.2, 1.3)")
+	fs.Var(stringList{values: &cfg.ServerCfg.TLSCipherSuites}, "tls-ciphers",
+		"список разрешённых шифров TLS 1.2 через запятую (по умолчанию - набор Go)")
+	fs.StringVar(&cfg.ServerCfg.AdminClientCAFile, "admin-client-ca", cfg.ServerCfg.AdminClientCAFile,
+		"файл сертификатов CA для проверки клиентских сертификатов административного сервиса")
+	fs.StringVar(&cfg.ServerCfg.HTTPRedirectAddress, "http-redirect-address", cfg.ServerCfg.HTTPRedirectAddress,
+		"адрес и порт http сервиса перенаправления на https (пустое значение - не запускать)")
+	fs.IntVar(&cfg.ServerCfg.ReadHeaderTimeout, "read-header-timeout", cfg.ServerCfg.ReadHeaderTimeout,
+		"время чтения заголовков запроса (секунды)")
+	fs.IntVar(&cfg.ServerCfg.ReadTimeout, "read-timeout", cfg.ServerCfg.ReadTimeout,
+		"время чтения запроса (секунды)")
+	fs.IntVar(&cfg.ServerCfg.WriteTimeout, "write-timeout", cfg.ServerCfg.WriteTimeout,
+		"время записи ответа (секунды)")
+	fs.IntVar(&cfg.ServerCfg.IdleTimeout, "idle-timeout", cfg.ServerCfg.IdleTimeout,
+		"время ожидания следующего запроса keep-alive соединения (секунды)")
+	fs.Int64Var(&cfg.ServerCfg.MaxBodySize, "max-body-size", cfg.ServerCfg.MaxBodySize,
+		"максимальный размер тела запроса (байты)")
+	fs.Int64Var(&cfg.ServerCfg.MaxDecompressedSize, "max-decompressed-size", cfg.ServerCfg.MaxDecompressedSize,
+		"максимальный размер распакованного gzip тела запроса (байты)")
+	fs.Var(int64Map{values: &cfg.ServerCfg.BodyLimits}, "body-limits",
+		"размер тела запроса для отдельных адресов в формате путь=байты через запятую")
+	fs.StringVar(&cfg.ServerCfg.RateLimitStore, "rate-limit-store", cfg.ServerCfg.RateLimitStore,
+		"хранилище ограничений частоты запросов (memory, postgres)")
+	fs.Var(stringMap{values: &cfg.ServerCfg.RateLimits}, "rate-limits",
+		"ограничения частоты запросов в формате 'МЕТОД /путь=запросы/период' через запятую, * - все запросы")
+	fs.IntVar(&cfg.ServerCfg.IdempotencyTTL, "idempotency-ttl", cfg.ServerCfg.IdempotencyTTL,
+		"время хранения ответов на запросы с заголовком Idempotency-Key (секунды)")
+	fs.IntVar(&cfg.ServerCfg.WithdrawCancelWindow, "withdraw-cancel-window", cfg.ServerCfg.WithdrawCancelWindow,
+		"время, в течение которого пользователь может отменить списание (секунды, 0 - без отмены)")
+	fs.IntVar(&cfg.ServerCfg.HoldTTL, "hold-ttl", cfg.ServerCfg.HoldTTL,
+		"время жизни резерва баллов без подтверждения (секунды)")
+	fs.IntVar(&cfg.ServerCfg.TransferConfirmTTL, "transfer-confirm-ttl", cfg.ServerCfg.TransferConfirmTTL,
+		"время на подтверждение перевода баллов (секунды)")
+	fs.Float64Var(&cfg.ServerCfg.TransferDailyLimit, "transfer-daily-limit", cfg.ServerCfg.TransferDailyLimit,
+		"максимальная сумма переводов пользователя за сутки (0 - без ограничения)")
+	fs.IntVar(&cfg.ServerCfg.TransferDailyCount, "transfer-daily-count", cfg.ServerCfg.TransferDailyCount,
+		"максимальное количество переводов пользователя за сутки (0 - без ограничения)")
+	fs.IntVar(&cfg.ServerCfg.TiersRecalcHour, "tiers-recalc-hour", cfg.ServerCfg.TiersRecalcHour,
+		"час ежедневного пересчёта уровней пользователей (0-23)")
+	fs.Var(stringList{values: &cfg.ServerCfg.OrderValidators}, "order-validators",
+		"проверки номеров заказов через запятую (luhn, length, prefix, regex, none)")
+	fs.IntVar(&cfg.ServerCfg.OrderMinLength, "order-min-length", cfg.ServerCfg.OrderMinLength,
+		"минимальная длина номера заказа для проверки length")
+	fs.IntVar(&cfg.ServerCfg.OrderMaxLength, "order-max-length", cfg.ServerCfg.OrderMaxLength,
+		"максимальная длина номера заказа для проверки length (0 - без ограничения)")
+	fs.Var(stringMap{values: &cfg.ServerCfg.OrderPrefixes}, "order-prefixes",
+		"префиксы номеров заказов магазинов для проверки prefix в формате магазин=префикс через запятую")
+	fs.StringVar(&cfg.ServerCfg.OrderPattern, "order-pattern", cfg.ServerCfg.OrderPattern,
+		"регулярное выражение номера заказа для проверки regex")
+	fs.IntVar(&cfg.ServerCfg.PollerStaleTimeout, "poller-stale-timeout", cfg.ServerCfg.PollerStaleTimeout,
+		"время с последнего опроса системы начислений, после которого сервис не готов (секунды)")
+	fs.IntVar(&cfg.ServerCfg.ShutdownDelay, "shutdown-delay", cfg.ServerCfg.ShutdownDelay,
+		"задержка остановки сервера после перехода /readyz в состояние отказа (секунды)")
+	fs.BoolVar(&cfg.ServerCfg.MetricsAdminOnly, "metrics-admin-only", cfg.ServerCfg.MetricsAdminOnly,
+		"отдавать метрики /metrics только на административном адресе")
+	fs.IntVar(&cfg.LoyaltyCfg.PointsExpiryMonths, "points-expiry-months", cfg.LoyaltyCfg.PointsExpiryMonths,
+		"срок действия начисленных баллов (месяцы, 0 - бессрочно)")
+	fs.IntVar(&cfg.LoyaltyCfg.ExpiringSoonDays, "expiring-soon-days", cfg.LoyaltyCfg.ExpiringSoonDays,
+		"период, за который баланс показывает сгорающие баллы (дни)")
+	fs.StringVar(&cfg.LoyaltyCfg.TierBasis, "tier-basis", cfg.LoyaltyCfg.TierBasis,
+		"показатель для расчёта уровня пользователя (earned - начисленные баллы, spend - списанные баллы)")
+	fs.IntVar(&cfg.LoyaltyCfg.TierPeriodDays, "tier-period-days", cfg.LoyaltyCfg.TierPeriodDays,
+		"период, за который считается показатель уровня (дни)")
+	fs.Float64Var(&cfg.LoyaltyCfg.TierSilverThreshold, "tier-silver-threshold", cfg.LoyaltyCfg.TierSilverThreshold,
+		"порог показателя для уровня silver")
+	fs.Float64Var(&cfg.LoyaltyCfg.TierGoldThreshold, "tier-gold-threshold", cfg.LoyaltyCfg.TierGoldThreshold,
+		"порог показателя для уровня gold")
+	fs.Float64Var(&cfg.LoyaltyCfg.TierBronzeMultiplier, "tier-bronze-multiplier", cfg.LoyaltyCfg.TierBronzeMultiplier,
+		"множитель начислений для уровня bronze")
+	fs.Float64Var(&cfg.LoyaltyCfg.TierSilverMultiplier, "tier-silver-multiplier", cfg.LoyaltyCfg.TierSilverMultiplier,
+		"множитель начислений для уровня silver")
+	fs.Float64Var(&cfg.LoyaltyCfg.TierGoldMultiplier, "tier-gold-multiplier", cfg.LoyaltyCfg.TierGoldMultiplier,
+		"множитель начислений для уровня gold")
+	fs.Float64Var(&cfg.LoyaltyCfg.ReferralReward, "referral-reward", cfg.LoyaltyCfg.ReferralReward,
+		"бонус пригласившему пользователю после первого обработанного заказа приглашённого")
+	fs.IntVar(&cfg.LoyaltyCfg.ReferralCap, "referral-cap", cfg.LoyaltyCfg.ReferralCap,
+		"максимальное количество вознаграждений одному пригласившему пользователю (0 - без ограничения)")
+	fs.Float64Var(&cfg.LoyaltyCfg.WithdrawMinSum, "withdraw-min-sum", cfg.LoyaltyCfg.WithdrawMinSum,
+		"минимальная сумма одного списания")
+	fs.Float64Var(&cfg.LoyaltyCfg.WithdrawMaxSum, "withdraw-max-sum", cfg.LoyaltyCfg.WithdrawMaxSum,
+		"максимальная сумма одного списания (0 - без ограничения)")
+	fs.Float64Var(&cfg.LoyaltyCfg.WithdrawDailyLimit, "withdraw-daily-limit", cfg.LoyaltyCfg.WithdrawDailyLimit,
+		"максимальная сумма списаний пользователя за сутки (0 - без ограничения)")
+	fs.Float64Var(&cfg.LoyaltyCfg.WithdrawMonthlyLimit, "withdraw-monthly-limit", cfg.LoyaltyCfg.WithdrawMonthlyLimit,
+		"максимальная сумма списаний пользователя за месяц (0 - без ограничения)")
+	fs.Float64Var(&cfg.LoyaltyCfg.WithdrawMaxOrderShare, "withdraw-max-order-share",
+		cfg.LoyaltyCfg.WithdrawMaxOrderShare,
+		"максимальная доля суммы заказа, оплачиваемая баллами (1 - без ограничения)")
+	fs.IntVar(&cfg.LoyaltyCfg.PointsCoolingOffHours, "points-cooling-off-hours", cfg.LoyaltyCfg.PointsCoolingOffHours,
+		"количество часов, в течение которых начисленные баллы нельзя списать (0 - без ограничения)")
+	fs.StringVar(&cfg.TracingCfg.Exporter, "trace-exporter", cfg.TracingCfg.Exporter,
+		"экспорт трассировки (none, otlp, stdout, file)")
+	fs.StringVar(&cfg.TracingCfg.Endpoint, "trace-endpoint", cfg.TracingCfg.Endpoint,
+		"адрес OTLP коллектора в формате host:port")
+	fs.BoolVar(&cfg.TracingCfg.Insecure, "trace-insecure", cfg.TracingCfg.Insecure,
+		"подключение к OTLP коллектору без TLS")
+	fs.StringVar(&cfg.TracingCfg.FilePath, "trace-file", cfg.TracingCfg.FilePath,
+		"файл для записи трассировки (для -trace-exporter=file)")
+	fs.Float64Var(&cfg.TracingCfg.SampleRatio, "trace-sample-ratio", cfg.TracingCfg.SampleRatio,
+		"доля записываемых трассировок (от 0 до 1)")
+	fs.StringVar(&cfg.LoggerCfg.Level, "log-level", cfg.LoggerCfg.Level,
+		"уровень логирования (debug, info, warn, error)")
+	fs.StringVar(&cfg.LoggerCfg.Encoding, "log-encoding", cfg.LoggerCfg.Encoding,
+		"формат записей лога (json, console)")
+	fs.Var(stringList{values: &cfg.LoggerCfg.OutputPaths}, "log-output",
+		"список мест записи лога через запятую (stdout, stderr или путь к файлу)")
+	fs.IntVar(&cfg.LoggerCfg.FileMaxSize, "log-max-size", cfg.LoggerCfg.FileMaxSize,
+		"максимальный размер файла лога до ротации (мегабайты)")
+	fs.IntVar(&cfg.LoggerCfg.FileMaxBackups, "log-max-backups", cfg.LoggerCfg.FileMaxBackups,
+		"количество хранимых файлов лога после ротации")
+	fs.IntVar(&cfg.LoggerCfg.FileMaxAge, "log-max-age", cfg.LoggerCfg.FileMaxAge,
+		"время хранения файлов лога после ротации (дни)")
+	fs.BoolVar(&cfg.LoggerCfg.Sampling, "log-sampling", cfg.LoggerCfg.Sampling,
+		"включить сэмплирование записей лога")
+	fs.IntVar(&cfg.LoggerCfg.SamplingInitial, "log-sampling-initial", cfg.LoggerCfg.SamplingInitial,
+		"количество одинаковых записей лога в секунду, записываемых без сэмплирования")
+	fs.IntVar(&cfg.LoggerCfg.SamplingThereafter, "log-sampling-thereafter", cfg.LoggerCfg.SamplingThereafter,
+		"после превышения лимита записывается каждая N-я одинаковая запись лога")
+
+	options := []option{
+		{"a", "RUN_ADDRESS"},
+		{"r", "ACCRUAL_SYSTEM_ADDRESS"},
+		{"ri", "ACCRUAL_REQUEST_INTERVAL"},
+		{"accrual-workers", "ACCRUAL_WORKERS"},
+		{"cors-origins", "CORS_ORIGINS"},
+		{"trusted-proxies", "TRUSTED_PROXIES"},
+		{"t", "TOKEN_LIVE_TIME"},
+		{"k", "TOKEN_KEY"},
+		{"d", "DATABASE_URI"},
+		{"pc", "DATABASE_POOL_SIZE"},
+		{"auto-migrate", "AUTO_MIGRATE"},
+		{"admin-address", "ADMIN_ADDRESS"},
+		{"tls-cert", "TLS_CERT_FILE"},
+		{"tls-key", "TLS_KEY_FILE"},
+		{"tls-min-version", "TLS_MIN_VERSION"},
+		{"tls-ciphers", "TLS_CIPHER_SUITES"},
+		{"admin-client-ca", "ADMIN_CLIENT_CA_FILE"},
+		{"http-redirect-address", "HTTP_REDIRECT_ADDRESS"},
+		{"read-header-timeout", "READ_HEADER_TIMEOUT"},
+		{"read-timeout", "READ_TIMEOUT"},
+		{"write-timeout", "WRITE_TIMEOUT"},
+		{"idle-timeout", "IDLE_TIMEOUT"},
+		{"max-body-size", "MAX_BODY_SIZE"},
+		{"max-decompressed-size", "MAX_DECOMPRESSED_SIZE"},
+		{"body-limits", "BODY_LIMITS"},
+		{"rate-limit-store", "RATE_LIMIT_STORE"},
+		{"rate-limits", "RATE_LIMITS"},
+		{"idempotency-ttl", "IDEMPOTENCY_TTL"},
+		{"withdraw-cancel-window", "WITHDRAW_CANCEL_WINDOW"},
+		{"hold-ttl", "HOLD_TTL"},
+		{"transfer-confirm-ttl", "TRANSFER_CONFIRM_TTL"},
+		{"transfer-daily-limit", "TRANSFER_DAILY_LIMIT"},
+		{"transfer-daily-count", "TRANSFER_DAILY_COUNT"},
+		{"tiers-recalc-hour", "TIERS_RECALC_HOUR"},
+		{"order-validators", "ORDER_VALIDATORS"},
+		{"order-min-length", "ORDER_MIN_LENGTH"},
+		{"order-max-length", "ORDER_MAX_LENGTH"},
+		{"order-prefixes", "ORDER_PREFIXES"},
+		{"order-pattern", "ORDER_PATTERN"},
+		{"poller-stale-timeout", "POLLER_STALE_TIMEOUT"},
+		{"shutdown-delay", "SHUTDOWN_DELAY"},
+		{"metrics-admin-only", "METRICS_ADMIN_ONLY"},
+		{"points-expiry-months", "POINTS_EXPIRY_MONTHS"},
+		{"expiring-soon-days", "EXPIRING_SOON_DAYS"},
+		{"tier-basis", "TIER_BASIS"},
+		{"tier-period-days", "TIER_PERIOD_DAYS"},
+		{"tier-silver-threshold", "TIER_SILVER_THRESHOLD"},
+		{"tier-gold-threshold", "TIER_GOLD_THRESHOLD"},
+		{"tier-bronze-multiplier", "TIER_BRONZE_MULTIPLIER"},
+		{"tier-silver-multiplier", "TIER_SILVER_MULTIPLIER"},
+		{"tier-gold-multiplier", "TIER_GOLD_MULTIPLIER"},
+		{"referral-reward", "REFERRAL_REWARD"},
+		{"referral-cap", "REFERRAL_CAP"},
+		{"withdraw-min-sum", "WITHDRAW_MIN_SUM"},
+		{"withdraw-max-sum", "WITHDRAW_MAX_SUM"},
+		{"withdraw-daily-limit", "WITHDRAW_DAILY_LIMIT"},
+		{"withdraw-monthly-limit", "WITHDRAW_MONTHLY_LIMIT"},
+		{"withdraw-max-order-share", "WITHDRAW_MAX_ORDER_SHARE"},
+		{"points-cooling-off-hours", "POINTS_COOLING_OFF_HOURS"},
+		{"trace-exporter", "TRACE_EXPORTER"},
+		{"trace-endpoint", "TRACE_ENDPOINT"},
+		{"trace-insecure", "TRACE_INSECURE"},
+		{"trace-file", "TRACE_FILE"},
+		{"trace-sample-ratio", "TRACE_SAMPLE_RATIO"},
+		{"log-level", "LOG_LEVEL"},
+		{"log-encoding", "LOG_ENCODING"},
+		{"log-output", "LOG_OUTPUT"},
+		{"log-max-size", "LOG_FILE_MAX_SIZE"},
+		{"log-max-backups", "LOG_FILE_MAX_BACKUPS"},
+		{"log-max-age", "LOG_FILE_MAX_AGE"},
+		{"log-sampling", "LOG_SAMPLING"},
+		{"log-sampling-initial", "LOG_SAMPLING_INITIAL"},
+		{"log-sampling-thereafter", "LOG_SAMPLING_THEREAFTER"},
+	}
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage of %s:\n", name)
+		fs.PrintDefaults()
+		fmt.Fprintln(fs.Output(), "\nEnvironment variables:")
+		fmt.Fprintf(fs.Output(), "  %s -> -%s\n", configEnv, configFlag)
+		for _, item := range options {
+			fmt.Fprintf(fs.Output(), "  %s -> -%s\n", item.env, item.flag)
+		}
+	}
+	return fs, options
+}
+
+// loadFile reads config file. Format is selected by file extension.
+func loadFile(path string, cfg *Config) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read config file error: %w", err)
+	}
+	values := make(map[string]any)
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &values)
+	case ".toml":
+		err = toml.Unmarshal(data, &values)
+	case ".json":
+		err = json.Unmarshal(data, &values)
+	default:
+		return fmt.Errorf("unknown config file format: '%s'", path)
+	}
+	if err != nil {
+		return fmt.Errorf("config file '%s' parse error: %w", path, err)
+	}
+	// All formats are converted to json to use the same field names.
+	jsonData, err := json.Marshal(values)
+	if err != nil {
+		return fmt.Errorf("config file '%s' convert error: %w", path, err)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(jsonData))
+	decoder.DisallowUnknownFields()
+	if err = decoder.Decode(cfg); err != nil {
+		return fmt.Errorf("config file '%s' values error: %w", path, err)
+	}
+	return nil
+}
+
+// NewConfig builds configuration from defaults, config file, environment and command line arguments.
+func NewConfig(name string, args []string) (*Config, error) {
+	cfg := newDefaultConfig()
+	fs, options := newFlagSet(name, cfg)
+	if err := fs.Parse(args); err != nil {
+		return nil, fmt.Errorf("arguments parse error: %w", err)
+	}
+	flags := make(map[string]string)
+	fs.Visit(func(f *flag.Flag) {
+		flags[f.Name] = f.Value.String()
+	})
+	if _, ok := flags[configFlag]; !ok {
+		if env, ok := os.LookupEnv(configEnv); ok {
+			cfg.File = env
+		}
+	}
+	if cfg.File != "" {
+		if err := loadFile(cfg.File, cfg); err != nil {
+			return nil, err
+		}
+	}
+	errs := make([]error, 0)
+	for _, item := range options {
+		if env, ok := os.LookupEnv(item.env); ok {
+			if err := fs.Set(item.flag, env); err != nil {
+				errs = append(errs, fmt.Errorf("environment '%s' value incorrect: %w", item.env, err))
+			}
+		}
+	}
+	for name, value := range flags {
+		if err := fs.Set(name, value); err != nil {
+			errs = append(errs, fmt.Errorf("flag '%s' value incorrect: %w", name, err))
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	cfg.Args = fs.Args()
+	cfg.ServerCfg.AuthSecretKey = []byte(cfg.TokenKey)
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("config validation error: %w", err)
+	}
+	return cfg, nil
+}
+
+// Validate checks all configuration options.
+func (cfg *Config) Validate() error {
+	return errors.Join(
+		cfg.ServerCfg.Validate(),
+		cfg.StorageCfg.Validate(),
+		cfg.LoyaltyCfg.Validate(),
+		cfg.LoggerCfg.Validate(),
+		cfg.TracingCfg.Validate(),
+	)
+}
+
+func redactDSN(dsn string) string {
+	if u, err := url.Parse(dsn); err == nil && u.Scheme != "" && u.User != nil {
+		if _, ok := u.User.Password(); ok {
+			u.User = url.UserPassword(u.User.Username(), redactedValue)
+			return strings.Replace(u.String(), url.QueryEscape(redactedValue), redactedValue, 1)
+		}
+		return dsn
+	}
+	return dsnPasswordRegexp.ReplaceAllString(dsn, "${1}"+redactedValue)
+}
+
+// Print writes effective configuration in yaml format with secrets redacted.
+func (cfg *Config) Print(w io.Writer) error {
+	redacted := *cfg
+	storageCfg := *cfg.StorageCfg
+	storageCfg.DBConnect = redactDSN(storageCfg.DBConnect)
+	redacted.StorageCfg = &storageCfg
+	if redacted.TokenKey != "" {
+		redacted.TokenKey = redactedValue
+	}
+	data, err := json.Marshal(redacted)
+	if err != nil {
+		return fmt.Errorf("config convert error: %w", err)
+	}
+	values := make(map[string]any)
+	if err = json.Unmarshal(data, &values); err != nil {
+		return fmt.Errorf("config convert error: %w", err)
+	}
+	encoder := yaml.NewEncoder(w)
+	defer encoder.Close() //nolint:errcheck // <- senselessly
+	if err = encoder.Encode(values); err != nil {
+		return fmt.Errorf("config print error: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package idempotency
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+)
+
+// Header is request header with client generated key.
+const Header = "Idempotency-Key"
+
+// MaxKeyLength is maximum length of idempotency key.
+const MaxKeyLength = 255
+
+// Record is saved result of the first request with idempotency key.
+// Done is false while the first request is processed.
+type Record struct {
+	Fingerprint string
+	ContentType string
+	Body        []byte
+	Status      int
+	Done        bool
+}
+
+// Store keeps idempotency records of users.
+type Store interface {
+	// StartIdempotent saves new record with fingerprint if key is not used or expired.
+	// Returns saved record and false if key is already used.
+	StartIdempotent(ctx context.Context, uid int, key, fingerprint string, ttl time.Duration) (*Record, bool, error)
+	// FinishIdempotent saves response of request.
+	FinishIdempotent(ctx context.Context, uid int, key string, record *Record) error
+	// CancelIdempotent removes record, so request with the key can be repeated.
+	CancelIdempotent(ctx context.Context, uid int, key string) error
+}
+
+// Fingerprint returns hash of request method, path and body.
+func Fingerprint(method, path string, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(method + " " + path + "\n"))
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
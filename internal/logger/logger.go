@@ -1,15 +1,140 @@
 package logger
 
 import (
+	"errors"
 	"fmt"
+	"os"
+	"strings"
+	"time"
 
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"gopkg.in/natefinch/lumberjack.v2"
 )
 
-func NewLogger() (*zap.SugaredLogger, error) {
-	logger, err := zap.NewDevelopment()
+const (
+	EncodingJSON    = "json"
+	EncodingConsole = "console"
+
+	defaultLevel              = "info"
+	defaultFileMaxSize        = 100
+	defaultFileMaxBackups     = 5
+	defaultFileMaxAge         = 30
+	defaultSamplingInitial    = 100
+	defaultSamplingThereafter = 100
+)
+
+type LoggerConfig struct {
+	Level              string   `json:"level"`
+	Encoding           string   `json:"encoding"`
+	OutputPaths        []string `json:"output_paths"`
+	FileMaxSize        int      `json:"file_max_size"`
+	FileMaxBackups     int      `json:"file_max_backups"`
+	FileMaxAge         int      `json:"file_max_age"`
+	SamplingInitial    int      `json:"sampling_initial"`
+	SamplingThereafter int      `json:"sampling_thereafter"`
+	Sampling           bool     `json:"sampling"`
+}
+
+func NewLoggerConfig() *LoggerConfig {
+	return &LoggerConfig{
+		Level:              defaultLevel,
+		Encoding:           EncodingJSON,
+		OutputPaths:        []string{"stdout"},
+		FileMaxSize:        defaultFileMaxSize,
+		FileMaxBackups:     defaultFileMaxBackups,
+		FileMaxAge:         defaultFileMaxAge,
+		SamplingInitial:    defaultSamplingInitial,
+		SamplingThereafter: defaultSamplingThereafter,
+	}
+}
+
+// Validate checks logger options values.
+func (cfg *LoggerConfig) Validate() error {
+	errs := make([]error, 0)
+	if _, err := zapcore.ParseLevel(cfg.Level); err != nil {
+		errs = append(errs, fmt.Errorf("log level incorrect: %w", err))
+	}
+	if cfg.Encoding != EncodingJSON && cfg.Encoding != EncodingConsole {
+		errs = append(errs, fmt.Errorf("unknown log encoding: '%s'", cfg.Encoding))
+	}
+	if len(cfg.OutputPaths) == 0 {
+		errs = append(errs, errors.New("log output paths is empty"))
+	}
+	if cfg.FileMaxSize <= 0 || cfg.FileMaxBackups < 0 || cfg.FileMaxAge < 0 {
+		errs = append(errs, errors.New("log file rotation options must not be negative, max size must be positive"))
+	}
+	if cfg.Sampling && (cfg.SamplingInitial <= 0 || cfg.SamplingThereafter <= 0) {
+		errs = append(errs, errors.New("log sampling options must be positive"))
+	}
+	return errors.Join(errs...)
+}
+
+func (cfg *LoggerConfig) encoder() (zapcore.Encoder, error) {
+	switch cfg.Encoding {
+	case EncodingJSON:
+		encCfg := zap.NewProductionEncoderConfig()
+		encCfg.EncodeTime = zapcore.ISO8601TimeEncoder
+		return zapcore.NewJSONEncoder(encCfg), nil
+	case EncodingConsole:
+		encCfg := zap.NewDevelopmentEncoderConfig()
+		encCfg.EncodeTime = zapcore.ISO8601TimeEncoder
+		return zapcore.NewConsoleEncoder(encCfg), nil
+	default:
+		return nil, fmt.Errorf("unknown log encoding: '%s'", cfg.Encoding)
+	}
+}
+
+func (cfg *LoggerConfig) writer() (zapcore.WriteSyncer, error) {
+	if len(cfg.OutputPaths) == 0 {
+		return nil, errors.New("log output paths is empty")
+	}
+	syncers := make([]zapcore.WriteSyncer, 0, len(cfg.OutputPaths))
+	for _, path := range cfg.OutputPaths {
+		switch strings.TrimSpace(path) {
+		case "":
+			continue
+		case "stdout":
+			syncers = append(syncers, zapcore.Lock(os.Stdout))
+		case "stderr":
+			syncers = append(syncers, zapcore.Lock(os.Stderr))
+		default:
+			syncers = append(syncers, zapcore.AddSync(&lumberjack.Logger{
+				Filename:   strings.TrimSpace(path),
+				MaxSize:    cfg.FileMaxSize,
+				MaxBackups: cfg.FileMaxBackups,
+				MaxAge:     cfg.FileMaxAge,
+			}))
+		}
+	}
+	if len(syncers) == 0 {
+		return nil, errors.New("log output paths is empty")
+	}
+	return zapcore.NewMultiWriteSyncer(syncers...), nil
+}
+
+// NewLogger creates logger and returns its atomic level for changing at runtime.
+func NewLogger(cfg *LoggerConfig) (*zap.SugaredLogger, zap.AtomicLevel, error) {
+	level := zap.NewAtomicLevel()
+	if cfg == nil {
+		return nil, level, errors.New("logger config is nil")
+	}
+	if err := level.UnmarshalText([]byte(cfg.Level)); err != nil {
+		return nil, level, fmt.Errorf("logger level error: %w", err)
+	}
+	encoder, err := cfg.encoder()
 	if err != nil {
-		return nil, fmt.Errorf("logger init error: %w", err)
+		return nil, level, fmt.Errorf("logger init error: %w", err)
+	}
+	writer, err := cfg.writer()
+	if err != nil {
+		return nil, level, fmt.Errorf("logger init error: %w", err)
+	}
+	core := zapcore.NewCore(encoder, writer, level)
+	if cfg.Sampling {
+		core = zapcore.NewSamplerWithOptions(core, time.Second, cfg.SamplingInitial, cfg.SamplingThereafter)
 	}
-	return logger.Sugar(), nil
+	logger := zap.New(core, zap.AddCaller(), zap.AddStacktrace(zapcore.ErrorLevel),
+		zap.ErrorOutput(zapcore.Lock(os.Stderr)))
+	return logger.Sugar(), level, nil
 }
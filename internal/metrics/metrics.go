@@ -0,0 +1,144 @@
+package metrics
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const (
+	namespace = "gophermart"
+
+	AccrualResultOK          = "ok"
+	AccrualResultError       = "error"
+	AccrualResultBadStatus   = "bad_status"
+	AccrualResultManyRequest = "too_many_requests"
+)
+
+var lastAccrualSuccess atomic.Int64
+
+var (
+	HTTPRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "Count of HTTP requests by route, method and status.",
+	}, []string{"route", "method", "status"})
+	HTTPDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "HTTP request latency by route, method and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+
+	AccrualQueueDepth = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "accrual",
+		Name:      "queue_depth",
+		Help:      "Count of orders waiting for accrual request.",
+	})
+	AccrualRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "accrual",
+		Name:      "requests_total",
+		Help:      "Count of accrual system requests by result.",
+	}, []string{"result"})
+	AccrualPauses = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "accrual",
+		Name:      "pauses_total",
+		Help:      "Count of pauses requested by accrual system (429 responces).",
+	})
+	_ = promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "accrual",
+		Name:      "seconds_since_last_success",
+		Help:      "Seconds since last successful accrual poll (-1 if there was no one).",
+	}, func() float64 {
+		last := lastAccrualSuccess.Load()
+		if last == 0 {
+			return -1
+		}
+		return time.Since(time.Unix(0, last)).Seconds()
+	})
+
+	Registrations = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "registrations_total",
+		Help:      "Count of registered users.",
+	})
+	OrdersAccepted = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "orders_accepted_total",
+		Help:      "Count of accepted orders.",
+	})
+	PointsAccrued = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "points_accrued_total",
+		Help:      "Sum of accrued points.",
+	})
+	PointsWithdrawn = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "points_withdrawn_total",
+		Help:      "Sum of withdrawn points.",
+	})
+	WithdrawsReverted = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "withdraws_reverted_total",
+		Help:      "Count of cancelled and refunded withdrawals by reason.",
+	}, []string{"reason"})
+	Holds = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "holds_total",
+		Help:      "Count of points holds by result (created, captured, released, expired).",
+	}, []string{"result"})
+	WithdrawRuleViolations = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "withdraw_rule_violations_total",
+		Help:      "Count of withdrawals and holds rejected by withdrawal rules by problem code.",
+	}, []string{"code"})
+	PromoRedemptions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "promo_redemptions_total",
+		Help:      "Count of promo code redemption requests by response status.",
+	}, []string{"status"})
+
+	buildInfo = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "build_info",
+		Help:      "Build information of the service.",
+	}, []string{"version", "commit", "date"})
+)
+
+func init() {
+	prometheus.MustRegister(collectors.NewBuildInfoCollector())
+}
+
+// SetAccrualSuccess marks current time as the last successful accrual poll.
+func SetAccrualSuccess() {
+	lastAccrualSuccess.Store(time.Now().UnixNano())
+}
+
+func SetBuildInfo(version, commit, date string) {
+	buildInfo.WithLabelValues(version, commit, date).Set(1)
+}
+
+// RegisterDBStats adds database connections pool statistics collector.
+func RegisterDBStats(db *sql.DB) error {
+	if err := prometheus.Register(collectors.NewDBStatsCollector(db, namespace)); err != nil {
+		return fmt.Errorf("register db stats collector error: %w", err)
+	}
+	return nil
+}
+
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
@@ -5,57 +5,61 @@
 package mocks
 
 import (
-	gomock "github.com/golang/mock/gomock"
+	context "context"
 	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
 )
 
-// MockCheckOrdersStorage is a mock of CheckOrdersStorage interface
+// MockCheckOrdersStorage is a mock of CheckOrdersStorage interface.
 type MockCheckOrdersStorage struct {
 	ctrl     *gomock.Controller
 	recorder *MockCheckOrdersStorageMockRecorder
 }
 
-// MockCheckOrdersStorageMockRecorder is the mock recorder for MockCheckOrdersStorage
+// MockCheckOrdersStorageMockRecorder is the mock recorder for MockCheckOrdersStorage.
 type MockCheckOrdersStorageMockRecorder struct {
 	mock *MockCheckOrdersStorage
 }
 
-// NewMockCheckOrdersStorage creates a new mock instance
+// NewMockCheckOrdersStorage creates a new mock instance.
 func NewMockCheckOrdersStorage(ctrl *gomock.Controller) *MockCheckOrdersStorage {
 	mock := &MockCheckOrdersStorage{ctrl: ctrl}
 	mock.recorder = &MockCheckOrdersStorageMockRecorder{mock}
 	return mock
 }
 
-// EXPECT returns an object that allows the caller to indicate expected use
+// EXPECT returns an object that allows the caller to indicate expected use.
 func (m *MockCheckOrdersStorage) EXPECT() *MockCheckOrdersStorageMockRecorder {
 	return m.recorder
 }
 
-// GetAccrualOrders mocks base method
-func (m *MockCheckOrdersStorage) GetAccrualOrders() []string {
+// GetAccrualOrders mocks base method.
+func (m *MockCheckOrdersStorage) GetAccrualOrders(arg0 context.Context) ([]string, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetAccrualOrders")
+	ret := m.ctrl.Call(m, "GetAccrualOrders", arg0)
 	ret0, _ := ret[0].([]string)
-	return ret0
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
-// GetAccrualOrders indicates an expected call of GetAccrualOrders
-func (mr *MockCheckOrdersStorageMockRecorder) GetAccrualOrders() *gomock.Call {
+// GetAccrualOrders indicates an expected call of GetAccrualOrders.
+func (mr *MockCheckOrdersStorageMockRecorder) GetAccrualOrders(arg0 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAccrualOrders", reflect.TypeOf((*MockCheckOrdersStorage)(nil).GetAccrualOrders))
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAccrualOrders", reflect.TypeOf((*MockCheckOrdersStorage)(nil).GetAccrualOrders), arg0)
 }
 
-// SetOrderData mocks base method
-func (m *MockCheckOrdersStorage) SetOrderData(arg0, arg1 string, arg2 float32) error {
+// SetOrderData mocks base method.
+func (m *MockCheckOrdersStorage) SetOrderData(arg0 context.Context, arg1, arg2 string, arg3 float32) (float32, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "SetOrderData", arg0, arg1, arg2)
-	ret0, _ := ret[0].(error)
-	return ret0
+	ret := m.ctrl.Call(m, "SetOrderData", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(float32)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
-// SetOrderData indicates an expected call of SetOrderData
-func (mr *MockCheckOrdersStorageMockRecorder) SetOrderData(arg0, arg1, arg2 interface{}) *gomock.Call {
+// SetOrderData indicates an expected call of SetOrderData.
+func (mr *MockCheckOrdersStorageMockRecorder) SetOrderData(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetOrderData", reflect.TypeOf((*MockCheckOrdersStorage)(nil).SetOrderData), arg0, arg1, arg2)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetOrderData", reflect.TypeOf((*MockCheckOrdersStorage)(nil).SetOrderData), arg0, arg1, arg2, arg3)
 }
@@ -7,8 +7,11 @@ package mocks
 import (
 	context "context"
 	reflect "reflect"
+	time "time"
 
 	gomock "github.com/golang/mock/gomock"
+	idempotency "github.com/gostuding/goMarket/internal/idempotency"
+	ratelimit "github.com/gostuding/goMarket/internal/ratelimit"
 )
 
 // MockStorage is a mock of Storage interface.
@@ -34,6 +37,22 @@ func (m *MockStorage) EXPECT() *MockStorageMockRecorder {
 	return m.recorder
 }
 
+// AddHold mocks base method.
+func (m *MockStorage) AddHold(arg0 context.Context, arg1 int, arg2 string, arg3, arg4 float32, arg5 time.Duration) ([]byte, int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddHold", arg0, arg1, arg2, arg3, arg4, arg5)
+	ret0, _ := ret[0].([]byte)
+	ret1, _ := ret[1].(int)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// AddHold indicates an expected call of AddHold.
+func (mr *MockStorageMockRecorder) AddHold(arg0, arg1, arg2, arg3, arg4, arg5 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddHold", reflect.TypeOf((*MockStorage)(nil).AddHold), arg0, arg1, arg2, arg3, arg4, arg5)
+}
+
 // AddOrder mocks base method.
 func (m *MockStorage) AddOrder(arg0 context.Context, arg1 int, arg2 string) (int, error) {
 	m.ctrl.T.Helper()
@@ -49,19 +68,94 @@ func (mr *MockStorageMockRecorder) AddOrder(arg0, arg1, arg2 interface{}) *gomoc
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddOrder", reflect.TypeOf((*MockStorage)(nil).AddOrder), arg0, arg1, arg2)
 }
 
+// AddOrders mocks base method.
+func (m *MockStorage) AddOrders(arg0 context.Context, arg1 int, arg2 []string) (map[string]int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddOrders", arg0, arg1, arg2)
+	ret0, _ := ret[0].(map[string]int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AddOrders indicates an expected call of AddOrders.
+func (mr *MockStorageMockRecorder) AddOrders(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddOrders", reflect.TypeOf((*MockStorage)(nil).AddOrders), arg0, arg1, arg2)
+}
+
+// AddTransfer mocks base method.
+func (m *MockStorage) AddTransfer(arg0 context.Context, arg1 int, arg2 string, arg3 float32, arg4 time.Duration) ([]byte, int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddTransfer", arg0, arg1, arg2, arg3, arg4)
+	ret0, _ := ret[0].([]byte)
+	ret1, _ := ret[1].(int)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// AddTransfer indicates an expected call of AddTransfer.
+func (mr *MockStorageMockRecorder) AddTransfer(arg0, arg1, arg2, arg3, arg4 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddTransfer", reflect.TypeOf((*MockStorage)(nil).AddTransfer), arg0, arg1, arg2, arg3, arg4)
+}
+
 // AddWithdraw mocks base method.
-func (m *MockStorage) AddWithdraw(arg0 context.Context, arg1 int, arg2 string, arg3 float32) (int, error) {
+func (m *MockStorage) AddWithdraw(arg0 context.Context, arg1 int, arg2 string, arg3, arg4 float32, arg5 time.Duration) (int, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "AddWithdraw", arg0, arg1, arg2, arg3)
+	ret := m.ctrl.Call(m, "AddWithdraw", arg0, arg1, arg2, arg3, arg4, arg5)
 	ret0, _ := ret[0].(int)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // AddWithdraw indicates an expected call of AddWithdraw.
-func (mr *MockStorageMockRecorder) AddWithdraw(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+func (mr *MockStorageMockRecorder) AddWithdraw(arg0, arg1, arg2, arg3, arg4, arg5 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddWithdraw", reflect.TypeOf((*MockStorage)(nil).AddWithdraw), arg0, arg1, arg2, arg3)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddWithdraw", reflect.TypeOf((*MockStorage)(nil).AddWithdraw), arg0, arg1, arg2, arg3, arg4, arg5)
+}
+
+// CancelIdempotent mocks base method.
+func (m *MockStorage) CancelIdempotent(arg0 context.Context, arg1 int, arg2 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CancelIdempotent", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CancelIdempotent indicates an expected call of CancelIdempotent.
+func (mr *MockStorageMockRecorder) CancelIdempotent(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CancelIdempotent", reflect.TypeOf((*MockStorage)(nil).CancelIdempotent), arg0, arg1, arg2)
+}
+
+// CancelWithdraw mocks base method.
+func (m *MockStorage) CancelWithdraw(arg0 context.Context, arg1 int, arg2 string) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CancelWithdraw", arg0, arg1, arg2)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CancelWithdraw indicates an expected call of CancelWithdraw.
+func (mr *MockStorageMockRecorder) CancelWithdraw(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CancelWithdraw", reflect.TypeOf((*MockStorage)(nil).CancelWithdraw), arg0, arg1, arg2)
+}
+
+// CaptureHold mocks base method.
+func (m *MockStorage) CaptureHold(arg0 context.Context, arg1 int, arg2 string, arg3 time.Duration) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CaptureHold", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CaptureHold indicates an expected call of CaptureHold.
+func (mr *MockStorageMockRecorder) CaptureHold(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CaptureHold", reflect.TypeOf((*MockStorage)(nil).CaptureHold), arg0, arg1, arg2, arg3)
 }
 
 // Close mocks base method.
@@ -78,18 +172,93 @@ func (mr *MockStorageMockRecorder) Close() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Close", reflect.TypeOf((*MockStorage)(nil).Close))
 }
 
+// ConfirmTransfer mocks base method.
+func (m *MockStorage) ConfirmTransfer(arg0 context.Context, arg1, arg2 int, arg3 float32, arg4 int) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ConfirmTransfer", arg0, arg1, arg2, arg3, arg4)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ConfirmTransfer indicates an expected call of ConfirmTransfer.
+func (mr *MockStorageMockRecorder) ConfirmTransfer(arg0, arg1, arg2, arg3, arg4 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ConfirmTransfer", reflect.TypeOf((*MockStorage)(nil).ConfirmTransfer), arg0, arg1, arg2, arg3, arg4)
+}
+
+// ExpireHolds mocks base method.
+func (m *MockStorage) ExpireHolds(arg0 context.Context) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ExpireHolds", arg0)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ExpireHolds indicates an expected call of ExpireHolds.
+func (mr *MockStorageMockRecorder) ExpireHolds(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExpireHolds", reflect.TypeOf((*MockStorage)(nil).ExpireHolds), arg0)
+}
+
+// ExpirePoints mocks base method.
+func (m *MockStorage) ExpirePoints(arg0 context.Context) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ExpirePoints", arg0)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ExpirePoints indicates an expected call of ExpirePoints.
+func (mr *MockStorageMockRecorder) ExpirePoints(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExpirePoints", reflect.TypeOf((*MockStorage)(nil).ExpirePoints), arg0)
+}
+
+// FinishIdempotent mocks base method.
+func (m *MockStorage) FinishIdempotent(arg0 context.Context, arg1 int, arg2 string, arg3 *idempotency.Record) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FinishIdempotent", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// FinishIdempotent indicates an expected call of FinishIdempotent.
+func (mr *MockStorageMockRecorder) FinishIdempotent(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FinishIdempotent", reflect.TypeOf((*MockStorage)(nil).FinishIdempotent), arg0, arg1, arg2, arg3)
+}
+
 // GetAccrualOrders mocks base method.
-func (m *MockStorage) GetAccrualOrders() []string {
+func (m *MockStorage) GetAccrualOrders(arg0 context.Context) ([]string, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetAccrualOrders")
+	ret := m.ctrl.Call(m, "GetAccrualOrders", arg0)
 	ret0, _ := ret[0].([]string)
-	return ret0
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
 // GetAccrualOrders indicates an expected call of GetAccrualOrders.
-func (mr *MockStorageMockRecorder) GetAccrualOrders() *gomock.Call {
+func (mr *MockStorageMockRecorder) GetAccrualOrders(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAccrualOrders", reflect.TypeOf((*MockStorage)(nil).GetAccrualOrders), arg0)
+}
+
+// GetHistory mocks base method.
+func (m *MockStorage) GetHistory(arg0 context.Context, arg1 int) ([]byte, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetHistory", arg0, arg1)
+	ret0, _ := ret[0].([]byte)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetHistory indicates an expected call of GetHistory.
+func (mr *MockStorageMockRecorder) GetHistory(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAccrualOrders", reflect.TypeOf((*MockStorage)(nil).GetAccrualOrders))
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetHistory", reflect.TypeOf((*MockStorage)(nil).GetHistory), arg0, arg1)
 }
 
 // GetOrders mocks base method.
@@ -107,6 +276,21 @@ func (mr *MockStorageMockRecorder) GetOrders(arg0, arg1 interface{}) *gomock.Cal
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrders", reflect.TypeOf((*MockStorage)(nil).GetOrders), arg0, arg1)
 }
 
+// GetReferrals mocks base method.
+func (m *MockStorage) GetReferrals(arg0 context.Context, arg1 int) ([]byte, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetReferrals", arg0, arg1)
+	ret0, _ := ret[0].([]byte)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetReferrals indicates an expected call of GetReferrals.
+func (mr *MockStorageMockRecorder) GetReferrals(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetReferrals", reflect.TypeOf((*MockStorage)(nil).GetReferrals), arg0, arg1)
+}
+
 // GetUserBalance mocks base method.
 func (m *MockStorage) GetUserBalance(arg0 context.Context, arg1 int) ([]byte, error) {
 	m.ctrl.T.Helper()
@@ -122,6 +306,21 @@ func (mr *MockStorageMockRecorder) GetUserBalance(arg0, arg1 interface{}) *gomoc
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserBalance", reflect.TypeOf((*MockStorage)(nil).GetUserBalance), arg0, arg1)
 }
 
+// GetUserTier mocks base method.
+func (m *MockStorage) GetUserTier(arg0 context.Context, arg1 int) ([]byte, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserTier", arg0, arg1)
+	ret0, _ := ret[0].([]byte)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserTier indicates an expected call of GetUserTier.
+func (mr *MockStorageMockRecorder) GetUserTier(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserTier", reflect.TypeOf((*MockStorage)(nil).GetUserTier), arg0, arg1)
+}
+
 // GetWithdraws mocks base method.
 func (m *MockStorage) GetWithdraws(arg0 context.Context, arg1 int) ([]byte, error) {
 	m.ctrl.T.Helper()
@@ -137,6 +336,35 @@ func (mr *MockStorageMockRecorder) GetWithdraws(arg0, arg1 interface{}) *gomock.
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWithdraws", reflect.TypeOf((*MockStorage)(nil).GetWithdraws), arg0, arg1)
 }
 
+// IsMigrated mocks base method.
+func (m *MockStorage) IsMigrated(arg0 context.Context) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IsMigrated", arg0)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// IsMigrated indicates an expected call of IsMigrated.
+func (mr *MockStorageMockRecorder) IsMigrated(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IsMigrated", reflect.TypeOf((*MockStorage)(nil).IsMigrated), arg0)
+}
+
+// IsReferralNotFound mocks base method.
+func (m *MockStorage) IsReferralNotFound(arg0 error) bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IsReferralNotFound", arg0)
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// IsReferralNotFound indicates an expected call of IsReferralNotFound.
+func (mr *MockStorageMockRecorder) IsReferralNotFound(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IsReferralNotFound", reflect.TypeOf((*MockStorage)(nil).IsReferralNotFound), arg0)
+}
+
 // IsUniqueViolation mocks base method.
 func (m *MockStorage) IsUniqueViolation(arg0 error) bool {
 	m.ctrl.T.Helper()
@@ -166,31 +394,153 @@ func (mr *MockStorageMockRecorder) Login(arg0, arg1, arg2, arg3, arg4 interface{
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Login", reflect.TypeOf((*MockStorage)(nil).Login), arg0, arg1, arg2, arg3, arg4)
 }
 
+// Ping mocks base method.
+func (m *MockStorage) Ping(arg0 context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Ping", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Ping indicates an expected call of Ping.
+func (mr *MockStorageMockRecorder) Ping(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Ping", reflect.TypeOf((*MockStorage)(nil).Ping), arg0)
+}
+
+// RecalculateTiers mocks base method.
+func (m *MockStorage) RecalculateTiers(arg0 context.Context) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RecalculateTiers", arg0)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RecalculateTiers indicates an expected call of RecalculateTiers.
+func (mr *MockStorageMockRecorder) RecalculateTiers(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RecalculateTiers", reflect.TypeOf((*MockStorage)(nil).RecalculateTiers), arg0)
+}
+
+// RedeemPromo mocks base method.
+func (m *MockStorage) RedeemPromo(arg0 context.Context, arg1 int, arg2 string) ([]byte, int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RedeemPromo", arg0, arg1, arg2)
+	ret0, _ := ret[0].([]byte)
+	ret1, _ := ret[1].(int)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// RedeemPromo indicates an expected call of RedeemPromo.
+func (mr *MockStorageMockRecorder) RedeemPromo(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RedeemPromo", reflect.TypeOf((*MockStorage)(nil).RedeemPromo), arg0, arg1, arg2)
+}
+
+// RefundWithdraw mocks base method.
+func (m *MockStorage) RefundWithdraw(arg0 context.Context, arg1 string) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RefundWithdraw", arg0, arg1)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RefundWithdraw indicates an expected call of RefundWithdraw.
+func (mr *MockStorageMockRecorder) RefundWithdraw(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RefundWithdraw", reflect.TypeOf((*MockStorage)(nil).RefundWithdraw), arg0, arg1)
+}
+
 // Registration mocks base method.
-func (m *MockStorage) Registration(arg0 context.Context, arg1, arg2, arg3, arg4 string) (int, error) {
+func (m *MockStorage) Registration(arg0 context.Context, arg1, arg2, arg3, arg4, arg5 string) (int, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Registration", arg0, arg1, arg2, arg3, arg4)
+	ret := m.ctrl.Call(m, "Registration", arg0, arg1, arg2, arg3, arg4, arg5)
 	ret0, _ := ret[0].(int)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // Registration indicates an expected call of Registration.
-func (mr *MockStorageMockRecorder) Registration(arg0, arg1, arg2, arg3, arg4 interface{}) *gomock.Call {
+func (mr *MockStorageMockRecorder) Registration(arg0, arg1, arg2, arg3, arg4, arg5 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Registration", reflect.TypeOf((*MockStorage)(nil).Registration), arg0, arg1, arg2, arg3, arg4)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Registration", reflect.TypeOf((*MockStorage)(nil).Registration), arg0, arg1, arg2, arg3, arg4, arg5)
+}
+
+// ReleaseHold mocks base method.
+func (m *MockStorage) ReleaseHold(arg0 context.Context, arg1 int, arg2 string) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReleaseHold", arg0, arg1, arg2)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReleaseHold indicates an expected call of ReleaseHold.
+func (mr *MockStorageMockRecorder) ReleaseHold(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReleaseHold", reflect.TypeOf((*MockStorage)(nil).ReleaseHold), arg0, arg1, arg2)
 }
 
 // SetOrderData mocks base method.
-func (m *MockStorage) SetOrderData(arg0, arg1 string, arg2 float32) error {
+func (m *MockStorage) SetOrderData(arg0 context.Context, arg1, arg2 string, arg3 float32) (float32, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "SetOrderData", arg0, arg1, arg2)
-	ret0, _ := ret[0].(error)
-	return ret0
+	ret := m.ctrl.Call(m, "SetOrderData", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(float32)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
 // SetOrderData indicates an expected call of SetOrderData.
-func (mr *MockStorageMockRecorder) SetOrderData(arg0, arg1, arg2 interface{}) *gomock.Call {
+func (mr *MockStorageMockRecorder) SetOrderData(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetOrderData", reflect.TypeOf((*MockStorage)(nil).SetOrderData), arg0, arg1, arg2, arg3)
+}
+
+// StartIdempotent mocks base method.
+func (m *MockStorage) StartIdempotent(arg0 context.Context, arg1 int, arg2, arg3 string, arg4 time.Duration) (*idempotency.Record, bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "StartIdempotent", arg0, arg1, arg2, arg3, arg4)
+	ret0, _ := ret[0].(*idempotency.Record)
+	ret1, _ := ret[1].(bool)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// StartIdempotent indicates an expected call of StartIdempotent.
+func (mr *MockStorageMockRecorder) StartIdempotent(arg0, arg1, arg2, arg3, arg4 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StartIdempotent", reflect.TypeOf((*MockStorage)(nil).StartIdempotent), arg0, arg1, arg2, arg3, arg4)
+}
+
+// Take mocks base method.
+func (m *MockStorage) Take(arg0 context.Context, arg1 string, arg2 ratelimit.Rule) (ratelimit.Result, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Take", arg0, arg1, arg2)
+	ret0, _ := ret[0].(ratelimit.Result)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Take indicates an expected call of Take.
+func (mr *MockStorageMockRecorder) Take(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Take", reflect.TypeOf((*MockStorage)(nil).Take), arg0, arg1, arg2)
+}
+
+// UserAllowed mocks base method.
+func (m *MockStorage) UserAllowed(arg0 context.Context, arg1 int, arg2 time.Time) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UserAllowed", arg0, arg1, arg2)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UserAllowed indicates an expected call of UserAllowed.
+func (mr *MockStorageMockRecorder) UserAllowed(arg0, arg1, arg2 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetOrderData", reflect.TypeOf((*MockStorage)(nil).SetOrderData), arg0, arg1, arg2)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UserAllowed", reflect.TypeOf((*MockStorage)(nil).UserAllowed), arg0, arg1, arg2)
 }
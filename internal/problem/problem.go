@@ -0,0 +1,65 @@
+// Package problem contains RFC 7807 problem details responses with machine readable codes.
+package problem
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// ContentType is media type of problem details response.
+const ContentType = "application/problem+json"
+
+const typePrefix = "urn:gophermart:problem:"
+
+// Problem is problem details of rejected request. Code identifies the problem for clients.
+type Problem struct {
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Code   string `json:"code"`
+	Detail string `json:"detail,omitempty"`
+	Status int    `json:"status"`
+}
+
+// New creates problem with HTTP status, code and short title.
+func New(status int, code, title string) *Problem {
+	return &Problem{Type: typePrefix + code, Title: title, Code: code, Status: status}
+}
+
+func (p *Problem) Error() string {
+	if p.Detail == "" {
+		return p.Code + ": " + p.Title
+	}
+	return p.Code + ": " + p.Detail
+}
+
+// WithDetail returns copy of problem with detail message.
+func (p *Problem) WithDetail(format string, args ...any) *Problem {
+	detailed := *p
+	detailed.Detail = fmt.Sprintf(format, args...)
+	return &detailed
+}
+
+// As returns problem from error chain.
+func As(err error) (*Problem, bool) {
+	var p *Problem
+	if errors.As(err, &p) {
+		return p, true
+	}
+	return nil, false
+}
+
+// Write writes problem response.
+func Write(w http.ResponseWriter, p *Problem) error {
+	data, err := json.Marshal(p)
+	if err != nil {
+		return fmt.Errorf("convert problem to json error: %w", err)
+	}
+	w.Header().Set("Content-Type", ContentType)
+	w.WriteHeader(p.Status)
+	if _, err = w.Write(data); err != nil {
+		return fmt.Errorf("write problem error: %w", err)
+	}
+	return nil
+}
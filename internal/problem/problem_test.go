@@ -0,0 +1,33 @@
+package problem
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWrite(t *testing.T) {
+	base := New(http.StatusForbidden, "daily-limit", "daily limit exceeded")
+	err := fmt.Errorf("add withdraw: %w", base.WithDetail("limit %d", 100))
+	p, ok := As(err)
+	if !ok {
+		t.Fatal("problem not found in error chain")
+	}
+	if base.Detail != "" {
+		t.Errorf("WithDetail() changed base problem: %v", base)
+	}
+	w := httptest.NewRecorder()
+	if err = Write(w, p); err != nil {
+		t.Fatalf("Write() error: %v", err)
+	}
+	want := `{"type":"urn:gophermart:problem:daily-limit","title":"daily limit exceeded","code":"daily-limit",` +
+		`"detail":"limit 100","status":403}`
+	if w.Code != http.StatusForbidden || w.Header().Get("Content-Type") != ContentType || w.Body.String() != want {
+		t.Errorf("Write() = %d %s '%s', want %d '%s'", w.Code, w.Header().Get("Content-Type"), w.Body.String(),
+			http.StatusForbidden, want)
+	}
+	if _, ok = As(fmt.Errorf("other: %w", http.ErrBodyNotAllowed)); ok {
+		t.Error("As() found problem in other error")
+	}
+}
@@ -0,0 +1,54 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const sweepInterval = time.Minute
+
+type memoryBucket struct {
+	full time.Time
+	Bucket
+}
+
+// MemoryStore keeps buckets of one service instance.
+type MemoryStore struct {
+	buckets   map[string]*memoryBucket
+	lastSweep time.Time
+	now       func() time.Time
+	mutex     sync.Mutex
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: make(map[string]*memoryBucket), now: time.Now, lastSweep: time.Now()}
+}
+
+// sweep removes full buckets, they are equal to new ones.
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < sweepInterval {
+		return
+	}
+	s.lastSweep = now
+	for key, item := range s.buckets {
+		if now.After(item.full) {
+			delete(s.buckets, key)
+		}
+	}
+}
+
+func (s *MemoryStore) Take(_ context.Context, key string, rule Rule) (Result, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	now := s.now()
+	s.sweep(now)
+	item, ok := s.buckets[key]
+	if !ok {
+		item = &memoryBucket{}
+		s.buckets[key] = item
+	}
+	res := item.Take(rule, now)
+	item.full = item.Full(rule)
+	return res, nil
+}
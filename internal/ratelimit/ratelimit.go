@@ -0,0 +1,92 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	StoreMemory   = "memory"
+	StorePostgres = "postgres"
+)
+
+// Rule allows Limit requests per Period. Bucket is refilled evenly during the period.
+type Rule struct {
+	Limit  int
+	Period time.Duration
+}
+
+// ParseRule parses rule in format "<limit>/<period>", for example "10/1m" or "5/30s".
+func ParseRule(value string) (Rule, error) {
+	limit, period, ok := strings.Cut(strings.TrimSpace(value), "/")
+	if !ok {
+		return Rule{}, fmt.Errorf("rate limit '%s' must be in limit/period format", value)
+	}
+	count, err := strconv.Atoi(limit)
+	if err != nil || count <= 0 {
+		return Rule{}, fmt.Errorf("rate limit '%s' limit must be positive number", value)
+	}
+	duration, err := time.ParseDuration(period)
+	if err != nil || duration <= 0 {
+		return Rule{}, fmt.Errorf("rate limit '%s' period must be positive duration", value)
+	}
+	return Rule{Limit: count, Period: duration}, nil
+}
+
+func (r Rule) rate() float64 {
+	return float64(r.Limit) / r.Period.Seconds()
+}
+
+// Result of taking token from bucket.
+type Result struct {
+	Limit      int
+	Remaining  int
+	Reset      time.Duration
+	RetryAfter time.Duration
+	Allowed    bool
+}
+
+// Store keeps token buckets by key.
+type Store interface {
+	Take(ctx context.Context, key string, rule Rule) (Result, error)
+}
+
+// Bucket is a token bucket state. Stores use it to take tokens.
+type Bucket struct {
+	Updated time.Time
+	Tokens  float64
+}
+
+// Take refills bucket for the time passed since last update and takes one token if possible.
+func (b *Bucket) Take(rule Rule, now time.Time) Result {
+	limit, rate := float64(rule.Limit), rule.rate()
+	if b.Updated.IsZero() {
+		b.Tokens = limit
+	} else if elapsed := now.Sub(b.Updated).Seconds(); elapsed > 0 {
+		b.Tokens = math.Min(limit, b.Tokens+elapsed*rate)
+	}
+	b.Updated = now
+	res := Result{Limit: rule.Limit}
+	if b.Tokens >= 1 {
+		b.Tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = seconds((1 - b.Tokens) / rate)
+	}
+	res.Remaining = int(b.Tokens)
+	res.Reset = seconds((limit - b.Tokens) / rate)
+	return res
+}
+
+// Full returns time when bucket is filled up.
+func (b *Bucket) Full(rule Rule) time.Time {
+	return b.Updated.Add(seconds((float64(rule.Limit) - b.Tokens) / rule.rate()))
+}
+
+func seconds(value float64) time.Duration {
+	return time.Duration(value * float64(time.Second))
+}
@@ -0,0 +1,68 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestParseRule(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    Rule
+		wantErr bool
+	}{
+		{name: "Запросы в минуту", value: "10/1m", want: Rule{Limit: 10, Period: time.Minute}},
+		{name: "Запросы в секунду", value: " 5/1s ", want: Rule{Limit: 5, Period: time.Second}},
+		{name: "Нет периода", value: "10", wantErr: true},
+		{name: "Нулевой лимит", value: "0/1m", wantErr: true},
+		{name: "Некорректный период", value: "10/minute", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseRule(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseRule() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseRule() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	store := NewMemoryStore()
+	store.now, store.lastSweep = func() time.Time { return now }, now
+	rule := Rule{Limit: 2, Period: 10 * time.Second}
+	steps := []struct {
+		name       string
+		key        string
+		wait       time.Duration
+		allowed    bool
+		remaining  int
+		retryAfter time.Duration
+	}{
+		{name: "Первый запрос", key: "a", allowed: true, remaining: 1},
+		{name: "Второй запрос", key: "a", allowed: true, remaining: 0},
+		{name: "Лимит исчерпан", key: "a", allowed: false, remaining: 0, retryAfter: 5 * time.Second},
+		{name: "Другой ключ", key: "b", allowed: true, remaining: 1},
+		{name: "Пополнение корзины", key: "a", wait: 5 * time.Second, allowed: true, remaining: 0},
+		{name: "Полная корзина", key: "a", wait: time.Minute, allowed: true, remaining: 1},
+	}
+	for _, step := range steps {
+		now = now.Add(step.wait)
+		res, err := store.Take(context.Background(), step.key, rule)
+		if err != nil {
+			t.Fatalf("%s: Take() error = %v", step.name, err)
+		}
+		if res.Allowed != step.allowed || res.Remaining != step.remaining || res.RetryAfter != step.retryAfter {
+			t.Errorf("%s: Take() = %+v", step.name, res)
+		}
+	}
+	if len(store.buckets) != 1 {
+		t.Errorf("full buckets are not removed: %d", len(store.buckets))
+	}
+}
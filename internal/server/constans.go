@@ -5,13 +5,50 @@ const (
 	defaultAccrualRequestInterval = 1
 	manyRequestsWaitTimeDef       = 60
 	shutdownTimeout               = 10
+	defaultPollerStaleTimeout     = 60
 	defaultRequestPoll            = 10
-	writeResponceErrorString      = "responce body write error: %w"
+	writeResponceErrorString      = "responce body write error: %v"
 	contentTypeString             = "Content-Type"
 	ctApplicationJSONString       = "application/json"
 	uidContextTypeError           = "context uid is not int"
 	incorrectIPErroString         = "remote ip incorrect: %w"
 	gormError                     = "gorm error: %w"
 	tokenGenerateError            = "token generation error: %w"
-	readRequestErrorString        = "read request body error: %w"
+	readRequestErrorString        = "read request body error: %v"
+	metricsURL                    = "/metrics"
+	registerURL                   = "/api/user/register"
+	loginURL                      = "/api/user/login"
+	ordersListURL                 = "/api/user/orders"
+	ordersBulkURL                 = "/api/user/orders/bulk"
+	withdrawURL                   = "/api/user/balance/withdraw"
+	balanceURL                    = "/api/user/balance"
+	withdrawalsURL                = "/api/user/withdrawals"
+	withdrawCancelURL             = "/api/user/withdrawals/{order}/cancel"
+	withdrawRefundURL             = "/api/admin/withdrawals/{order}/refund"
+	holdsURL                      = "/api/user/balance/holds"
+	holdCaptureURL                = "/api/user/balance/holds/{order}/capture"
+	holdReleaseURL                = "/api/user/balance/holds/{order}/release"
+	transferURL                   = "/api/user/balance/transfer"
+	transferConfirmURL            = "/api/user/balance/transfer/{id}/confirm"
+	historyURL                    = "/api/user/history"
+	tierURL                       = "/api/user/tier"
+	promoURL                      = "/api/user/promo"
+	referralsURL                  = "/api/user/referrals"
+	allRoutes                     = "*"
+	defaultReadHeaderTimeout      = 5
+	defaultReadTimeout            = 10
+	defaultWriteTimeout           = 30
+	defaultIdleTimeout            = 120
+	defaultMaxBodySize            = 1 << 20
+	defaultMaxDecompressedSize    = 4 << 20
+	defaultAuthBodySize           = 4 << 10
+	defaultOrderBodySize          = 1 << 10
+	defaultOrdersBulkBodySize     = 64 << 10
+	maxAccrualResponseSize        = 1 << 20
+	defaultIdempotencyTTL         = 24 * 60 * 60
+	defaultWithdrawCancelWindow   = 15 * 60
+	defaultHoldTTL                = 15 * 60
+	defaultTransferConfirmTTL     = 5 * 60
+	defaultTiersRecalcHour        = 3
+	maxHour                       = 23
 )
@@ -6,35 +6,103 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"mime"
 	"net"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/go-chi/chi"
+	"github.com/gostuding/goMarket/internal/idempotency"
+	"github.com/gostuding/goMarket/internal/metrics"
+	"github.com/gostuding/goMarket/internal/problem"
+	"github.com/gostuding/goMarket/internal/ratelimit"
 	"github.com/gostuding/goMarket/internal/server/middlewares"
+	"github.com/gostuding/goMarket/internal/tracing"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
 	"gorm.io/gorm"
 )
 
 type Storage interface {
 	CheckOrdersStorage
-	Registration(context.Context, string, string, string, string) (int, error)
+	HealthStorage
+	ratelimit.Store
+	idempotency.Store
+	middlewares.AuthStore
+	Registration(context.Context, string, string, string, string, string) (int, error)
 	Login(context.Context, string, string, string, string) (int, error)
 	AddOrder(context.Context, int, string) (int, error)
+	AddOrders(context.Context, int, []string) (map[string]int, error)
 	GetOrders(context.Context, int) ([]byte, error)
 	GetUserBalance(context.Context, int) ([]byte, error)
-	AddWithdraw(context.Context, int, string, float32) (int, error)
+	AddWithdraw(context.Context, int, string, float32, float32, time.Duration) (int, error)
 	GetWithdraws(context.Context, int) ([]byte, error)
+	CancelWithdraw(context.Context, int, string) (int, error)
+	RefundWithdraw(context.Context, string) (int, error)
+	AddHold(context.Context, int, string, float32, float32, time.Duration) ([]byte, int, error)
+	CaptureHold(context.Context, int, string, time.Duration) (int, error)
+	ReleaseHold(context.Context, int, string) (int, error)
+	ExpireHolds(context.Context) (int64, error)
+	AddTransfer(context.Context, int, string, float32, time.Duration) ([]byte, int, error)
+	ConfirmTransfer(context.Context, int, int, float32, int) (int, error)
+	GetHistory(context.Context, int) ([]byte, error)
+	ExpirePoints(context.Context) (int64, error)
+	GetUserTier(context.Context, int) ([]byte, error)
+	RecalculateTiers(context.Context) (int64, error)
+	RedeemPromo(context.Context, int, string) ([]byte, int, error)
+	GetReferrals(context.Context, int) ([]byte, error)
 	Close() error
 	IsUniqueViolation(error) bool
+	IsReferralNotFound(error) bool
 }
 
+// LoginPassword Модель для отправки логина и пароля пользователя
+// @description Модель для отправки логина и пароля пользователя
 type LoginPassword struct {
-	Login    string `json:"login"`
-	Password string `json:"password"`
+	Login    string `json:"login"`              // Логин пользователя
+	Password string `json:"password"`           // Пароль пользователя
+	Referral string `json:"referral,omitempty"` // Реферальный код пригласившего пользователя (при регистрации)
 }
 
 type Withdraw struct {
-	Order string  `json:"order"`
-	Sum   float32 `json:"sum"`
+	Order      string  `json:"order"`
+	Sum        float32 `json:"sum"`
+	OrderTotal float32 `json:"order_total,omitempty"` // Сумма заказа, обязательна при ограничении доли оплаты баллами
+}
+
+// Results of order number registration in bulk upload.
+const (
+	orderAccepted       = "accepted"
+	orderAlreadyYours   = "already_yours"
+	orderOwnedByAnother = "owned_by_another"
+	orderInvalid        = "invalid"
+	orderError          = "error"
+)
+
+// OrderResult Результат регистрации номера заказа при пакетной загрузке
+type OrderResult struct {
+	Number string `json:"number"` // Номер заказа
+	Status string `json:"status"` // Результат: accepted, already_yours, owned_by_another, invalid или error
+	Code   int    `json:"code"`   // Код ответа, как у запроса регистрации одного заказа
+}
+
+// Transfer Модель перевода баллов другому пользователю
+type Transfer struct {
+	Login string  `json:"login"` // Логин получателя
+	Sum   float32 `json:"sum"`   // Сумма перевода
+}
+
+// Promo Модель активации промокода
+type Promo struct {
+	Code string `json:"code"` // Промокод
+}
+
+// transferLimits are daily limits of user transfers. Zero values mean no limit.
+type transferLimits struct {
+	sum   float32
+	count int
 }
 
 func isValidateLoginPassword(body []byte) (*LoginPassword, error) {
@@ -49,28 +117,20 @@ func isValidateLoginPassword(body []byte) (*LoginPassword, error) {
 	return &user, nil
 }
 
-func checkOrderNumber(order string) error {
-	initPosition := 0
-	if len(order)%2 > 0 {
-		initPosition = 1
-	}
-	summ := 0
-	for i := 0; i < len(order); i++ {
-		value, err := strconv.Atoi(fmt.Sprintf("%c", order[i]))
-		if err != nil {
-			return fmt.Errorf("order error in position %d: %w", i, err)
-		}
-		if initPosition == i {
-			initPosition += 2
-			summ += (2 * value) % 9 //nolint:gomnd // <- algoritm constants
+// readRequestBody reads body and writes 413 status if body size limit exceeded.
+func readRequestBody(w http.ResponseWriter, r *http.Request, logger *zap.SugaredLogger) ([]byte, bool) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
 		} else {
-			summ += value
+			w.WriteHeader(http.StatusInternalServerError)
 		}
+		logger.Warnf(readRequestErrorString, err)
+		return nil, false
 	}
-	if summ%10 == 0 {
-		return nil
-	}
-	return fmt.Errorf("order control summ error. Order: '%s'", order)
+	return body, true
 }
 
 // Register ...
@@ -81,8 +141,10 @@ func checkOrderNumber(order string) error {
 // @Router /user/register [post]
 // @Success 200 "Успешная регистрация пользователя"
 // @Header 200 {string} Authorization "Токен авторизации"
-// @failure 400 "Ошибка в теле запроса. Тело запроса не соответствует json формату"
+// @failure 400 "Ошибка в теле запроса. Тело запроса не соответствует json формату или реферальный код не найден"
 // @failure 409 "Такой логин уже используется другим пользователем"
+// @failure 413 "Превышен размер тела запроса"
+// @failure 429 "Превышено ограничение частоты запросов"
 // @failure 500 "Внутренняя ошибка сервиса".
 func Register(ctx context.Context, body, key []byte, remoteAddr, ua string,
 	strg Storage, tokenLiveTime int) (string, int, error) {
@@ -94,16 +156,19 @@ func Register(ctx context.Context, body, key []byte, remoteAddr, ua string,
 	if err != nil {
 		return "", http.StatusBadRequest, fmt.Errorf(incorrectIPErroString, err)
 	}
-	uid, err := strg.Registration(ctx, user.Login, user.Password, ua, ip)
+	uid, err := strg.Registration(ctx, user.Login, user.Password, ua, ip, user.Referral)
 	if err != nil {
 		status := http.StatusInternalServerError
 		err = fmt.Errorf(gormError, err)
 		if strg.IsUniqueViolation(err) {
 			status = http.StatusConflict
 			err = fmt.Errorf("user registrating duplicate error: '%s'", user.Login)
+		} else if strg.IsReferralNotFound(err) {
+			status = http.StatusBadRequest
 		}
 		return "", status, err
 	}
+	metrics.Registrations.Inc()
 	token, err := middlewares.CreateToken(key, tokenLiveTime, uid, ua, ip)
 	if err != nil {
 		return "", http.StatusInternalServerError, fmt.Errorf(tokenGenerateError, err)
@@ -121,6 +186,8 @@ func Register(ctx context.Context, body, key []byte, remoteAddr, ua string,
 // @Header 200 {string} Authorization "Токен авторизации"
 // @failure 400 "Ошибка в теле запроса. Тело запроса не соответствует json формату"
 // @failure 401 "Логин или пароль не найден"
+// @failure 413 "Превышен размер тела запроса"
+// @failure 429 "Превышено ограничение частоты запросов"
 // @failure 500 "Внутренняя ошибка сервиса".
 func Login(ctx context.Context, body, key []byte, remoteAddr, ua string,
 	strg Storage, tokenLiveTime int) (string, int, error) {
@@ -154,19 +221,20 @@ func Login(ctx context.Context, body, key []byte, remoteAddr, ua string,
 // @Param order body string true "Номер заказа"
 // @Security ApiKeyAuth
 // @Param Authorization header string false "Токен авторизации"
+// @Param Idempotency-Key header string false "Ключ идемпотентности. Повтор запроса с ключом возвращает первый ответ"
 // @Router /user/orders [post]
 // @Success 200 "Заказ уже был добавлен пользователем ранее"
 // @Success 202 "Заказ успешно зарегистрирован за пользователем"
 // @failure 400 "Ошибка в теле запроса. Тело запроса пустое"
 // @failure 401 "Пользователь не авторизован"
-// @failure 409 "Заказ зарегистрирован за другим пользователем"
-// @failure 422 "Номер заказа не прошёл проверку подлинности"
+// @failure 409 "Заказ зарегистрирован за другим пользователем или запрос с ключом идемпотентности ещё выполняется"
+// @failure 422 "Номер заказа не прошёл проверку подлинности или ключ идемпотентности использован с другим запросом"
+// @failure 413 "Превышен размер тела запроса"
+// @failure 429 "Превышено ограничение частоты запросов"
 // @failure 500 "Внутренняя ошибка сервиса".
-func AddOrder(args requestResponce) {
-	body, err := io.ReadAll(args.r.Body)
-	if err != nil {
-		args.w.WriteHeader(http.StatusInternalServerError)
-		args.logger.Warnf(readRequestErrorString, err)
+func AddOrder(args requestResponce, validator OrderValidator) {
+	body, ok := readRequestBody(args.w, args.r, args.logger)
+	if !ok {
 		return
 	}
 	defer args.r.Body.Close() //nolint:errcheck // <-senselessly
@@ -175,10 +243,11 @@ func AddOrder(args requestResponce) {
 		args.logger.Warnln("empty add order request's body")
 		return
 	}
-	err = checkOrderNumber(string(body))
+	trace.SpanFromContext(args.r.Context()).SetAttributes(tracing.OrderNumberKey.String(string(body)))
+	number, err := checkOrder(validator, string(body))
 	if err != nil {
 		args.w.WriteHeader(http.StatusUnprocessableEntity)
-		args.logger.Warnf("check order error: %w", err)
+		args.logger.Warnf("check order error: %v", err)
 		return
 	}
 	uid, ok := args.r.Context().Value(middlewares.AuthUID).(int)
@@ -187,13 +256,155 @@ func AddOrder(args requestResponce) {
 		args.logger.Warnln(uidContextTypeError)
 		return
 	}
-	status, err := args.strg.AddOrder(args.r.Context(), uid, string(body))
+	status, err := args.strg.AddOrder(args.r.Context(), uid, number)
 	if err != nil {
-		args.logger.Warnf("add order error: %w", err)
+		args.logger.Warnf("add order error: %v", err)
+	}
+	if status == http.StatusAccepted {
+		metrics.OrdersAccepted.Inc()
 	}
 	args.w.WriteHeader(status)
 }
 
+// AddOrders ...
+// @Tags Заказы
+// @Summary Пакетная загрузка номеров заказов пользователя
+// @Description Номера передаются json массивом строк или текстом, по одному номеру в строке.
+// @Description Результат возвращается для каждого номера в порядке запроса.
+// @Description Номера регистрируются пачками, каждая пачка в отдельной транзакции.
+// @Description При ошибке хранилища после сохранения части пачек ответ 500 содержит результаты номеров,
+// @Description номера, не зарегистрированные до ошибки, возвращаются со статусом error.
+// @Accept json,plain
+// @Produce json
+// @Param orders body []string true "Номера заказов"
+// @Security ApiKeyAuth
+// @Param Authorization header string false "Токен авторизации"
+// @Param Idempotency-Key header string false "Ключ идемпотентности. Повтор запроса с ключом возвращает первый ответ"
+// @Router /user/orders/bulk [post]
+// @Success 207 {array} OrderResult "Результаты регистрации номеров"
+// @failure 400 "Ошибка в теле запроса. Тело запроса пустое или не соответствует формату"
+// @failure 401 "Пользователь не авторизован"
+// @failure 409 "Запрос с ключом идемпотентности ещё выполняется"
+// @failure 422 "Ключ идемпотентности использован с другим запросом"
+// @failure 413 "Превышен размер тела запроса"
+// @failure 429 "Превышено ограничение частоты запросов"
+// @failure 500 {array} OrderResult "Внутренняя ошибка сервиса. Если часть пачек сохранена - результаты номеров".
+func AddOrders(args requestResponce, validator OrderValidator) {
+	body, ok := readRequestBody(args.w, args.r, args.logger)
+	if !ok {
+		return
+	}
+	numbers, err := readOrderNumbers(args.r.Header.Get(contentTypeString), body)
+	if err != nil {
+		args.w.WriteHeader(http.StatusBadRequest)
+		args.logger.Warnf("read orders error: %v", err)
+		return
+	}
+	uid, ok := args.r.Context().Value(middlewares.AuthUID).(int)
+	if !ok {
+		args.w.WriteHeader(http.StatusUnauthorized)
+		args.logger.Warnln(uidContextTypeError)
+		return
+	}
+	results := make([]OrderResult, len(numbers))
+	valid := make([]string, 0, len(numbers))
+	// repeats of number in request are registered once
+	repeats := make(map[string]bool, len(numbers))
+	for i, number := range numbers {
+		results[i].Number = number
+		normalized, err := checkOrder(validator, number)
+		if err != nil {
+			results[i].Status, results[i].Code = orderInvalid, http.StatusUnprocessableEntity
+			continue
+		}
+		results[i].Number = normalized
+		if _, ok := repeats[normalized]; !ok {
+			repeats[normalized] = false
+			valid = append(valid, normalized)
+		}
+	}
+	statuses := map[string]int{}
+	// Response with error is not saved for idempotency key, so request can be retried with the same key.
+	status := http.StatusMultiStatus
+	if len(valid) > 0 {
+		statuses, err = args.strg.AddOrders(args.r.Context(), uid, valid)
+		if err != nil {
+			args.logger.Warnf("add orders error: %v", err)
+			if len(statuses) == 0 {
+				args.w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			status = http.StatusInternalServerError
+		}
+	}
+	accepted := 0
+	for i := range results {
+		if results[i].Status == orderInvalid {
+			continue
+		}
+		code, ok := statuses[results[i].Number]
+		switch {
+		case !ok:
+			code = http.StatusInternalServerError
+		case repeats[results[i].Number] && code == http.StatusAccepted:
+			code = http.StatusOK
+		}
+		repeats[results[i].Number] = true
+		results[i].Code, results[i].Status = code, orderResult(code)
+		if code == http.StatusAccepted {
+			accepted++
+		}
+	}
+	metrics.OrdersAccepted.Add(float64(accepted))
+	data, err := json.Marshal(results)
+	if err != nil {
+		args.w.WriteHeader(http.StatusInternalServerError)
+		args.logger.Warnf("convert orders results to json error: %v", err)
+		return
+	}
+	args.w.Header().Add(contentTypeString, ctApplicationJSONString)
+	args.w.WriteHeader(status)
+	if _, err = args.w.Write(data); err != nil {
+		args.logger.Warnf(writeResponceErrorString, err)
+	}
+}
+
+// readOrderNumbers returns order numbers from json array or from text lines. Empty lines are skipped.
+func readOrderNumbers(contentType string, body []byte) ([]string, error) {
+	var numbers []string
+	if mediaType, _, _ := mime.ParseMediaType(contentType); mediaType == ctApplicationJSONString {
+		if err := json.Unmarshal(body, &numbers); err != nil {
+			return nil, fmt.Errorf("body convert to json error: %w", err)
+		}
+	} else {
+		numbers = strings.Split(string(body), "\n")
+	}
+	orders := make([]string, 0, len(numbers))
+	for _, number := range numbers {
+		if number = strings.TrimSpace(number); number != "" {
+			orders = append(orders, number)
+		}
+	}
+	if len(orders) == 0 {
+		return nil, errors.New("orders list is empty")
+	}
+	return orders, nil
+}
+
+// orderResult returns bulk upload result name of order status.
+func orderResult(status int) string {
+	switch status {
+	case http.StatusAccepted:
+		return orderAccepted
+	case http.StatusOK:
+		return orderAlreadyYours
+	case http.StatusInternalServerError:
+		return orderError
+	default:
+		return orderOwnedByAnother
+	}
+}
+
 func getListCommon(args *requestResponce, name string, f func(context.Context, int) ([]byte, error)) {
 	args.logger.Debugf("%s list request", name)
 	args.w.Header().Add(contentTypeString, ctApplicationJSONString)
@@ -206,7 +417,7 @@ func getListCommon(args *requestResponce, name string, f func(context.Context, i
 	data, err := f(args.r.Context(), uid)
 	if err != nil {
 		args.w.WriteHeader(http.StatusInternalServerError)
-		args.logger.Warnf("%s get list error: %w", name, err)
+		args.logger.Warnf("%s get list error: %v", name, err)
 		return
 	}
 	if data == nil {
@@ -231,6 +442,7 @@ func getListCommon(args *requestResponce, name string, f func(context.Context, i
 // @Success 200 {array} storage.Orders "Список зарегистрированных за пользователем заказов"
 // @failure 204 "Нет данных для ответа"
 // @failure 401 "Пользователь не авторизован"
+// @failure 429 "Превышено ограничение частоты запросов"
 // @failure 500 "Внутренняя ошибка сервиса".
 func GetOrdersList(args requestResponce) {
 	getListCommon(&args, "orders", args.strg.GetOrders)
@@ -245,6 +457,7 @@ func GetOrdersList(args requestResponce) {
 // @Router /user/balance [get]
 // @Success 200 {object} storage.BalanceStruct "Баланс пользователя"
 // @failure 401 "Пользователь не авторизован"
+// @failure 429 "Превышено ограничение частоты запросов"
 // @failure 500 "Внутренняя ошибка сервиса".
 func GetUserBalance(args requestResponce) {
 	args.logger.Debug("user balance request")
@@ -257,7 +470,7 @@ func GetUserBalance(args requestResponce) {
 	data, err := args.strg.GetUserBalance(args.r.Context(), uid)
 	if err != nil {
 		args.w.WriteHeader(http.StatusInternalServerError)
-		args.logger.Warnf("get user balance error: %w", err)
+		args.logger.Warnf("get user balance error: %v", err)
 		return
 	}
 	args.w.Header().Add(contentTypeString, ctApplicationJSONString)
@@ -274,47 +487,83 @@ func GetUserBalance(args requestResponce) {
 // @Param withdraw body Withdraw true "Номер заказа в счет которого списываются баллы"
 // @Security ApiKeyAuth
 // @Param Authorization header string false "Токен авторизации"
+// @Param Idempotency-Key header string false "Ключ идемпотентности. Повтор запроса с ключом возвращает первый ответ"
 // @Router /user/balance/withdraw [post]
-// @Success 200 "Списание успешно добавлено"
-// @failure 400 "Ошибка в теле запроса. Тело запроса не соответствует формату json"
+// @Success 200 "Списание успешно добавлено. Списание можно отменить в течение окна отмены"
+// @failure 400 {object} problem.Problem "Ошибка в теле запроса или нет суммы заказа (withdraw-order-total-required)"
 // @failure 401 "Пользователь не авторизован"
-// @failure 402 "Недостаточно средств"
-// @failure 409 "Заказ уже был зарегистрирован ранее"
-// @failure 422 "Номер заказа не прошёл проверку подлинности"
+// @failure 402 {object} problem.Problem "Недостаточно средств или баллы ещё не доступны (withdraw-cooling-off)"
+// @failure 403 {object} problem.Problem "Превышен лимит списаний (withdraw-daily-limit, withdraw-monthly-limit)"
+// @failure 409 "По заказу уже есть списание или активный резерв, или запрос с ключом идемпотентности ещё выполняется"
+// @failure 422 {object} problem.Problem "Неверный номер заказа, ключ идемпотентности, сумма (withdraw-below-min, ...)"
+// @failure 413 "Превышен размер тела запроса"
+// @failure 429 "Превышено ограничение частоты запросов"
 // @failure 500 "Внутренняя ошибка сервиса".
-func AddWithdraw(args requestResponce) {
-	body, err := io.ReadAll(args.r.Body)
+func AddWithdraw(args requestResponce, cancelWindow time.Duration, validator OrderValidator) {
+	withdraw, uid, ok := readWithdraw(&args, validator)
+	if !ok {
+		return
+	}
+	status, err := args.strg.AddWithdraw(args.r.Context(), uid, withdraw.Order, withdraw.Sum, withdraw.OrderTotal,
+		cancelWindow)
 	if err != nil {
-		args.w.WriteHeader(http.StatusInternalServerError)
-		args.logger.Warnf("body read error: %w", err)
+		args.logger.Warnf("add withdraw error: %v", err)
+	}
+	args.logger.Debugf("add withdraw status: %d \n", status)
+	if status == http.StatusOK {
+		metrics.PointsWithdrawn.Add(float64(withdraw.Sum))
+	}
+	writeStatus(&args, status, err)
+}
+
+// writeStatus writes problem details if err is withdrawal rule violation or status only otherwise.
+func writeStatus(args *requestResponce, status int, err error) {
+	p, ok := problem.As(err)
+	if !ok {
+		args.w.WriteHeader(status)
 		return
 	}
+	metrics.WithdrawRuleViolations.WithLabelValues(p.Code).Inc()
+	if err = problem.Write(args.w, p); err != nil {
+		args.logger.Warnf(writeResponceErrorString, err)
+	}
+}
+
+// readWithdraw reads and checks order number and sum of withdraw or hold request.
+// Writes response status and returns false if request is incorrect.
+func readWithdraw(args *requestResponce, validator OrderValidator) (*Withdraw, int, bool) {
+	body, ok := readRequestBody(args.w, args.r, args.logger)
+	if !ok {
+		return nil, 0, false
+	}
 	var withdraw Withdraw
-	err = json.Unmarshal(body, &withdraw)
+	err := json.Unmarshal(body, &withdraw)
 	if err != nil {
 		args.w.WriteHeader(http.StatusBadRequest)
-		args.logger.Warnf("convert to json error: %w", err)
-		return
+		args.logger.Warnf("convert to json error: %v", err)
+		return nil, 0, false
 	}
-	args.logger.Debugf("add withdraw request %s: %f", withdraw.Order, withdraw.Sum)
-	err = checkOrderNumber(withdraw.Order)
+	args.logger.Debugf("withdraw request %s: %f", withdraw.Order, withdraw.Sum)
+	if withdraw.Sum <= 0 || withdraw.OrderTotal < 0 {
+		args.w.WriteHeader(http.StatusBadRequest)
+		args.logger.Warnf("withdraw sum or order total incorrect: %f, %f", withdraw.Sum, withdraw.OrderTotal)
+		return nil, 0, false
+	}
+	trace.SpanFromContext(args.r.Context()).SetAttributes(tracing.OrderNumberKey.String(withdraw.Order))
+	order, err := checkOrder(validator, withdraw.Order)
 	if err != nil {
 		args.w.WriteHeader(http.StatusUnprocessableEntity)
-		args.logger.Warnf("check order error", err)
-		return
+		args.logger.Warnf("check order error: %v", err)
+		return nil, 0, false
 	}
+	withdraw.Order = order
 	uid, ok := args.r.Context().Value(middlewares.AuthUID).(int)
 	if !ok {
 		args.w.WriteHeader(http.StatusUnauthorized)
 		args.logger.Warnln(uidContextTypeError)
-		return
+		return nil, 0, false
 	}
-	status, err := args.strg.AddWithdraw(args.r.Context(), uid, withdraw.Order, withdraw.Sum)
-	if err != nil {
-		args.logger.Warnf("add withdraw error: %w", err)
-	}
-	args.logger.Debugf("add withdraw status: %d \n", status)
-	args.w.WriteHeader(status)
+	return &withdraw, uid, true
 }
 
 // GetWithdrawsList ...
@@ -324,10 +573,382 @@ func AddWithdraw(args requestResponce) {
 // @Router /user/withdrawals [get]
 // @Security ApiKeyAuth
 // @Param Authorization header string false "Токен авторизации"
-// @Success 200 {array} storage.Withdraws "Список списаний"
+// @Success 200 {array} storage.Withdraws "Список списаний со статусами PENDING, COMPLETED, CANCELLED, REFUNDED"
 // @failure 204 "Нет данных для ответа"
 // @failure 401 "Пользователь не авторизован"
+// @failure 429 "Превышено ограничение частоты запросов"
 // @failure 500 "Внутренняя ошибка сервиса".
 func GetWithdrawsList(args requestResponce) {
 	getListCommon(&args, "withdraws", args.strg.GetWithdraws)
 }
+
+// revertWithdrawCommon writes status of withdrawal cancel or refund.
+func revertWithdrawCommon(args *requestResponce, reason string, f func(string) (int, error)) {
+	order := chi.URLParam(args.r, "order")
+	trace.SpanFromContext(args.r.Context()).SetAttributes(tracing.OrderNumberKey.String(order))
+	status, err := f(order)
+	if err != nil {
+		args.logger.Warnf("%s withdraw error: %v", reason, err)
+	}
+	if status == http.StatusOK {
+		metrics.WithdrawsReverted.WithLabelValues(reason).Inc()
+	}
+	args.w.WriteHeader(status)
+}
+
+// CancelWithdraw ...
+// @Tags Списание баллов
+// @Summary Отмена списания баллов пользователем
+// @Param order path string true "Номер заказа списания"
+// @Security ApiKeyAuth
+// @Param Authorization header string false "Токен авторизации"
+// @Router /user/withdrawals/{order}/cancel [post]
+// @Success 200 "Списание отменено, баллы возвращены на баланс"
+// @failure 401 "Пользователь не авторизован"
+// @failure 404 "Списание не найдено"
+// @failure 409 "Списание не в статусе PENDING или окно отмены истекло"
+// @failure 429 "Превышено ограничение частоты запросов"
+// @failure 500 "Внутренняя ошибка сервиса".
+func CancelWithdraw(args requestResponce) {
+	uid, ok := args.r.Context().Value(middlewares.AuthUID).(int)
+	if !ok {
+		args.w.WriteHeader(http.StatusUnauthorized)
+		args.logger.Warnln(uidContextTypeError)
+		return
+	}
+	revertWithdrawCommon(&args, "cancel", func(order string) (int, error) {
+		return args.strg.CancelWithdraw(args.r.Context(), uid, order)
+	})
+}
+
+// RefundWithdraw returns withdrawn points to user balance. Used by partners through admin server.
+func RefundWithdraw(args requestResponce) {
+	revertWithdrawCommon(&args, "refund", func(order string) (int, error) {
+		return args.strg.RefundWithdraw(args.r.Context(), order)
+	})
+}
+
+// AddHold ...
+// @Tags Резервирование баллов
+// @Summary Резервирование баллов в счёт заказа
+// @Description Резерв уменьшает доступный баланс, но не текущий. Резерв без подтверждения или отмены истекает.
+// @Accept json
+// @Produce json
+// @Param hold body Withdraw true "Номер заказа и сумма резерва"
+// @Security ApiKeyAuth
+// @Param Authorization header string false "Токен авторизации"
+// @Param Idempotency-Key header string false "Ключ идемпотентности. Повтор запроса с ключом возвращает первый ответ"
+// @Router /user/balance/holds [post]
+// @Success 201 {object} storage.Holds "Резерв создан"
+// @failure 400 {object} problem.Problem "Ошибка в теле запроса или нет суммы заказа (withdraw-order-total-required)"
+// @failure 401 "Пользователь не авторизован"
+// @failure 402 {object} problem.Problem "Недостаточно доступных средств или баллы недоступны (withdraw-cooling-off)"
+// @failure 403 {object} problem.Problem "Превышен лимит списаний (withdraw-daily-limit, withdraw-monthly-limit)"
+// @failure 409 "По заказу уже есть резерв или списание, или запрос с ключом идемпотентности ещё выполняется"
+// @failure 422 {object} problem.Problem "Неверный номер заказа, ключ идемпотентности, сумма (withdraw-below-min, ...)"
+// @failure 413 "Превышен размер тела запроса"
+// @failure 429 "Превышено ограничение частоты запросов"
+// @failure 500 "Внутренняя ошибка сервиса".
+func AddHold(args requestResponce, ttl time.Duration, validator OrderValidator) {
+	hold, uid, ok := readWithdraw(&args, validator)
+	if !ok {
+		return
+	}
+	data, status, err := args.strg.AddHold(args.r.Context(), uid, hold.Order, hold.Sum, hold.OrderTotal, ttl)
+	if err != nil {
+		args.logger.Warnf("add hold error: %v", err)
+	}
+	if status != http.StatusCreated {
+		writeStatus(&args, status, err)
+		return
+	}
+	metrics.Holds.WithLabelValues("created").Inc()
+	args.w.Header().Add(contentTypeString, ctApplicationJSONString)
+	args.w.WriteHeader(status)
+	if _, err = args.w.Write(data); err != nil {
+		args.logger.Warnf(writeResponceErrorString, err)
+	}
+}
+
+// holdCommon writes status of hold capture or release.
+func holdCommon(args *requestResponce, action string, f func(int, string) (int, error)) {
+	uid, ok := args.r.Context().Value(middlewares.AuthUID).(int)
+	if !ok {
+		args.w.WriteHeader(http.StatusUnauthorized)
+		args.logger.Warnln(uidContextTypeError)
+		return
+	}
+	order := chi.URLParam(args.r, "order")
+	trace.SpanFromContext(args.r.Context()).SetAttributes(tracing.OrderNumberKey.String(order))
+	status, err := f(uid, order)
+	if err != nil {
+		args.logger.Warnf("%s hold error: %v", action, err)
+	}
+	if status == http.StatusOK {
+		metrics.Holds.WithLabelValues(action).Inc()
+	}
+	args.w.WriteHeader(status)
+}
+
+// CaptureHold ...
+// @Tags Резервирование баллов
+// @Summary Подтверждение резерва и списание баллов
+// @Param order path string true "Номер заказа резерва"
+// @Security ApiKeyAuth
+// @Param Authorization header string false "Токен авторизации"
+// @Router /user/balance/holds/{order}/capture [post]
+// @Success 200 "Резерв переведён в списание"
+// @failure 401 "Пользователь не авторизован"
+// @failure 404 "Активный резерв не найден"
+// @failure 409 "Резерв истёк или списание по заказу уже зарегистрировано"
+// @failure 429 "Превышено ограничение частоты запросов"
+// @failure 500 "Внутренняя ошибка сервиса".
+func CaptureHold(args requestResponce, cancelWindow time.Duration) {
+	holdCommon(&args, "captured", func(uid int, order string) (int, error) {
+		return args.strg.CaptureHold(args.r.Context(), uid, order, cancelWindow)
+	})
+}
+
+// ReleaseHold ...
+// @Tags Резервирование баллов
+// @Summary Отмена резерва баллов
+// @Param order path string true "Номер заказа резерва"
+// @Security ApiKeyAuth
+// @Param Authorization header string false "Токен авторизации"
+// @Router /user/balance/holds/{order}/release [post]
+// @Success 200 "Резерв отменён, баллы доступны для списания"
+// @failure 401 "Пользователь не авторизован"
+// @failure 404 "Активный резерв не найден"
+// @failure 429 "Превышено ограничение частоты запросов"
+// @failure 500 "Внутренняя ошибка сервиса".
+func ReleaseHold(args requestResponce) {
+	holdCommon(&args, "released", func(uid int, order string) (int, error) {
+		return args.strg.ReleaseHold(args.r.Context(), uid, order)
+	})
+}
+
+// AddTransfer ...
+// @Tags Переводы баллов
+// @Summary Создание перевода баллов другому пользователю
+// @Description Перевод выполняется после подтверждения запросом /user/balance/transfer/{id}/confirm.
+// @Accept json
+// @Produce json
+// @Param transfer body Transfer true "Логин получателя и сумма перевода"
+// @Security ApiKeyAuth
+// @Param Authorization header string false "Токен авторизации"
+// @Param Idempotency-Key header string false "Ключ идемпотентности. Повтор запроса с ключом возвращает первый ответ"
+// @Router /user/balance/transfer [post]
+// @Success 201 {object} storage.Transfers "Перевод ожидает подтверждения"
+// @failure 400 "Ошибка в теле запроса, сумма не положительная или перевод самому себе"
+// @failure 401 "Пользователь не авторизован"
+// @failure 402 "Недостаточно доступных средств"
+// @failure 404 "Получатель не найден"
+// @failure 409 "Запрос с ключом идемпотентности ещё выполняется"
+// @failure 422 "Ключ идемпотентности использован с другим запросом"
+// @failure 413 "Превышен размер тела запроса"
+// @failure 429 "Превышено ограничение частоты запросов"
+// @failure 500 "Внутренняя ошибка сервиса".
+func AddTransfer(args requestResponce, ttl time.Duration) {
+	body, ok := readRequestBody(args.w, args.r, args.logger)
+	if !ok {
+		return
+	}
+	var transfer Transfer
+	if err := json.Unmarshal(body, &transfer); err != nil || transfer.Login == "" || transfer.Sum <= 0 {
+		args.w.WriteHeader(http.StatusBadRequest)
+		args.logger.Warnf("incorrect transfer request: %v", err)
+		return
+	}
+	uid, ok := args.r.Context().Value(middlewares.AuthUID).(int)
+	if !ok {
+		args.w.WriteHeader(http.StatusUnauthorized)
+		args.logger.Warnln(uidContextTypeError)
+		return
+	}
+	data, status, err := args.strg.AddTransfer(args.r.Context(), uid, transfer.Login, transfer.Sum, ttl)
+	if err != nil {
+		args.logger.Warnf("add transfer error: %v", err)
+	}
+	if status != http.StatusCreated {
+		args.w.WriteHeader(status)
+		return
+	}
+	args.w.Header().Add(contentTypeString, ctApplicationJSONString)
+	args.w.WriteHeader(status)
+	if _, err = args.w.Write(data); err != nil {
+		args.logger.Warnf(writeResponceErrorString, err)
+	}
+}
+
+// ConfirmTransfer ...
+// @Tags Переводы баллов
+// @Summary Подтверждение перевода баллов
+// @Param id path int true "Идентификатор перевода"
+// @Security ApiKeyAuth
+// @Param Authorization header string false "Токен авторизации"
+// @Router /user/balance/transfer/{id}/confirm [post]
+// @Success 200 "Перевод выполнен"
+// @failure 400 "Некорректный идентификатор перевода"
+// @failure 401 "Пользователь не авторизован"
+// @failure 402 "Недостаточно доступных средств"
+// @failure 403 "Превышен дневной лимит переводов"
+// @failure 404 "Ожидающий подтверждения перевод не найден"
+// @failure 409 "Время подтверждения перевода истекло"
+// @failure 429 "Превышено ограничение частоты запросов"
+// @failure 500 "Внутренняя ошибка сервиса".
+func ConfirmTransfer(args requestResponce, limits transferLimits) {
+	id, err := strconv.Atoi(chi.URLParam(args.r, "id"))
+	if err != nil {
+		args.w.WriteHeader(http.StatusBadRequest)
+		args.logger.Warnf("incorrect transfer id: %v", err)
+		return
+	}
+	uid, ok := args.r.Context().Value(middlewares.AuthUID).(int)
+	if !ok {
+		args.w.WriteHeader(http.StatusUnauthorized)
+		args.logger.Warnln(uidContextTypeError)
+		return
+	}
+	status, err := args.strg.ConfirmTransfer(args.r.Context(), uid, id, limits.sum, limits.count)
+	if err != nil {
+		args.logger.Warnf("confirm transfer error: %v", err)
+	}
+	args.w.WriteHeader(status)
+}
+
+// GetHistoryList ...
+// @Tags Баланс пользователя
+// @Summary Запрос истории изменений баланса
+// @Description Начисления по заказам, списания, переводы и сгорание баллов, отсортированные по времени.
+// @Produce json
+// @Router /user/history [get]
+// @Security ApiKeyAuth
+// @Param Authorization header string false "Токен авторизации"
+// @Success 200 {array} storage.HistoryItem "История изменений баланса"
+// @failure 204 "Нет данных для ответа"
+// @failure 401 "Пользователь не авторизован"
+// @failure 429 "Превышено ограничение частоты запросов"
+// @failure 500 "Внутренняя ошибка сервиса".
+func GetHistoryList(args requestResponce) {
+	getListCommon(&args, "history", args.strg.GetHistory)
+}
+
+// GetUserTier ...
+// @Tags Баланс пользователя
+// @Summary Запрос уровня пользователя
+// @Description Текущий уровень, множитель начислений и прогресс до следующего уровня за расчётный период.
+// @Produce json
+// @Security ApiKeyAuth
+// @Param Authorization header string false "Токен авторизации"
+// @Router /user/tier [get]
+// @Success 200 {object} storage.TierStruct "Уровень пользователя"
+// @failure 401 "Пользователь не авторизован"
+// @failure 429 "Превышено ограничение частоты запросов"
+// @failure 500 "Внутренняя ошибка сервиса".
+func GetUserTier(args requestResponce) {
+	args.logger.Debug("user tier request")
+	uid, ok := args.r.Context().Value(middlewares.AuthUID).(int)
+	if !ok {
+		args.w.WriteHeader(http.StatusUnauthorized)
+		args.logger.Warnln(uidContextTypeError)
+		return
+	}
+	data, err := args.strg.GetUserTier(args.r.Context(), uid)
+	if err != nil {
+		args.w.WriteHeader(http.StatusInternalServerError)
+		args.logger.Warnf("get user tier error: %v", err)
+		return
+	}
+	args.w.Header().Add(contentTypeString, ctApplicationJSONString)
+	_, err = args.w.Write(data)
+	if err != nil {
+		args.logger.Warnf(writeResponceErrorString, err)
+	}
+}
+
+// RedeemPromo ...
+// @Tags Баланс пользователя
+// @Summary Активация промокода
+// @Description Начисляет бонус кампании промокода. Каждый пользователь может активировать код один раз.
+// @Accept json
+// @Produce json
+// @Param promo body Promo true "Промокод"
+// @Security ApiKeyAuth
+// @Param Authorization header string false "Токен авторизации"
+// @Router /user/promo [post]
+// @Success 200 {object} storage.PromoResult "Бонус начислен"
+// @failure 400 "Ошибка в теле запроса"
+// @failure 401 "Пользователь не авторизован"
+// @failure 403 "Кампания промокода не предназначена для пользователя"
+// @failure 404 "Промокод не найден"
+// @failure 409 "Промокод уже активирован пользователем"
+// @failure 410 "Промокод израсходован, кампания не активна или её бюджет исчерпан"
+// @failure 413 "Превышен размер тела запроса"
+// @failure 429 "Превышено ограничение частоты запросов"
+// @failure 500 "Внутренняя ошибка сервиса".
+func RedeemPromo(args requestResponce) {
+	body, ok := readRequestBody(args.w, args.r, args.logger)
+	if !ok {
+		return
+	}
+	var promo Promo
+	if err := json.Unmarshal(body, &promo); err != nil || strings.TrimSpace(promo.Code) == "" {
+		args.w.WriteHeader(http.StatusBadRequest)
+		args.logger.Warnf("incorrect promo request: %v", err)
+		return
+	}
+	uid, ok := args.r.Context().Value(middlewares.AuthUID).(int)
+	if !ok {
+		args.w.WriteHeader(http.StatusUnauthorized)
+		args.logger.Warnln(uidContextTypeError)
+		return
+	}
+	data, status, err := args.strg.RedeemPromo(args.r.Context(), uid, strings.TrimSpace(promo.Code))
+	if err != nil {
+		args.logger.Warnf("redeem promo error: %v", err)
+	}
+	metrics.PromoRedemptions.WithLabelValues(strconv.Itoa(status)).Inc()
+	if status != http.StatusOK {
+		args.w.WriteHeader(status)
+		return
+	}
+	args.w.Header().Add(contentTypeString, ctApplicationJSONString)
+	args.w.WriteHeader(status)
+	if _, err = args.w.Write(data); err != nil {
+		args.logger.Warnf(writeResponceErrorString, err)
+	}
+}
+
+// GetReferrals ...
+// @Tags Баланс пользователя
+// @Summary Запрос реферального кода и приглашённых пользователей
+// @Description Статус приглашения: PENDING - ожидает первого обработанного заказа, REWARDED - бонус начислен,
+// @Description REJECTED - отклонено (reason: self, same_ip, cap, blocked).
+// @Produce json
+// @Security ApiKeyAuth
+// @Param Authorization header string false "Токен авторизации"
+// @Router /user/referrals [get]
+// @Success 200 {object} storage.ReferralsStruct "Реферальный код и приглашённые пользователи"
+// @failure 401 "Пользователь не авторизован"
+// @failure 429 "Превышено ограничение частоты запросов"
+// @failure 500 "Внутренняя ошибка сервиса".
+func GetReferrals(args requestResponce) {
+	args.logger.Debug("user referrals request")
+	uid, ok := args.r.Context().Value(middlewares.AuthUID).(int)
+	if !ok {
+		args.w.WriteHeader(http.StatusUnauthorized)
+		args.logger.Warnln(uidContextTypeError)
+		return
+	}
+	data, err := args.strg.GetReferrals(args.r.Context(), uid)
+	if err != nil {
+		args.w.WriteHeader(http.StatusInternalServerError)
+		args.logger.Warnf("get user referrals error: %v", err)
+		return
+	}
+	args.w.Header().Add(contentTypeString, ctApplicationJSONString)
+	_, err = args.w.Write(data)
+	if err != nil {
+		args.logger.Warnf(writeResponceErrorString, err)
+	}
+}
@@ -5,12 +5,19 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/go-chi/chi"
 	"github.com/golang/mock/gomock"
 	"github.com/gostuding/goMarket/internal/mocks"
+	"github.com/gostuding/goMarket/internal/problem"
+	"github.com/gostuding/goMarket/internal/server/middlewares"
 	"github.com/jackc/pgerrcode"
 	"github.com/jackc/pgx/v5/pgconn"
+	"go.uber.org/zap"
 	"gorm.io/gorm"
 )
 
@@ -34,11 +41,16 @@ func TestRegister(t *testing.T) {
 	ctx := context.Background()
 	unqueError := pgconn.PgError{Code: pgerrcode.UniqueViolation}
 	errDB := errors.New("database error")
-	m.EXPECT().Registration(ctx, "admin", gomock.Any(), "ua", "127.0.0.1").Return(uid, nil)
-	m.EXPECT().Registration(ctx, "repeat", gomock.Any(), "ua", "127.0.0.1").Return(0, &unqueError)
-	m.EXPECT().Registration(ctx, "user", gomock.Any(), "ua", "127.0.0.1").Return(0, errDB)
+	errReferral := errors.New("referral code not found")
+	m.EXPECT().Registration(ctx, "admin", gomock.Any(), "ua", "127.0.0.1", "").Return(uid, nil)
+	m.EXPECT().Registration(ctx, "repeat", gomock.Any(), "ua", "127.0.0.1", "").Return(0, &unqueError)
+	m.EXPECT().Registration(ctx, "user", gomock.Any(), "ua", "127.0.0.1", "").Return(0, errDB)
+	m.EXPECT().Registration(ctx, "friend", gomock.Any(), "ua", "127.0.0.1", "UNKNOWN").Return(0, errReferral)
 	m.EXPECT().IsUniqueViolation(fmt.Errorf("gorm error: %w", &unqueError)).Return(true)
 	m.EXPECT().IsUniqueViolation(fmt.Errorf("gorm error: %w", errDB)).Return(false)
+	m.EXPECT().IsReferralNotFound(fmt.Errorf("gorm error: %w", errDB)).Return(false)
+	m.EXPECT().IsUniqueViolation(fmt.Errorf("gorm error: %w", errReferral)).Return(false)
+	m.EXPECT().IsReferralNotFound(fmt.Errorf("gorm error: %w", errReferral)).Return(true)
 
 	type args struct {
 		body          []byte
@@ -112,6 +124,20 @@ func TestRegister(t *testing.T) {
 			want1:     http.StatusInternalServerError,
 			wantErr:   true,
 		},
+		{
+			name: "Неизвестный реферальный код",
+			args: args{
+				body:       []byte(`{"login": "friend", "password": "1", "referral": "UNKNOWN"}`),
+				key:        []byte("default"),
+				remoteAddr: "127.0.0.1:9000",
+				ua:         "ua",
+				strg:       m,
+			},
+			wantCheck: true,
+			want:      "",
+			want1:     http.StatusBadRequest,
+			wantErr:   true,
+		},
 		{
 			name: "Ошибка переданного ip",
 			args: args{
@@ -221,3 +247,258 @@ func TestLoginFunc(t *testing.T) {
 		})
 	}
 }
+
+func TestCancelWithdraw(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	m := mocks.NewMockStorage(ctrl)
+	m.EXPECT().CancelWithdraw(gomock.Any(), 1, "12345678903").Return(http.StatusOK, nil)
+	m.EXPECT().CancelWithdraw(gomock.Any(), 1, "2377225624").Return(http.StatusConflict, errors.New("expired"))
+	m.EXPECT().CancelWithdraw(gomock.Any(), 2, "12345678903").Return(http.StatusNotFound, errors.New("not found"))
+	tests := []struct {
+		name     string
+		order    string
+		uid      any
+		wantCode int
+	}{
+		{name: "Успешная отмена", order: "12345678903", uid: 1, wantCode: http.StatusOK},
+		{name: "Окно отмены истекло", order: "2377225624", uid: 1, wantCode: http.StatusConflict},
+		{name: "Списание другого пользователя", order: "12345678903", uid: 2, wantCode: http.StatusNotFound},
+		{name: "Пользователь не авторизован", order: "12345678903", uid: "1", wantCode: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("order", tt.order)
+			req := httptest.NewRequest(http.MethodPost, "/api/user/withdrawals/"+tt.order+"/cancel", nil)
+			ctx := context.WithValue(req.Context(), chi.RouteCtxKey, rctx)
+			req = req.WithContext(context.WithValue(ctx, middlewares.AuthUID, tt.uid))
+			w := httptest.NewRecorder()
+			CancelWithdraw(newRequestResponce(w, req, m, zap.NewNop().Sugar()))
+			if w.Code != tt.wantCode {
+				t.Errorf("CancelWithdraw() status = %d, want %d", w.Code, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestRefundWithdraw(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	m := mocks.NewMockStorage(ctrl)
+	m.EXPECT().RefundWithdraw(gomock.Any(), "12345678903").Return(http.StatusOK, nil)
+	m.EXPECT().RefundWithdraw(gomock.Any(), "2377225624").Return(http.StatusConflict, errors.New("cancelled"))
+	m.EXPECT().RefundWithdraw(gomock.Any(), "4561261212345467").Return(http.StatusNotFound, errors.New("not found"))
+	tests := []struct {
+		name     string
+		order    string
+		mTLS     bool
+		wantCode int
+	}{
+		{name: "Успешный возврат", order: "12345678903", mTLS: true, wantCode: http.StatusOK},
+		{name: "Списание уже отменено", order: "2377225624", mTLS: true, wantCode: http.StatusConflict},
+		{name: "Списание не найдено", order: "4561261212345467", mTLS: true, wantCode: http.StatusNotFound},
+		{name: "Возврат без mTLS отключён", order: "12345678903", wantCode: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := makeAdminRouter(zap.NewAtomicLevel(), m, zap.NewNop().Sugar(), tt.mTLS)
+			req := httptest.NewRequest(http.MethodPost, "/api/admin/withdrawals/"+tt.order+"/refund", nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			if w.Code != tt.wantCode {
+				t.Errorf("RefundWithdraw() status = %d, want %d", w.Code, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestAddHold(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	m := mocks.NewMockStorage(ctrl)
+	hold := []byte(`{"order":"12345678903","sum":10,"status":"ACTIVE"}`)
+	m.EXPECT().AddHold(gomock.Any(), 1, "12345678903", float32(10), float32(0), time.Minute).
+		Return(hold, http.StatusCreated, nil)
+	m.EXPECT().AddHold(gomock.Any(), 1, "2377225624", float32(500), float32(0), time.Minute).
+		Return(nil, http.StatusPaymentRequired, nil)
+	tests := []struct {
+		name     string
+		body     string
+		wantCode int
+		wantBody string
+	}{
+		{name: "Резерв создан", body: `{"order":"12345678903","sum":10}`, wantCode: http.StatusCreated,
+			wantBody: string(hold)},
+		{name: "Недостаточно средств", body: `{"order":"2377225624","sum":500}`,
+			wantCode: http.StatusPaymentRequired},
+		{name: "Отрицательная сумма", body: `{"order":"12345678903","sum":-1}`, wantCode: http.StatusBadRequest},
+		{name: "Некорректный номер", body: `{"order":"12345678904","sum":1}`,
+			wantCode: http.StatusUnprocessableEntity},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/user/balance/holds", strings.NewReader(tt.body))
+			req = req.WithContext(context.WithValue(req.Context(), middlewares.AuthUID, 1))
+			w := httptest.NewRecorder()
+			AddHold(newRequestResponce(w, req, m, zap.NewNop().Sugar()), time.Minute, luhnValidator{})
+			if w.Code != tt.wantCode || w.Body.String() != tt.wantBody {
+				t.Errorf("AddHold() = %d '%s', want %d '%s'", w.Code, w.Body.String(), tt.wantCode, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestConfirmTransfer(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	m := mocks.NewMockStorage(ctrl)
+	limits := transferLimits{sum: 1000, count: 3}
+	m.EXPECT().ConfirmTransfer(gomock.Any(), 1, 10, float32(1000), 3).Return(http.StatusOK, nil)
+	m.EXPECT().ConfirmTransfer(gomock.Any(), 1, 11, float32(1000), 3).
+		Return(http.StatusForbidden, errors.New("limit"))
+	tests := []struct {
+		name     string
+		id       string
+		wantCode int
+	}{
+		{name: "Перевод подтверждён", id: "10", wantCode: http.StatusOK},
+		{name: "Превышен лимит", id: "11", wantCode: http.StatusForbidden},
+		{name: "Некорректный идентификатор", id: "abc", wantCode: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("id", tt.id)
+			req := httptest.NewRequest(http.MethodPost, "/api/user/balance/transfer/"+tt.id+"/confirm", nil)
+			ctx := context.WithValue(req.Context(), chi.RouteCtxKey, rctx)
+			req = req.WithContext(context.WithValue(ctx, middlewares.AuthUID, 1))
+			w := httptest.NewRecorder()
+			ConfirmTransfer(newRequestResponce(w, req, m, zap.NewNop().Sugar()), limits)
+			if w.Code != tt.wantCode {
+				t.Errorf("ConfirmTransfer() status = %d, want %d", w.Code, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestRedeemPromo(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	m := mocks.NewMockStorage(ctrl)
+	result := []byte(`{"campaign":"welcome","code":"WELCOME","sum":500}`)
+	m.EXPECT().RedeemPromo(gomock.Any(), 1, "WELCOME").Return(result, http.StatusOK, nil)
+	m.EXPECT().RedeemPromo(gomock.Any(), 1, "USED").Return(nil, http.StatusGone, errors.New("used up"))
+	tests := []struct {
+		name     string
+		body     string
+		wantCode int
+		wantBody string
+	}{
+		{name: "Промокод активирован", body: `{"code":" WELCOME "}`, wantCode: http.StatusOK, wantBody: string(result)},
+		{name: "Промокод израсходован", body: `{"code":"USED"}`, wantCode: http.StatusGone},
+		{name: "Пустой промокод", body: `{"code":""}`, wantCode: http.StatusBadRequest},
+		{name: "Некорректное тело", body: `code`, wantCode: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/user/promo", strings.NewReader(tt.body))
+			req = req.WithContext(context.WithValue(req.Context(), middlewares.AuthUID, 1))
+			w := httptest.NewRecorder()
+			RedeemPromo(newRequestResponce(w, req, m, zap.NewNop().Sugar()))
+			if w.Code != tt.wantCode || w.Body.String() != tt.wantBody {
+				t.Errorf("RedeemPromo() = %d '%s', want %d '%s'", w.Code, w.Body.String(), tt.wantCode, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestAddWithdrawRules(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	m := mocks.NewMockStorage(ctrl)
+	limit := problem.New(http.StatusForbidden, "withdraw-daily-limit", "daily withdrawals limit exceeded")
+	m.EXPECT().AddWithdraw(gomock.Any(), 1, "12345678903", float32(10), float32(100), time.Minute).
+		Return(http.StatusOK, nil)
+	m.EXPECT().AddWithdraw(gomock.Any(), 1, "2377225624", float32(500), float32(0), time.Minute).
+		Return(http.StatusForbidden, limit)
+	tests := []struct {
+		name     string
+		body     string
+		wantCode int
+		wantType string
+		wantBody string
+	}{
+		{name: "Списание с суммой заказа", body: `{"order":"12345678903","sum":10,"order_total":100}`,
+			wantCode: http.StatusOK},
+		{name: "Превышен дневной лимит", body: `{"order":"2377225624","sum":500}`, wantCode: http.StatusForbidden,
+			wantType: problem.ContentType, wantBody: `"code":"withdraw-daily-limit"`},
+		{name: "Отрицательная сумма заказа", body: `{"order":"12345678903","sum":10,"order_total":-1}`,
+			wantCode: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/user/balance/withdraw", strings.NewReader(tt.body))
+			req = req.WithContext(context.WithValue(req.Context(), middlewares.AuthUID, 1))
+			w := httptest.NewRecorder()
+			AddWithdraw(newRequestResponce(w, req, m, zap.NewNop().Sugar()), time.Minute, luhnValidator{})
+			if w.Code != tt.wantCode || w.Header().Get(contentTypeString) != tt.wantType ||
+				!strings.Contains(w.Body.String(), tt.wantBody) {
+				t.Errorf("AddWithdraw() = %d %s '%s', want %d %s '%s'", w.Code, w.Header().Get(contentTypeString),
+					w.Body.String(), tt.wantCode, tt.wantType, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestAddOrders(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	m := mocks.NewMockStorage(ctrl)
+	m.EXPECT().AddOrders(gomock.Any(), 1, []string{"12345678903", "2377225624", "4561261212345467"}).
+		Return(map[string]int{"12345678903": http.StatusAccepted, "2377225624": http.StatusOK,
+			"4561261212345467": http.StatusConflict}, nil)
+	m.EXPECT().AddOrders(gomock.Any(), 1, []string{"4561261212345467"}).
+		Return(map[string]int{"4561261212345467": http.StatusAccepted}, nil)
+	m.EXPECT().AddOrders(gomock.Any(), 1, []string{"12345678903", "2377225624"}).
+		Return(map[string]int{"12345678903": http.StatusAccepted}, errors.New("storage error"))
+	m.EXPECT().AddOrders(gomock.Any(), 1, []string{"79927398713"}).Return(nil, errors.New("storage error"))
+	m.EXPECT().AddOrders(gomock.Any(), 1, []string{"2377225624"}).
+		Return(map[string]int{"2377225624": http.StatusAccepted}, nil)
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		wantCode    int
+		wantBody    string
+	}{
+		{name: "Массив json", contentType: ctApplicationJSONString,
+			body: `["12345678903","2377225624","4561261212345467","12345678904"]`, wantCode: http.StatusMultiStatus,
+			wantBody: `[{"number":"12345678903","status":"accepted","code":202},` +
+				`{"number":"2377225624","status":"already_yours","code":200},` +
+				`{"number":"4561261212345467","status":"owned_by_another","code":409},` +
+				`{"number":"12345678904","status":"invalid","code":422}]`},
+		{name: "Текст с ошибкой хранилища", contentType: "text/plain", body: "12345678903\r\n\n 2377225624 \n",
+			wantCode: http.StatusInternalServerError,
+			wantBody: `[{"number":"12345678903","status":"accepted","code":202},` +
+				`{"number":"2377225624","status":"error","code":500}]`},
+		{name: "Ошибка хранилища до сохранения пачек", contentType: "text/plain", body: "79927398713",
+			wantCode: http.StatusInternalServerError},
+		{name: "Повтор номера в запросе", contentType: ctApplicationJSONString, body: `["2377225624","23-7722-5624"]`,
+			wantCode: http.StatusMultiStatus, wantBody: `[{"number":"2377225624","status":"accepted","code":202},` +
+				`{"number":"2377225624","status":"already_yours","code":200}]`},
+		{name: "Номер с пробелами и дефисами", contentType: "text/plain", body: "4561-2612 1234 5467\n",
+			wantCode: http.StatusMultiStatus,
+			wantBody: `[{"number":"4561261212345467","status":"accepted","code":202}]`},
+		{name: "Только неверные номера", contentType: "text/plain", body: "12345678904\nabc",
+			wantCode: http.StatusMultiStatus, wantBody: `[{"number":"12345678904","status":"invalid","code":422},` +
+				`{"number":"abc","status":"invalid","code":422}]`},
+		{name: "Пустой список", contentType: ctApplicationJSONString, body: `[]`, wantCode: http.StatusBadRequest},
+		{name: "Некорректный json", contentType: ctApplicationJSONString, body: `[1`, wantCode: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/user/orders/bulk", strings.NewReader(tt.body))
+			req.Header.Set(contentTypeString, tt.contentType)
+			req = req.WithContext(context.WithValue(req.Context(), middlewares.AuthUID, 1))
+			w := httptest.NewRecorder()
+			AddOrders(newRequestResponce(w, req, m, zap.NewNop().Sugar()), luhnValidator{})
+			if w.Code != tt.wantCode || w.Body.String() != tt.wantBody {
+				t.Errorf("AddOrders() = %d '%s', want %d '%s'", w.Code, w.Body.String(), tt.wantCode, tt.wantBody)
+			}
+		})
+	}
+}
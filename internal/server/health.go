@@ -0,0 +1,174 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	healthStatusOK   = "ok"
+	healthStatusFail = "fail"
+	healthTimeout    = 2 * time.Second
+	pollerBeatGrace  = 5 * time.Second
+)
+
+type HealthStorage interface {
+	Ping(context.Context) error
+	IsMigrated(context.Context) (bool, error)
+}
+
+// pollerState is updated by accrual poller gorutine and read by readiness check.
+type pollerState struct {
+	started     atomic.Int64
+	beat        atomic.Int64
+	lastPoll    atomic.Int64
+	pausedUntil atomic.Int64
+}
+
+func unixTime(value int64) time.Time {
+	if value == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, value)
+}
+
+func (p *pollerState) setBeat() {
+	p.beat.Store(time.Now().UnixNano())
+}
+
+func (p *pollerState) setPoll() {
+	p.lastPoll.Store(time.Now().UnixNano())
+}
+
+func (p *pollerState) pause(d time.Duration) {
+	p.pausedUntil.Store(time.Now().Add(d).UnixNano())
+}
+
+func (p *pollerState) isPaused() bool {
+	return time.Now().Before(unixTime(p.pausedUntil.Load()))
+}
+
+type healthCheck struct {
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty"`
+}
+
+type healthResponce struct {
+	Checks map[string]healthCheck `json:"checks"`
+	Status string                 `json:"status"`
+}
+
+type health struct {
+	strg         HealthStorage
+	poller       *pollerState
+	logger       *zap.SugaredLogger
+	staleTimeout time.Duration
+	interval     atomic.Int64
+	shuttingDown atomic.Bool
+}
+
+func newHealth(strg HealthStorage, logger *zap.SugaredLogger, interval, staleTimeout time.Duration) *health {
+	h := health{
+		strg:         strg,
+		poller:       &pollerState{},
+		logger:       logger,
+		staleTimeout: staleTimeout,
+	}
+	h.setInterval(interval)
+	return &h
+}
+
+// setInterval changes accrual poller interval after config reload.
+func (h *health) setInterval(interval time.Duration) {
+	h.interval.Store(int64(interval))
+}
+
+func (h *health) checkPoller() healthCheck {
+	now := time.Now()
+	started := unixTime(h.poller.started.Load())
+	if started.IsZero() {
+		return healthCheck{Status: healthStatusFail, Message: "accrual poller is not started"}
+	}
+	if now.Sub(unixTime(h.poller.beat.Load())) > 2*time.Duration(h.interval.Load())+pollerBeatGrace {
+		return healthCheck{Status: healthStatusFail, Message: "accrual poller is not alive"}
+	}
+	if h.poller.isPaused() {
+		return healthCheck{Status: healthStatusOK, Message: "accrual requests paused by accrual system"}
+	}
+	lastPoll := unixTime(h.poller.lastPoll.Load())
+	if lastPoll.IsZero() {
+		lastPoll = started
+	}
+	if now.Sub(lastPoll) > h.staleTimeout {
+		return healthCheck{
+			Status:  healthStatusFail,
+			Message: "last successful poll at " + lastPoll.Format(time.RFC3339),
+		}
+	}
+	return healthCheck{Status: healthStatusOK}
+}
+
+func (h *health) readiness(ctx context.Context) (healthResponce, bool) {
+	resp := healthResponce{Status: healthStatusOK, Checks: make(map[string]healthCheck)}
+	if h.shuttingDown.Load() {
+		resp.Checks["shutdown"] = healthCheck{Status: healthStatusFail, Message: "server is shutting down"}
+	}
+	ctx, cancel := context.WithTimeout(ctx, healthTimeout)
+	defer cancel()
+	if err := h.strg.Ping(ctx); err != nil {
+		resp.Checks["database"] = healthCheck{Status: healthStatusFail, Message: err.Error()}
+	} else {
+		resp.Checks["database"] = healthCheck{Status: healthStatusOK}
+	}
+	migrated, err := h.strg.IsMigrated(ctx)
+	switch {
+	case err != nil:
+		resp.Checks["migrations"] = healthCheck{Status: healthStatusFail, Message: err.Error()}
+	case !migrated:
+		resp.Checks["migrations"] = healthCheck{Status: healthStatusFail, Message: "database schema is not up to date"}
+	default:
+		resp.Checks["migrations"] = healthCheck{Status: healthStatusOK}
+	}
+	resp.Checks["accrual_poller"] = h.checkPoller()
+	for _, item := range resp.Checks {
+		if item.Status != healthStatusOK {
+			resp.Status = healthStatusFail
+			return resp, false
+		}
+	}
+	return resp, true
+}
+
+func (h *health) writeJSON(w http.ResponseWriter, resp healthResponce, ok bool) {
+	data, err := json.Marshal(resp)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		h.logger.Warnf("health responce convert error: %v", err)
+		return
+	}
+	w.Header().Set(contentTypeString, ctApplicationJSONString)
+	if ok {
+		w.WriteHeader(http.StatusOK)
+	} else {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	if _, err = w.Write(data); err != nil {
+		h.logger.Warnf(writeResponceErrorString, err)
+	}
+}
+
+// Liveness responds 200 while the process is running.
+func (h *health) Liveness(w http.ResponseWriter, r *http.Request) {
+	h.writeJSON(w, healthResponce{Status: healthStatusOK}, true)
+}
+
+// Readiness checks database, schema and accrual poller and responds 503 if any of them failed.
+func (h *health) Readiness(w http.ResponseWriter, r *http.Request) {
+	resp, ok := h.readiness(r.Context())
+	h.writeJSON(w, resp, ok)
+}
@@ -0,0 +1,102 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/gostuding/goMarket/internal/mocks"
+	"go.uber.org/zap"
+)
+
+func TestReadiness(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	m := mocks.NewMockStorage(ctrl)
+	m.EXPECT().Ping(gomock.Any()).Return(nil).AnyTimes()
+	m.EXPECT().IsMigrated(gomock.Any()).Return(true, nil).AnyTimes()
+	errStrg := mocks.NewMockStorage(ctrl)
+	errStrg.EXPECT().Ping(gomock.Any()).Return(errors.New("connection refused")).AnyTimes()
+	errStrg.EXPECT().IsMigrated(gomock.Any()).Return(false, nil).AnyTimes()
+
+	tests := []struct {
+		strg     Storage
+		prepare  func(h *health)
+		name     string
+		failed   []string
+		wantCode int
+	}{
+		{
+			name: "Сервис готов",
+			strg: m,
+			prepare: func(h *health) {
+				h.poller.started.Store(time.Now().UnixNano())
+				h.poller.setBeat()
+				h.poller.setPoll()
+			},
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "Опрос начислений не запущен",
+			strg:     m,
+			prepare:  func(h *health) {},
+			failed:   []string{"accrual_poller"},
+			wantCode: http.StatusServiceUnavailable,
+		},
+		{
+			name: "Опрос начислений устарел",
+			strg: m,
+			prepare: func(h *health) {
+				h.poller.started.Store(time.Now().Add(-time.Hour).UnixNano())
+				h.poller.setBeat()
+			},
+			failed:   []string{"accrual_poller"},
+			wantCode: http.StatusServiceUnavailable,
+		},
+		{
+			name: "Ошибка БД",
+			strg: errStrg,
+			prepare: func(h *health) {
+				h.poller.started.Store(time.Now().UnixNano())
+				h.poller.setBeat()
+			},
+			failed:   []string{"database", "migrations"},
+			wantCode: http.StatusServiceUnavailable,
+		},
+		{
+			name: "Остановка сервиса",
+			strg: m,
+			prepare: func(h *health) {
+				h.poller.started.Store(time.Now().UnixNano())
+				h.poller.setBeat()
+				h.shuttingDown.Store(true)
+			},
+			failed:   []string{"shutdown"},
+			wantCode: http.StatusServiceUnavailable,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			h := newHealth(tt.strg, zap.NewNop().Sugar(), time.Second, time.Minute)
+			tt.prepare(h)
+			resp, ok := h.readiness(context.Background())
+			if ok != (tt.wantCode == http.StatusOK) {
+				t.Errorf("readiness() ok = %v, responce: %v", ok, resp)
+			}
+			for _, name := range tt.failed {
+				if resp.Checks[name].Status != healthStatusFail {
+					t.Errorf("readiness() check '%s' status = '%s', want fail", name, resp.Checks[name].Status)
+				}
+			}
+			w := httptest.NewRecorder()
+			h.Readiness(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+			if w.Code != tt.wantCode {
+				t.Errorf("Readiness() status = %d, want %d", w.Code, tt.wantCode)
+			}
+		})
+	}
+}
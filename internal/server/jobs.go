@@ -0,0 +1,65 @@
+package server
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	holdsExpireInterval  = time.Minute
+	pointsExpireInterval = time.Hour
+)
+
+// backgroundJob is periodical storage task. run returns count of processed records.
+// Daily job runs once a day, its interval is offset of run time from local midnight.
+type backgroundJob struct {
+	run      func(context.Context) (int64, error)
+	name     string
+	interval time.Duration
+	daily    bool
+}
+
+// nextRun returns delay before the next job run.
+func (job *backgroundJob) nextRun(now time.Time) time.Duration {
+	if !job.daily {
+		return job.interval
+	}
+	next := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()).Add(job.interval)
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next.Sub(now)
+}
+
+// runJobs runs jobs until ctxStop is done and waits for them to finish. Jobs use ctxWork.
+func runJobs(ctxStop, ctxWork context.Context, jobs []backgroundJob, logger *zap.SugaredLogger) {
+	var wg sync.WaitGroup
+	for _, item := range jobs {
+		wg.Add(1)
+		go func(job backgroundJob) {
+			defer wg.Done()
+			timer := time.NewTimer(job.nextRun(time.Now()))
+			defer timer.Stop()
+			for {
+				select {
+				case <-ctxStop.Done():
+					return
+				case <-timer.C:
+					count, err := job.run(ctxWork)
+					timer.Reset(job.nextRun(time.Now()))
+					if err != nil {
+						logger.Warnf("%s job error: %v", job.name, err)
+						continue
+					}
+					if count > 0 {
+						logger.Infof("%s job processed %d records", job.name, count)
+					}
+				}
+			}
+		}(item)
+	}
+	wg.Wait()
+}
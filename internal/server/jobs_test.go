@@ -0,0 +1,65 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestRunJobs(t *testing.T) {
+	var calls, failures atomic.Int32
+	jobs := []backgroundJob{
+		{name: "ok", interval: time.Millisecond, run: func(ctx context.Context) (int64, error) {
+			calls.Add(1)
+			return 1, nil
+		}},
+		{name: "error", interval: time.Millisecond, run: func(ctx context.Context) (int64, error) {
+			failures.Add(1)
+			return 0, errors.New("storage error")
+		}},
+	}
+	ctxStop, stop := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		runJobs(ctxStop, context.Background(), jobs, zap.NewNop().Sugar())
+	}()
+	time.Sleep(20 * time.Millisecond)
+	stop()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("jobs are not stopped")
+	}
+	if calls.Load() == 0 || failures.Load() == 0 {
+		t.Errorf("jobs calls: ok %d, error %d", calls.Load(), failures.Load())
+	}
+}
+
+func TestNextRun(t *testing.T) {
+	now := time.Date(2024, time.March, 10, 12, 30, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		job  backgroundJob
+		want time.Duration
+	}{
+		{name: "Периодическая задача", job: backgroundJob{interval: time.Minute}, want: time.Minute},
+		{name: "Ежедневная задача сегодня", job: backgroundJob{interval: 15 * time.Hour, daily: true},
+			want: 2*time.Hour + 30*time.Minute},
+		{name: "Ежедневная задача завтра", job: backgroundJob{interval: 3 * time.Hour, daily: true},
+			want: 14*time.Hour + 30*time.Minute},
+		{name: "Ежедневная задача в текущее время", job: backgroundJob{interval: 12*time.Hour + 30*time.Minute,
+			daily: true}, want: 24 * time.Hour},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.job.nextRun(now); got != tt.want {
+				t.Errorf("nextRun() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -18,6 +18,12 @@ const (
 	AuthUID uidstr = iota
 )
 
+// AuthStore checks that user of valid token still has access: user is not blocked and token is issued
+// after the last revocation of user tokens.
+type AuthStore interface {
+	UserAllowed(ctx context.Context, uid int, issuedAt time.Time) (bool, error)
+}
+
 type authJWTStruct struct {
 	jwt.RegisteredClaims
 	UserAgent string
@@ -30,6 +36,7 @@ func CreateToken(key []byte, liveTime, uid int, ua, ip string) (string, error) {
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, authJWTStruct{
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Duration(liveTime) * time.Second)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
 		UserAgent: ua,
 		IP:        ip,
@@ -42,43 +49,77 @@ func CreateToken(key []byte, liveTime, uid int, ua, ip string) (string, error) {
 	return tokenString, nil
 }
 
-func checkAuthToken(r *http.Request, key []byte) (int, error) {
+// parseToken checks token signature with each of keys.
+func parseToken(token string, keys [][]byte) (*authJWTStruct, error) {
+	err := errors.New("signing keys list is empty")
+	for _, key := range keys {
+		claims := &authJWTStruct{}
+		var info *jwt.Token
+		info, err = jwt.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
+			if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+				return nil, fmt.Errorf("unexpected signing method: %v", t.Header["alg"])
+			}
+			return key, nil
+		})
+		if err != nil {
+			continue
+		}
+		if !info.Valid {
+			return nil, errors.New("token is not valid")
+		}
+		return claims, nil
+	}
+	return nil, fmt.Errorf("auth token parse error: %w", err)
+}
+
+func checkAuthToken(r *http.Request, keys [][]byte) (*authJWTStruct, error) {
 	token := r.Header.Get(authString)
 	if token == "" {
-		return 0, errors.New("token is empty")
+		return nil, errors.New("token is empty")
 	}
-	claims := &authJWTStruct{}
-	info, err := jwt.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
-		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", t.Header["alg"])
-		}
-		return key, nil
-	})
+	claims, err := parseToken(token, keys)
 	if err != nil {
-		return 0, fmt.Errorf("auth token parse error: %w", err)
-	}
-	if !info.Valid {
-		return 0, errors.New("token is not valid")
+		return nil, err
 	}
 	ip, _, err := net.SplitHostPort(r.RemoteAddr)
 	if err != nil {
-		return 0, fmt.Errorf("user ip not equal to IP:port, error: %w", err)
+		return nil, fmt.Errorf("user ip not equal to IP:port, error: %w", err)
 	}
 	if claims.UserAgent != r.UserAgent() || claims.IP != ip {
-		return 0, errors.New("user data changed. Reauth requared")
+		return nil, errors.New("user data changed. Reauth requared")
 	}
-	return claims.UID, nil
+	return claims, nil
 }
 
-func AuthMiddleware(logger *zap.SugaredLogger, redirectURL string, key []byte) func(h http.Handler) http.Handler {
+// AuthMiddleware checks user token. keys returns current signing keys, so they can be changed at runtime.
+// Tokens of blocked users and tokens revoked by password reset are rejected.
+func AuthMiddleware(logger *zap.SugaredLogger, redirectURL string, keys func() [][]byte,
+	strg AuthStore) func(h http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		fn := func(w http.ResponseWriter, r *http.Request) {
-			uid, err := checkAuthToken(r, key)
+			claims, err := checkAuthToken(r, keys())
 			if err != nil {
 				http.Redirect(w, r, redirectURL, http.StatusUnauthorized)
-				logger.Warnf("%s authorization token error: %w", r.URL.Path, err)
+				RequestLogger(r.Context(), logger).Warnf("%s authorization token error: %v", r.URL.Path, err)
+				return
+			}
+			var issuedAt time.Time
+			if claims.IssuedAt != nil {
+				issuedAt = claims.IssuedAt.Time
+			}
+			uid := claims.UID
+			allowed, err := strg.UserAllowed(r.Context(), uid, issuedAt)
+			if err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				RequestLogger(r.Context(), logger).Warnf("%s check user error: %v", r.URL.Path, err)
+				return
+			}
+			if !allowed {
+				http.Redirect(w, r, redirectURL, http.StatusUnauthorized)
+				RequestLogger(r.Context(), logger).Warnf("%s user %d is blocked or token revoked", r.URL.Path, uid)
 				return
 			}
+			setRequestUID(r.Context(), uid)
 			w.Header().Set(authString, r.Header.Get(authString))
 			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), AuthUID, uid)))
 		}
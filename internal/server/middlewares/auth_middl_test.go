@@ -0,0 +1,60 @@
+package middlewares
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// authStore is users access of test users: blocked users and revocation time of users tokens.
+type authStore struct {
+	blocked map[int]bool
+	reset   map[int]time.Time
+}
+
+func (s *authStore) UserAllowed(ctx context.Context, uid int, issuedAt time.Time) (bool, error) {
+	if uid == 0 {
+		return false, errors.New("storage error")
+	}
+	reset, ok := s.reset[uid]
+	return !s.blocked[uid] && (!ok || !issuedAt.Before(reset)), nil
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	key := []byte("key")
+	strg := &authStore{blocked: map[int]bool{2: true}, reset: map[int]time.Time{3: time.Now().Add(time.Hour)}}
+	handler := AuthMiddleware(zap.NewNop().Sugar(), "/api/user/login", func() [][]byte { return [][]byte{key} },
+		strg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	tests := []struct {
+		name     string
+		uid      int
+		wantCode int
+	}{
+		{name: "Активный пользователь", uid: 1, wantCode: http.StatusOK},
+		{name: "Заблокированный пользователь", uid: 2, wantCode: http.StatusUnauthorized},
+		{name: "Токен выдан до смены пароля", uid: 3, wantCode: http.StatusUnauthorized},
+		{name: "Ошибка хранилища", uid: 0, wantCode: http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := CreateToken(key, 60, tt.uid, "agent", "192.0.2.1")
+			if err != nil {
+				t.Fatalf("create token error: %v", err)
+			}
+			req := httptest.NewRequest(http.MethodGet, "/api/user/balance", nil)
+			req.RemoteAddr = "192.0.2.1:1234"
+			req.Header.Set("Authorization", token)
+			req.Header.Set("User-Agent", "agent")
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			if w.Code != tt.wantCode {
+				t.Errorf("AuthMiddleware() = %d, want %d", w.Code, tt.wantCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,20 @@
+package middlewares
+
+import (
+	"net/http"
+)
+
+// BodyLimitMiddleware limits request body size. Reading more than limit bytes returns *http.MaxBytesError.
+func BodyLimitMiddleware(limit int64) func(h http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			if r.ContentLength > limit {
+				w.WriteHeader(http.StatusRequestEntityTooLarge)
+				return
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, limit)
+			next.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
@@ -0,0 +1,71 @@
+package middlewares
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func gzipBody(t *testing.T, size int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	if _, err := writer.Write(bytes.Repeat([]byte("0"), size)); err != nil {
+		t.Fatalf("gzip write error: %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("gzip close error: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestBodyLimits(t *testing.T) {
+	readHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := io.ReadAll(r.Body); err != nil {
+			var maxErr *http.MaxBytesError
+			if errors.As(err, &maxErr) {
+				w.WriteHeader(http.StatusRequestEntityTooLarge)
+				return
+			}
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+	handler := BodyLimitMiddleware(1024)(GzipMiddleware(zap.NewNop().Sugar(), 4096)(readHandler))
+	tests := []struct {
+		name     string
+		body     []byte
+		gzip     bool
+		wantCode int
+	}{
+		{name: "Тело в пределах лимита", body: bytes.Repeat([]byte("0"), 1024), wantCode: http.StatusOK},
+		{name: "Тело больше лимита", body: bytes.Repeat([]byte("0"), 1025), wantCode: http.StatusRequestEntityTooLarge},
+		{name: "Сжатое тело в пределах лимита", body: gzipBody(t, 4096), gzip: true, wantCode: http.StatusOK},
+		{
+			name:     "Распакованное тело больше лимита",
+			body:     gzipBody(t, 1<<20),
+			gzip:     true,
+			wantCode: http.StatusRequestEntityTooLarge,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/user/orders", bytes.NewReader(tt.body))
+			if tt.gzip {
+				req.Header.Set(ceString, gzipString)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			if w.Code != tt.wantCode {
+				t.Errorf("status code = %d, want %d", w.Code, tt.wantCode)
+			}
+		})
+	}
+}
@@ -73,17 +73,19 @@ func (c *gzipReader) Close() error {
 	return c.gzip.Close() //nolint:wrapcheck // <- default action
 }
 
-func GzipMiddleware(logger *zap.SugaredLogger) func(h http.Handler) http.Handler {
+// GzipMiddleware decompresses request body and compresses responce.
+// Decompressed body size is limited by maxSize to protect from decompression bombs.
+func GzipMiddleware(logger *zap.SugaredLogger, maxSize int64) func(h http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		fn := func(w http.ResponseWriter, r *http.Request) {
 			if strings.Contains(r.Header.Get(ceString), gzipString) {
 				cr, err := newGzipReader(r.Body)
 				if err != nil {
 					w.WriteHeader(http.StatusBadRequest)
-					logger.Warnf("gzip reader create error: %w", err)
+					RequestLogger(r.Context(), logger).Warnf("gzip reader create error: %v", err)
 					return
 				}
-				r.Body = cr
+				r.Body = http.MaxBytesReader(w, cr, maxSize)
 				defer cr.Close() //nolint:errcheck // <- senselessly
 			}
 			if strings.Contains(r.Header.Get("Accept-Encoding"), gzipString) {
@@ -0,0 +1,131 @@
+package middlewares
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gostuding/goMarket/internal/idempotency"
+	"go.uber.org/zap"
+)
+
+// recordWriter writes response to client and keeps a copy for idempotency record.
+type recordWriter struct {
+	http.ResponseWriter
+	body   bytes.Buffer
+	status int
+}
+
+func (w *recordWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *recordWriter) Write(data []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data) //nolint:wrapcheck // <- writer proxy
+}
+
+// IdempotencyMiddleware saves response of the first request with Idempotency-Key header and
+// replays it for retries with the same key. Retry with other body gets 422 status,
+// retry while the first request is processed gets 409 status.
+// Must be used after authorization, keys are unique per user.
+func IdempotencyMiddleware(logger *zap.SugaredLogger, store idempotency.Store,
+	ttl time.Duration) func(h http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(idempotency.Header)
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			logger := RequestLogger(r.Context(), logger)
+			uid, ok := r.Context().Value(AuthUID).(int)
+			if !ok {
+				w.WriteHeader(http.StatusUnauthorized)
+				logger.Warnln("idempotency middleware used without authorization")
+				return
+			}
+			if len(key) > idempotency.MaxKeyLength {
+				w.WriteHeader(http.StatusBadRequest)
+				logger.Warnf("idempotency key is too long: %d", len(key))
+				return
+			}
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				var maxErr *http.MaxBytesError
+				if errors.As(err, &maxErr) {
+					w.WriteHeader(http.StatusRequestEntityTooLarge)
+				} else {
+					w.WriteHeader(http.StatusBadRequest)
+				}
+				logger.Warnf("read request body error: %v", err)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			fingerprint := idempotency.Fingerprint(r.Method, r.URL.Path, body)
+			record, created, err := store.StartIdempotent(r.Context(), uid, key, fingerprint, ttl)
+			if err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				logger.Warnf("start idempotent request error: %v", err)
+				return
+			}
+			if !created {
+				replay(w, logger, record, fingerprint)
+				return
+			}
+			rw := recordWriter{ResponseWriter: w}
+			finished := false
+			defer func() {
+				// Request failed or panicked, so the key can be used to retry it.
+				if !finished {
+					if err := store.CancelIdempotent(context.Background(), uid, key); err != nil {
+						logger.Warnf("cancel idempotent request error: %v", err)
+					}
+				}
+			}()
+			next.ServeHTTP(&rw, r)
+			if rw.status == 0 {
+				rw.status = http.StatusOK
+			}
+			if rw.status >= http.StatusInternalServerError {
+				return
+			}
+			finished = true
+			record = &idempotency.Record{Fingerprint: fingerprint, ContentType: rw.Header().Get(ctString),
+				Body: rw.body.Bytes(), Status: rw.status, Done: true}
+			if err = store.FinishIdempotent(context.Background(), uid, key, record); err != nil {
+				logger.Warnf("finish idempotent request error: %v", err)
+			}
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+func replay(w http.ResponseWriter, logger *zap.SugaredLogger, record *idempotency.Record, fingerprint string) {
+	switch {
+	case record.Fingerprint != fingerprint:
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		logger.Warnln("idempotency key is used with other request")
+	case !record.Done:
+		w.WriteHeader(http.StatusConflict)
+		logger.Debugln("request with idempotency key is in progress")
+	default:
+		if record.ContentType != "" {
+			w.Header().Set(ctString, record.ContentType)
+		}
+		w.Header().Set("Idempotent-Replayed", "true")
+		w.WriteHeader(record.Status)
+		if _, err := w.Write(record.Body); err != nil {
+			logger.Warnf("write replayed response error: %v", err)
+		}
+	}
+}
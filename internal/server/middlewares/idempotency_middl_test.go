@@ -0,0 +1,163 @@
+package middlewares
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gostuding/goMarket/internal/idempotency"
+	"go.uber.org/zap"
+)
+
+type memoryIdempotency struct {
+	records map[string]*idempotency.Record
+}
+
+func (m *memoryIdempotency) StartIdempotent(ctx context.Context, uid int, key, fingerprint string,
+	ttl time.Duration) (*idempotency.Record, bool, error) {
+	id := strconv.Itoa(uid) + key
+	if record, ok := m.records[id]; ok {
+		return record, false, nil
+	}
+	m.records[id] = &idempotency.Record{Fingerprint: fingerprint}
+	return nil, true, nil
+}
+
+func (m *memoryIdempotency) FinishIdempotent(ctx context.Context, uid int, key string,
+	record *idempotency.Record) error {
+	m.records[strconv.Itoa(uid)+key] = record
+	return nil
+}
+
+func (m *memoryIdempotency) CancelIdempotent(ctx context.Context, uid int, key string) error {
+	delete(m.records, strconv.Itoa(uid)+key)
+	return nil
+}
+
+func TestIdempotencyMiddleware(t *testing.T) {
+	type request struct {
+		key      string
+		body     string
+		uid      int
+		wantCode int
+		wantBody string
+		replayed bool
+	}
+	tests := []struct {
+		name     string
+		status   int
+		requests []request
+		calls    int
+	}{
+		{
+			name:   "Повтор запроса",
+			status: http.StatusAccepted,
+			calls:  1,
+			requests: []request{
+				{key: "a", body: "1", uid: 1, wantCode: http.StatusAccepted, wantBody: "1"},
+				{key: "a", body: "1", uid: 1, wantCode: http.StatusAccepted, wantBody: "1", replayed: true},
+			},
+		},
+		{
+			name:   "Другое тело запроса",
+			status: http.StatusAccepted,
+			calls:  1,
+			requests: []request{
+				{key: "a", body: "1", uid: 1, wantCode: http.StatusAccepted, wantBody: "1"},
+				{key: "a", body: "2", uid: 1, wantCode: http.StatusUnprocessableEntity},
+			},
+		},
+		{
+			name:   "Ключи разных пользователей",
+			status: http.StatusAccepted,
+			calls:  2,
+			requests: []request{
+				{key: "a", body: "1", uid: 1, wantCode: http.StatusAccepted, wantBody: "1"},
+				{key: "a", body: "1", uid: 2, wantCode: http.StatusAccepted, wantBody: "1"},
+			},
+		},
+		{
+			name:   "Без ключа",
+			status: http.StatusAccepted,
+			calls:  2,
+			requests: []request{
+				{body: "1", uid: 1, wantCode: http.StatusAccepted, wantBody: "1"},
+				{body: "1", uid: 1, wantCode: http.StatusAccepted, wantBody: "1"},
+			},
+		},
+		{
+			name:   "Ошибка сервиса не сохраняется",
+			status: http.StatusInternalServerError,
+			calls:  2,
+			requests: []request{
+				{key: "a", body: "1", uid: 1, wantCode: http.StatusInternalServerError, wantBody: "1"},
+				{key: "a", body: "1", uid: 1, wantCode: http.StatusInternalServerError, wantBody: "1"},
+			},
+		},
+		{
+			name:     "Слишком длинный ключ",
+			requests: []request{{key: strings.Repeat("a", 256), uid: 1, wantCode: http.StatusBadRequest}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			echo := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				w.Header().Set(ctString, "text/plain")
+				w.WriteHeader(tt.status)
+				if _, err := w.Write([]byte(r.FormValue("body"))); err != nil {
+					t.Errorf("write error: %v", err)
+				}
+			})
+			store := &memoryIdempotency{records: make(map[string]*idempotency.Record)}
+			handler := IdempotencyMiddleware(zap.NewNop().Sugar(), store, time.Hour)(echo)
+			for i, item := range tt.requests {
+				req := httptest.NewRequest(http.MethodPost, "/api/user/orders",
+					strings.NewReader("body="+item.body))
+				req.Header.Set(ctString, "application/x-www-form-urlencoded")
+				if item.key != "" {
+					req.Header.Set(idempotency.Header, item.key)
+				}
+				req = req.WithContext(context.WithValue(req.Context(), AuthUID, item.uid))
+				w := httptest.NewRecorder()
+				handler.ServeHTTP(w, req)
+				if w.Code != item.wantCode || w.Body.String() != item.wantBody {
+					t.Errorf("request %d: response = %d '%s', want %d '%s'", i, w.Code, w.Body.String(),
+						item.wantCode, item.wantBody)
+				}
+				if replayed := w.Header().Get("Idempotent-Replayed") != ""; replayed != item.replayed {
+					t.Errorf("request %d: replayed = %v, want %v", i, replayed, item.replayed)
+				}
+			}
+			if calls != tt.calls {
+				t.Errorf("handler calls = %d, want %d", calls, tt.calls)
+			}
+		})
+	}
+}
+
+func TestIdempotencyInProgress(t *testing.T) {
+	store := &memoryIdempotency{records: make(map[string]*idempotency.Record)}
+	var inner *httptest.ResponseRecorder
+	var handler http.Handler
+	newRequest := func() *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/api/user/balance/withdraw", strings.NewReader("{}"))
+		req.Header.Set(idempotency.Header, "key")
+		return req.WithContext(context.WithValue(req.Context(), AuthUID, 1))
+	}
+	handler = IdempotencyMiddleware(zap.NewNop().Sugar(), store, time.Hour)(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			inner = httptest.NewRecorder()
+			handler.ServeHTTP(inner, newRequest())
+			w.WriteHeader(http.StatusOK)
+		}))
+	handler.ServeHTTP(httptest.NewRecorder(), newRequest())
+	if inner == nil || inner.Code != http.StatusConflict {
+		t.Errorf("concurrent request status = %v, want %d", inner, http.StatusConflict)
+	}
+}
@@ -0,0 +1,146 @@
+package middlewares
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+type requestKey int
+
+const (
+	requestLoggerKey requestKey = iota
+	requestIDKey
+	requestInfoKey
+)
+
+const (
+	requestIDHeader = "X-Request-ID"
+	requestIDLength = 16
+	requestIDMaxLen = 128
+)
+
+// requestInfo is filled by the inner middlewares (auth) for the access log.
+type requestInfo struct {
+	uid int
+}
+
+type loggingWriter struct {
+	http.ResponseWriter
+	status int
+	size   int
+}
+
+func (w *loggingWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	size, err := w.ResponseWriter.Write(b)
+	w.size += size
+	return size, err //nolint:wrapcheck // <- default action
+}
+
+func (w *loggingWriter) WriteHeader(statusCode int) {
+	if w.status == 0 {
+		w.status = statusCode
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func newRequestID() string {
+	buf := make([]byte, requestIDLength)
+	if _, err := rand.Read(buf); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(buf)
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > requestIDMaxLen {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < '!' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// RequestID returns request identifier stored in context by LoggerMiddleware.
+func RequestID(ctx context.Context) string {
+	id, ok := ctx.Value(requestIDKey).(string)
+	if !ok {
+		return ""
+	}
+	return id
+}
+
+// RequestLogger returns request-scoped logger or default logger if context has no one.
+func RequestLogger(ctx context.Context, logger *zap.SugaredLogger) *zap.SugaredLogger {
+	reqLogger, ok := ctx.Value(requestLoggerKey).(*zap.SugaredLogger)
+	if !ok {
+		return logger
+	}
+	return reqLogger
+}
+
+func setRequestUID(ctx context.Context, uid int) {
+	if info, ok := ctx.Value(requestInfoKey).(*requestInfo); ok {
+		info.uid = uid
+	}
+}
+
+// LoggerMiddleware accepts or generates X-Request-ID, stores request-scoped logger in context
+// and writes access log line for each request.
+func LoggerMiddleware(logger *zap.SugaredLogger) func(h http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			id := r.Header.Get(requestIDHeader)
+			if !validRequestID(id) {
+				id = newRequestID()
+			}
+			w.Header().Set(requestIDHeader, id)
+			reqLogger := logger.With(zap.String("request_id", id))
+			span := trace.SpanFromContext(r.Context())
+			if span.SpanContext().HasTraceID() {
+				reqLogger = reqLogger.With(zap.String("trace_id", span.SpanContext().TraceID().String()))
+				span.SetAttributes(attribute.String("http.request_id", id))
+			}
+			info := requestInfo{}
+			ctx := context.WithValue(r.Context(), requestIDKey, id)
+			ctx = context.WithValue(ctx, requestLoggerKey, reqLogger)
+			ctx = context.WithValue(ctx, requestInfoKey, &info)
+			lw := &loggingWriter{ResponseWriter: w}
+			next.ServeHTTP(lw, r.WithContext(ctx))
+
+			route := r.URL.Path
+			if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+				route = rctx.RoutePattern()
+			}
+			if lw.status == 0 {
+				lw.status = http.StatusOK
+			}
+			fields := []any{
+				zap.String("method", r.Method),
+				zap.String("route", route),
+				zap.Int("status", lw.status),
+				zap.Int("bytes", lw.size),
+				zap.Duration("latency", time.Since(start)),
+			}
+			if info.uid != 0 {
+				fields = append(fields, zap.Int("uid", info.uid))
+			}
+			reqLogger.Infow("access", fields...)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
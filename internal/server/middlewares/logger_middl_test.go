@@ -0,0 +1,64 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestLoggerMiddleware(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	logger := zap.New(core).Sugar()
+	router := chi.NewRouter()
+	router.Use(LoggerMiddleware(logger))
+	router.Get("/orders/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if RequestID(r.Context()) == "" {
+			t.Error("request id not found in context")
+		}
+		setRequestUID(r.Context(), 1)
+		w.WriteHeader(http.StatusAccepted)
+		_, _ = w.Write([]byte("ok"))
+	})
+
+	tests := []struct {
+		name   string
+		header string
+		keep   bool
+	}{
+		{name: "Передача идентификатора клиентом", header: "client-id", keep: true},
+		{name: "Генерация идентификатора", header: "", keep: false},
+		{name: "Некорректный идентификатор", header: "bad id", keep: false},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/orders/10", nil)
+			if tt.header != "" {
+				req.Header.Set(requestIDHeader, tt.header)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			id := w.Header().Get(requestIDHeader)
+			if id == "" {
+				t.Fatal("responce request id is empty")
+			}
+			if (id == tt.header) != tt.keep {
+				t.Errorf("responce request id = '%s', header '%s'", id, tt.header)
+			}
+			entries := logs.TakeAll()
+			if len(entries) != 1 {
+				t.Fatalf("access log entries count = %d, want 1", len(entries))
+			}
+			fields := entries[0].ContextMap()
+			if fields["request_id"] != id || fields["route"] != "/orders/{id}" ||
+				fields["status"] != int64(http.StatusAccepted) || fields["bytes"] != int64(2) ||
+				fields["uid"] != int64(1) {
+				t.Errorf("access log fields incorrect: %v", fields)
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package middlewares
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gostuding/goMarket/internal/ratelimit"
+	"go.uber.org/zap"
+)
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// rateLimitKey returns bucket key of user if request is authorized or of client ip otherwise.
+func rateLimitKey(r *http.Request, route string, byUser bool) string {
+	if uid, ok := r.Context().Value(AuthUID).(int); ok && byUser {
+		return route + "|uid:" + strconv.Itoa(uid)
+	}
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	return route + "|ip:" + ip
+}
+
+// RateLimitMiddleware limits requests to route by client ip or by user id if byUser is true.
+// rule returns current limit of the route, so limits can be changed at runtime.
+// If store is not available requests are allowed.
+func RateLimitMiddleware(logger *zap.SugaredLogger, store ratelimit.Store, route string,
+	rule func(string) (ratelimit.Rule, bool), byUser bool) func(h http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			limit, ok := rule(route)
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+			res, err := store.Take(r.Context(), rateLimitKey(r, route, byUser), limit)
+			if err != nil {
+				RequestLogger(r.Context(), logger).Warnf("rate limit error: %v", err)
+				next.ServeHTTP(w, r)
+				return
+			}
+			w.Header().Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+			w.Header().Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+			w.Header().Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(res.Reset)))
+			if !res.Allowed {
+				w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(res.RetryAfter)))
+				w.WriteHeader(http.StatusTooManyRequests)
+				RequestLogger(r.Context(), logger).Debugf("rate limit '%s' exceeded", route)
+				return
+			}
+			next.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
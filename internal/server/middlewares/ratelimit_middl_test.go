@@ -0,0 +1,86 @@
+package middlewares
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gostuding/goMarket/internal/ratelimit"
+	"go.uber.org/zap"
+)
+
+func TestRateLimitMiddleware(t *testing.T) {
+	rules := func(route string) (ratelimit.Rule, bool) {
+		if route == "POST /api/user/orders" {
+			return ratelimit.Rule{Limit: 1, Period: time.Minute}, true
+		}
+		return ratelimit.Rule{}, false
+	}
+	okHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	type request struct {
+		ip       string
+		uid      int
+		wantCode int
+	}
+	tests := []struct {
+		name     string
+		route    string
+		requests []request
+		byUser   bool
+	}{
+		{
+			name:  "Ограничение по ip",
+			route: "POST /api/user/orders",
+			requests: []request{
+				{ip: "192.0.2.1:1000", wantCode: http.StatusOK},
+				{ip: "192.0.2.1:1001", wantCode: http.StatusTooManyRequests},
+				{ip: "192.0.2.2:1000", wantCode: http.StatusOK},
+			},
+		},
+		{
+			name:   "Ограничение по пользователю",
+			route:  "POST /api/user/orders",
+			byUser: true,
+			requests: []request{
+				{ip: "192.0.2.1:1000", uid: 1, wantCode: http.StatusOK},
+				{ip: "192.0.2.2:1000", uid: 1, wantCode: http.StatusTooManyRequests},
+				{ip: "192.0.2.1:1000", uid: 2, wantCode: http.StatusOK},
+			},
+		},
+		{
+			name:  "Адрес без ограничений",
+			route: "GET /api/user/orders",
+			requests: []request{
+				{ip: "192.0.2.1:1000", wantCode: http.StatusOK},
+				{ip: "192.0.2.1:1000", wantCode: http.StatusOK},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := RateLimitMiddleware(zap.NewNop().Sugar(), ratelimit.NewMemoryStore(), tt.route, rules,
+				tt.byUser)(okHandler)
+			for i, item := range tt.requests {
+				req := httptest.NewRequest(http.MethodPost, "/api/user/orders", nil)
+				req.RemoteAddr = item.ip
+				if item.uid != 0 {
+					req = req.WithContext(context.WithValue(req.Context(), AuthUID, item.uid))
+				}
+				w := httptest.NewRecorder()
+				handler.ServeHTTP(w, req)
+				if w.Code != item.wantCode {
+					t.Errorf("request %d: status code = %d, want %d", i, w.Code, item.wantCode)
+				}
+				_, limited := rules(tt.route)
+				if limited && w.Header().Get("RateLimit-Limit") != "1" {
+					t.Errorf("request %d: RateLimit-Limit header = '%s'", i, w.Header().Get("RateLimit-Limit"))
+				}
+				if w.Code == http.StatusTooManyRequests && w.Header().Get("Retry-After") != "60" {
+					t.Errorf("request %d: Retry-After header = '%s'", i, w.Header().Get("Retry-After"))
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package middlewares
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// RealIPMiddleware sets request remote address to client address from X-Forwarded-For or X-Real-IP headers
+// if request is received from trusted proxy. Headers of other clients are ignored, so client can not
+// change its address for rate limits and tokens. Port of connection is kept in remote address.
+func RealIPMiddleware(trusted []*net.IPNet) func(h http.Handler) http.Handler {
+	isTrusted := func(ip net.IP) bool {
+		for _, network := range trusted {
+			if network.Contains(ip) {
+				return true
+			}
+		}
+		return false
+	}
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			host, port, err := net.SplitHostPort(r.RemoteAddr)
+			if peer := net.ParseIP(host); err == nil && peer != nil && isTrusted(peer) {
+				if ip := forwardedIP(r.Header, isTrusted); ip != "" {
+					r.RemoteAddr = net.JoinHostPort(ip, port)
+				}
+			}
+			next.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// forwardedIP returns the nearest to proxy untrusted address of X-Forwarded-For chain or X-Real-IP address.
+// Addresses left of incorrect value are not used, because they are not checked by trusted proxies.
+func forwardedIP(header http.Header, isTrusted func(net.IP) bool) string {
+	if value := header.Get("X-Forwarded-For"); value != "" {
+		chain := strings.Split(value, ",")
+		client := ""
+		for i := len(chain) - 1; i >= 0; i-- {
+			ip := net.ParseIP(strings.TrimSpace(chain[i]))
+			if ip == nil {
+				break
+			}
+			client = ip.String()
+			if !isTrusted(ip) {
+				break
+			}
+		}
+		return client
+	}
+	if ip := net.ParseIP(strings.TrimSpace(header.Get("X-Real-IP"))); ip != nil {
+		return ip.String()
+	}
+	return ""
+}
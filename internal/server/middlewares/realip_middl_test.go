@@ -0,0 +1,54 @@
+package middlewares
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRealIPMiddleware(t *testing.T) {
+	_, proxies, err := net.ParseCIDR("10.0.0.0/8")
+	if err != nil {
+		t.Fatalf("parse cidr error: %v", err)
+	}
+	tests := []struct {
+		name      string
+		remote    string
+		forwarded string
+		realIP    string
+		want      string
+	}{
+		{name: "Клиент без прокси", remote: "192.0.2.1:1234", want: "192.0.2.1:1234"},
+		{name: "Заголовок от недоверенного клиента", remote: "192.0.2.1:1234", forwarded: "198.51.100.7",
+			realIP: "198.51.100.8", want: "192.0.2.1:1234"},
+		{name: "Заголовок от доверенного прокси", remote: "10.0.0.1:1234", forwarded: "198.51.100.7",
+			want: "198.51.100.7:1234"},
+		{name: "Подмена адреса в цепочке", remote: "10.0.0.1:1234",
+			forwarded: "203.0.113.9, 198.51.100.7, 10.0.0.2", want: "198.51.100.7:1234"},
+		{name: "Некорректный адрес в цепочке", remote: "10.0.0.1:1234", forwarded: "198.51.100.7, bad, 10.0.0.2",
+			want: "10.0.0.2:1234"},
+		{name: "X-Real-IP от доверенного прокси", remote: "10.0.0.1:1234", realIP: "198.51.100.8",
+			want: "198.51.100.8:1234"},
+		{name: "Доверенный прокси без заголовков", remote: "10.0.0.1:1234", want: "10.0.0.1:1234"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			handler := RealIPMiddleware([]*net.IPNet{proxies})(http.HandlerFunc(
+				func(w http.ResponseWriter, r *http.Request) { got = r.RemoteAddr }))
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.remote
+			if tt.forwarded != "" {
+				req.Header.Set("X-Forwarded-For", tt.forwarded)
+			}
+			if tt.realIP != "" {
+				req.Header.Set("X-Real-IP", tt.realIP)
+			}
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+			if got != tt.want {
+				t.Errorf("RealIPMiddleware() remote address = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package server
+
+import (
+	"bytes"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-chi/cors"
+	"github.com/gostuding/goMarket/internal/ratelimit"
+	"go.uber.org/zap"
+)
+
+// ConfigReloader re-reads configuration on SIGHUP. Returned config must be already validated.
+type ConfigReloader func() (*ServerConfig, error)
+
+// runtimeSettings are server options which can be changed without restart.
+type runtimeSettings struct {
+	cors          *cors.Cors
+	tokenKeys     [][]byte
+	corsOrigins   []string
+	rateLimits    map[string]ratelimit.Rule
+	tokenLiveTime int
+	interval      time.Duration
+	workers       int
+}
+
+type runtimeConfig struct {
+	settings atomic.Pointer[runtimeSettings]
+	changed  chan struct{}
+	cfg      ServerConfig
+	mutex    sync.Mutex
+}
+
+func newCORS(origins []string) *cors.Cors {
+	return cors.New(cors.Options{
+		AllowedOrigins: origins,
+		AllowedMethods: []string{"GET", "POST", "OPTIONS"},
+	})
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func newRuntimeConfig(cfg *ServerConfig) *runtimeConfig {
+	rc := runtimeConfig{cfg: *cfg, changed: make(chan struct{}, 1)}
+	rateLimits, _ := parseRateLimits(cfg.RateLimits)
+	rc.settings.Store(&runtimeSettings{
+		rateLimits:    rateLimits,
+		cors:          newCORS(cfg.CORSOrigins),
+		corsOrigins:   cfg.CORSOrigins,
+		tokenKeys:     [][]byte{cfg.AuthSecretKey},
+		tokenLiveTime: cfg.AuthTokenLiveTime,
+		interval:      time.Duration(cfg.AccrualRequestInterval) * time.Second,
+		workers:       cfg.AccrualWorkers,
+	})
+	return &rc
+}
+
+func (rc *runtimeConfig) load() *runtimeSettings {
+	return rc.settings.Load()
+}
+
+// signingKey returns key for new tokens.
+func (rc *runtimeConfig) signingKey() []byte {
+	return rc.load().tokenKeys[0]
+}
+
+// verifyKeys returns keys accepted for tokens validation.
+func (rc *runtimeConfig) verifyKeys() [][]byte {
+	return rc.load().tokenKeys
+}
+
+// apply changes runtime settings. Options which require restart are logged and ignored.
+func (rc *runtimeConfig) apply(cfg *ServerConfig, logger *zap.SugaredLogger) {
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
+	if cfg.ServerAddress != rc.cfg.ServerAddress || cfg.AdminAddress != rc.cfg.AdminAddress ||
+		cfg.AccuralAddress != rc.cfg.AccuralAddress || cfg.MetricsAdminOnly != rc.cfg.MetricsAdminOnly ||
+		cfg.ShutdownDelay != rc.cfg.ShutdownDelay || cfg.PollerStaleTimeout != rc.cfg.PollerStaleTimeout {
+		logger.Warnln("addresses, metrics, shutdown and poller stale options are changed only after restart")
+	}
+	old := rc.load()
+	rateLimits, _ := parseRateLimits(cfg.RateLimits)
+	settings := runtimeSettings{
+		rateLimits:    rateLimits,
+		cors:          old.cors,
+		corsOrigins:   old.corsOrigins,
+		tokenKeys:     old.tokenKeys,
+		tokenLiveTime: cfg.AuthTokenLiveTime,
+		interval:      time.Duration(cfg.AccrualRequestInterval) * time.Second,
+		workers:       cfg.AccrualWorkers,
+	}
+	if !equalStrings(cfg.CORSOrigins, old.corsOrigins) {
+		settings.cors = newCORS(cfg.CORSOrigins)
+		settings.corsOrigins = cfg.CORSOrigins
+	}
+	// After key change tokens signed by the previous key stay valid until they expire.
+	if !bytes.Equal(cfg.AuthSecretKey, old.tokenKeys[0]) {
+		settings.tokenKeys = [][]byte{cfg.AuthSecretKey, old.tokenKeys[0]}
+		logger.Infoln("token signing key changed, tokens signed by previous key are valid until expire")
+	}
+	rc.settings.Store(&settings)
+	// Next reload compares options with applied config, so restart warning is not repeated.
+	rc.cfg = *cfg
+	select {
+	case rc.changed <- struct{}{}:
+	default:
+	}
+	logger.Infof("Runtime config applied: accrual interval %v, accrual workers %d, token live time %d, cors %v",
+		settings.interval, settings.workers, settings.tokenLiveTime, settings.corsOrigins)
+}
+
+// rateLimit returns current rate limit rule of route.
+func (rc *runtimeConfig) rateLimit(route string) (ratelimit.Rule, bool) {
+	rule, ok := rc.load().rateLimits[route]
+	return rule, ok
+}
+
+// corsMiddleware uses current cors options for each request.
+func (rc *runtimeConfig) corsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rc.load().cors.Handler(next).ServeHTTP(w, r)
+	})
+}
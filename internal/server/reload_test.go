@@ -0,0 +1,116 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/gostuding/goMarket/internal/mocks"
+	"github.com/gostuding/goMarket/internal/server/middlewares"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestRuntimeConfigApply(t *testing.T) {
+	cfg := NewServerConfig()
+	cfg.AuthSecretKey = []byte("old key")
+	oldToken, err := middlewares.CreateToken(cfg.AuthSecretKey, cfg.AuthTokenLiveTime, 1, "", "192.0.2.1")
+	if err != nil {
+		t.Fatalf("create token error: %v", err)
+	}
+	tests := []struct {
+		change   func(cfg *ServerConfig)
+		check    func(t *testing.T, rt *runtimeConfig)
+		name     string
+		wantKeys int
+	}{
+		{
+			name:     "Изменение интервала и количества обработчиков",
+			change:   func(cfg *ServerConfig) { cfg.AccrualRequestInterval, cfg.AccrualWorkers = 5, 3 },
+			wantKeys: 1,
+			check: func(t *testing.T, rt *runtimeConfig) {
+				t.Helper()
+				if s := rt.load(); s.interval != 5*time.Second || s.workers != 3 {
+					t.Errorf("runtime settings not changed: %v, %d", s.interval, s.workers)
+				}
+			},
+		},
+		{
+			name:     "Смена ключа подписи",
+			change:   func(cfg *ServerConfig) { cfg.AuthSecretKey = []byte("new key") },
+			wantKeys: 2,
+			check: func(t *testing.T, rt *runtimeConfig) {
+				t.Helper()
+				if string(rt.signingKey()) != "new key" {
+					t.Errorf("signing key not changed: %s", rt.signingKey())
+				}
+				strg := mocks.NewMockStorage(gomock.NewController(t))
+				strg.EXPECT().UserAllowed(gomock.Any(), 1, gomock.Any()).Return(true, nil)
+				handler := middlewares.AuthMiddleware(zap.NewNop().Sugar(), "/", rt.verifyKeys, strg)(
+					http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+				req := httptest.NewRequest(http.MethodGet, "/api/user/orders", nil)
+				req.RemoteAddr = "192.0.2.1:1234"
+				req.Header.Set("Authorization", oldToken)
+				req.Header.Set("User-Agent", "")
+				w := httptest.NewRecorder()
+				handler.ServeHTTP(w, req)
+				if w.Code != http.StatusOK {
+					t.Errorf("token signed by previous key rejected: %d", w.Code)
+				}
+			},
+		},
+		{
+			name:     "Изменение CORS источников",
+			change:   func(cfg *ServerConfig) { cfg.CORSOrigins = []string{"https://example.com"} },
+			wantKeys: 1,
+			check: func(t *testing.T, rt *runtimeConfig) {
+				t.Helper()
+				if s := rt.load(); len(s.corsOrigins) != 1 || s.corsOrigins[0] != "https://example.com" {
+					t.Errorf("cors origins not changed: %v", s.corsOrigins)
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rt := newRuntimeConfig(cfg)
+			newCfg := *cfg
+			tt.change(&newCfg)
+			rt.apply(&newCfg, zap.NewNop().Sugar())
+			if len(rt.verifyKeys()) != tt.wantKeys {
+				t.Errorf("verify keys count = %d, want %d", len(rt.verifyKeys()), tt.wantKeys)
+			}
+			select {
+			case <-rt.changed:
+			default:
+				t.Error("poller is not notified about changes")
+			}
+			tt.check(t, rt)
+		})
+	}
+}
+
+func TestRuntimeConfigApplyTwice(t *testing.T) {
+	cfg := NewServerConfig()
+	cfg.AuthSecretKey = []byte("key")
+	rt := newRuntimeConfig(cfg)
+	core, logs := observer.New(zap.WarnLevel)
+	logger := zap.New(core).Sugar()
+	newCfg := *cfg
+	newCfg.ServerAddress = "localhost:9090"
+	rt.apply(&newCfg, logger)
+	if logs.Len() != 1 {
+		t.Fatalf("restart warnings after first reload = %d, want 1", logs.Len())
+	}
+	again := newCfg
+	again.AccrualWorkers = 7
+	rt.apply(&again, logger)
+	if logs.Len() != 1 {
+		t.Errorf("restart warnings after second reload = %d, want 1", logs.Len())
+	}
+	if rt.load().workers != 7 {
+		t.Errorf("accrual workers = %d, want 7", rt.load().workers)
+	}
+}
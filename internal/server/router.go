@@ -6,29 +6,224 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
 	"strconv"
+	"strings"
+	"sync"
 	"syscall"
 	"time"
 
 	"github.com/go-chi/chi"
 	"github.com/go-chi/chi/middleware"
-	"github.com/go-chi/cors"
 	"github.com/gostuding/goMarket/docs"
+	"github.com/gostuding/goMarket/internal/metrics"
+	"github.com/gostuding/goMarket/internal/ratelimit"
 	"github.com/gostuding/goMarket/internal/server/middlewares"
+	"github.com/gostuding/goMarket/internal/tracing"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 
 	httpSwagger "github.com/swaggo/http-swagger/v2"
 )
 
 type ServerConfig struct {
-	ServerAddress          string
-	AccuralAddress         string
-	AuthSecretKey          []byte
-	AuthTokenLiveTime      int
-	AccrualRequestInterval int
+	ServerAddress          string            `json:"address"`
+	AdminAddress           string            `json:"admin_address"`
+	AccuralAddress         string            `json:"accrual_address"`
+	TLSCertFile            string            `json:"tls_cert_file"`
+	TLSKeyFile             string            `json:"tls_key_file"`
+	TLSMinVersion          string            `json:"tls_min_version"`
+	AdminClientCAFile      string            `json:"admin_client_ca_file"`
+	HTTPRedirectAddress    string            `json:"http_redirect_address"`
+	TLSCipherSuites        []string          `json:"tls_cipher_suites"`
+	RateLimits             map[string]string `json:"rate_limits"`
+	RateLimitStore         string            `json:"rate_limit_store"`
+	BodyLimits             map[string]int64  `json:"body_limits"`
+	MaxBodySize            int64             `json:"max_body_size"`
+	MaxDecompressedSize    int64             `json:"max_decompressed_size"`
+	ReadHeaderTimeout      int               `json:"read_header_timeout"`
+	ReadTimeout            int               `json:"read_timeout"`
+	WriteTimeout           int               `json:"write_timeout"`
+	IdleTimeout            int               `json:"idle_timeout"`
+	CORSOrigins            []string          `json:"cors_origins"`
+	TrustedProxies         []string          `json:"trusted_proxies"`
+	OrderValidators        []string          `json:"order_validators"`
+	OrderPrefixes          map[string]string `json:"order_prefixes"`
+	OrderPattern           string            `json:"order_pattern"`
+	OrderMinLength         int               `json:"order_min_length"`
+	OrderMaxLength         int               `json:"order_max_length"`
+	AuthSecretKey          []byte            `json:"-"`
+	AuthTokenLiveTime      int               `json:"token_live_time"`
+	AccrualRequestInterval int               `json:"accrual_request_interval"`
+	AccrualWorkers         int               `json:"accrual_workers"`
+	PollerStaleTimeout     int               `json:"poller_stale_timeout"`
+	ShutdownDelay          int               `json:"shutdown_delay"`
+	IdempotencyTTL         int               `json:"idempotency_ttl"`
+	WithdrawCancelWindow   int               `json:"withdraw_cancel_window"`
+	HoldTTL                int               `json:"hold_ttl"`
+	TransferConfirmTTL     int               `json:"transfer_confirm_ttl"`
+	TransferDailyCount     int               `json:"transfer_daily_count"`
+	TiersRecalcHour        int               `json:"tiers_recalc_hour"`
+	TransferDailyLimit     float64           `json:"transfer_daily_limit"`
+	MetricsAdminOnly       bool              `json:"metrics_admin_only"`
+}
+
+func checkAddress(name, address string) error {
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		return fmt.Errorf("%s '%s' incorrect: %w", name, address, err)
+	}
+	return nil
+}
+
+// Validate checks server options values.
+func (cfg *ServerConfig) Validate() error {
+	errs := []error{checkAddress("server address", cfg.ServerAddress)}
+	if cfg.AdminAddress != "" {
+		errs = append(errs, checkAddress("admin address", cfg.AdminAddress))
+	}
+	if u, err := url.Parse(cfg.AccuralAddress); err != nil || u.Scheme == "" || u.Host == "" {
+		errs = append(errs, fmt.Errorf("accrual address '%s' must be absolute url", cfg.AccuralAddress))
+	}
+	if len(cfg.AuthSecretKey) == 0 {
+		errs = append(errs, errors.New("token key is empty"))
+	}
+	if cfg.AuthTokenLiveTime <= 0 {
+		errs = append(errs, fmt.Errorf("token live time must be positive, got %d", cfg.AuthTokenLiveTime))
+	}
+	if cfg.AccrualRequestInterval <= 0 {
+		errs = append(errs, fmt.Errorf("accrual request interval must be positive, got %d",
+			cfg.AccrualRequestInterval))
+	}
+	if cfg.AccrualWorkers <= 0 {
+		errs = append(errs, fmt.Errorf("accrual workers count must be positive, got %d", cfg.AccrualWorkers))
+	}
+	if len(cfg.CORSOrigins) == 0 {
+		errs = append(errs, errors.New("cors origins list is empty"))
+	}
+	if cfg.PollerStaleTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("poller stale timeout must be positive, got %d", cfg.PollerStaleTimeout))
+	}
+	if cfg.ShutdownDelay < 0 {
+		errs = append(errs, fmt.Errorf("shutdown delay must not be negative, got %d", cfg.ShutdownDelay))
+	}
+	if cfg.IdempotencyTTL <= 0 {
+		errs = append(errs, fmt.Errorf("idempotency key live time must be positive, got %d", cfg.IdempotencyTTL))
+	}
+	if cfg.WithdrawCancelWindow < 0 {
+		errs = append(errs, fmt.Errorf("withdraw cancel window must not be negative, got %d",
+			cfg.WithdrawCancelWindow))
+	}
+	if cfg.HoldTTL <= 0 {
+		errs = append(errs, fmt.Errorf("hold live time must be positive, got %d", cfg.HoldTTL))
+	}
+	if cfg.TransferConfirmTTL <= 0 {
+		errs = append(errs, fmt.Errorf("transfer confirm time must be positive, got %d", cfg.TransferConfirmTTL))
+	}
+	if cfg.TransferDailyCount < 0 || cfg.TransferDailyLimit < 0 {
+		errs = append(errs, errors.New("transfer daily limits must not be negative"))
+	}
+	if cfg.TiersRecalcHour < 0 || cfg.TiersRecalcHour > maxHour {
+		errs = append(errs, fmt.Errorf("tiers recalc hour must be in [0, %d], got %d", maxHour, cfg.TiersRecalcHour))
+	}
+	errs = append(errs, cfg.checkTLS()...)
+	errs = append(errs, cfg.checkLimits()...)
+	errs = append(errs, cfg.checkRateLimits()...)
+	if _, err := parseTrustedProxies(cfg.TrustedProxies); err != nil {
+		errs = append(errs, err)
+	}
+	if _, err := cfg.orderValidator(); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// checkLimits validates server timeouts and request body limits.
+func (cfg *ServerConfig) checkLimits() []error {
+	errs := make([]error, 0)
+	if cfg.ReadHeaderTimeout <= 0 || cfg.ReadTimeout <= 0 || cfg.WriteTimeout <= 0 || cfg.IdleTimeout <= 0 {
+		errs = append(errs, errors.New("server timeouts must be positive"))
+	}
+	if cfg.MaxBodySize <= 0 || cfg.MaxDecompressedSize <= 0 {
+		errs = append(errs, errors.New("max body size and max decompressed size must be positive"))
+	}
+	for path, size := range cfg.BodyLimits {
+		if !strings.HasPrefix(path, "/") || size <= 0 {
+			errs = append(errs, fmt.Errorf("body limit for '%s' incorrect: %d", path, size))
+		}
+	}
+	return errs
+}
+
+// checkRateLimits validates rate limit rules. Rules are set for "METHOD /path" routes or "*" for all requests.
+func (cfg *ServerConfig) checkRateLimits() []error {
+	errs := make([]error, 0)
+	if cfg.RateLimitStore != ratelimit.StoreMemory && cfg.RateLimitStore != ratelimit.StorePostgres {
+		errs = append(errs, fmt.Errorf("unknown rate limit store: '%s'", cfg.RateLimitStore))
+	}
+	if _, err := parseRateLimits(cfg.RateLimits); err != nil {
+		errs = append(errs, err)
+	}
+	return errs
+}
+
+func parseRateLimits(values map[string]string) (map[string]ratelimit.Rule, error) {
+	rules := make(map[string]ratelimit.Rule, len(values))
+	errs := make([]error, 0)
+	for route, value := range values {
+		if method, path, ok := strings.Cut(route, " "); route != allRoutes && (!ok || method == "" ||
+			!strings.HasPrefix(path, "/")) {
+			errs = append(errs, fmt.Errorf("rate limit route '%s' must be in 'METHOD /path' format", route))
+			continue
+		}
+		rule, err := ratelimit.ParseRule(value)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		rules[route] = rule
+	}
+	return rules, errors.Join(errs...)
+}
+
+// parseTrustedProxies parses proxies addresses or networks in CIDR notation.
+func parseTrustedProxies(values []string) ([]*net.IPNet, error) {
+	networks := make([]*net.IPNet, 0, len(values))
+	errs := make([]error, 0)
+	for _, value := range values {
+		if !strings.Contains(value, "/") {
+			if ip := net.ParseIP(value); ip != nil && ip.To4() != nil {
+				value += "/32"
+			} else {
+				value += "/128"
+			}
+		}
+		_, network, err := net.ParseCIDR(value)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("trusted proxy '%s' incorrect: %w", value, err))
+			continue
+		}
+		networks = append(networks, network)
+	}
+	return networks, errors.Join(errs...)
+}
+
+// newHTTPServer creates server with configured timeouts.
+func (cfg *ServerConfig) newHTTPServer(address string, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              address,
+		Handler:           handler,
+		ReadHeaderTimeout: time.Duration(cfg.ReadHeaderTimeout) * time.Second,
+		ReadTimeout:       time.Duration(cfg.ReadTimeout) * time.Second,
+		WriteTimeout:      time.Duration(cfg.WriteTimeout) * time.Second,
+		IdleTimeout:       time.Duration(cfg.IdleTimeout) * time.Second,
+	}
 }
 
 func NewServerConfig() *ServerConfig {
@@ -36,7 +231,36 @@ func NewServerConfig() *ServerConfig {
 		ServerAddress:          "localhost:8080",
 		AccuralAddress:         "http://localhost:8081",
 		AccrualRequestInterval: defaultAccrualRequestInterval,
-		AuthTokenLiveTime:      defaultAuthTokenLiveTime,
+		AccrualWorkers:         defaultRequestPoll,
+		CORSOrigins:            []string{"https://*", "http://*"},
+		OrderValidators:        []string{ValidatorLuhn},
+		OrderPrefixes:          map[string]string{},
+		TLSMinVersion:          defaultTLSVersion,
+		RateLimitStore:         ratelimit.StoreMemory,
+		RateLimits:             map[string]string{},
+		ReadHeaderTimeout:      defaultReadHeaderTimeout,
+		ReadTimeout:            defaultReadTimeout,
+		WriteTimeout:           defaultWriteTimeout,
+		IdleTimeout:            defaultIdleTimeout,
+		MaxBodySize:            defaultMaxBodySize,
+		MaxDecompressedSize:    defaultMaxDecompressedSize,
+		BodyLimits: map[string]int64{
+			registerURL:   defaultAuthBodySize,
+			loginURL:      defaultAuthBodySize,
+			ordersListURL: defaultOrderBodySize,
+			ordersBulkURL: defaultOrdersBulkBodySize,
+			withdrawURL:   defaultAuthBodySize,
+			holdsURL:      defaultAuthBodySize,
+			transferURL:   defaultAuthBodySize,
+			promoURL:      defaultAuthBodySize,
+		},
+		AuthTokenLiveTime:    defaultAuthTokenLiveTime,
+		PollerStaleTimeout:   defaultPollerStaleTimeout,
+		IdempotencyTTL:       defaultIdempotencyTTL,
+		WithdrawCancelWindow: defaultWithdrawCancelWindow,
+		HoldTTL:              defaultHoldTTL,
+		TransferConfirmTTL:   defaultTransferConfirmTTL,
+		TiersRecalcHour:      defaultTiersRecalcHour,
 	}
 }
 
@@ -48,8 +272,8 @@ type requestResponce struct {
 }
 
 type CheckOrdersStorage interface {
-	GetAccrualOrders() []string
-	SetOrderData(string, string, float32) error
+	GetAccrualOrders(context.Context) ([]string, error)
+	SetOrderData(context.Context, string, string, float32) (float32, error)
 }
 
 type ordersStatus struct {
@@ -58,178 +282,449 @@ type ordersStatus struct {
 	Accrual float32 `json:"accrual"`
 }
 
-func loginRegistrationCommon(w http.ResponseWriter, r *http.Request, logger *zap.SugaredLogger, key []byte,
-	strg Storage, tlt int,
+func newRequestResponce(w http.ResponseWriter, r *http.Request, strg Storage,
+	logger *zap.SugaredLogger) requestResponce {
+	return requestResponce{r: r, w: w, strg: strg, logger: middlewares.RequestLogger(r.Context(), logger)}
+}
+
+func loginRegistrationCommon(w http.ResponseWriter, r *http.Request, logger *zap.SugaredLogger, rt *runtimeConfig,
+	strg Storage,
 	mainFunc func(context.Context, []byte, []byte, string, string, Storage, int) (string, int, error)) {
-	body, err := io.ReadAll(r.Body)
-	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		logger.Warnf(readRequestErrorString, err)
+	logger = middlewares.RequestLogger(r.Context(), logger)
+	body, ok := readRequestBody(w, r, logger)
+	if !ok {
 		return
 	}
-	token, status, err := mainFunc(r.Context(), body, key, r.RemoteAddr, r.UserAgent(), strg, tlt)
+	token, status, err := mainFunc(r.Context(), body, rt.signingKey(), r.RemoteAddr, r.UserAgent(), strg,
+		rt.load().tokenLiveTime)
 	if err != nil {
-		logger.Warnf("storage error: %w", err)
+		logger.Warnf("storage error: %v", err)
 	}
 	w.Header().Set("Authorization", token)
 	w.WriteHeader(status)
 }
 
-func makeRouter(strg Storage, logger *zap.SugaredLogger, key []byte, tokenLiveTime int, address string) http.Handler {
-	var loginURL = "/api/user/login"
-	var ordersListURL = "/api/user/orders"
+func makeRouter(cfg *ServerConfig, strg Storage, logger *zap.SugaredLogger, hlth *health,
+	rt *runtimeConfig, validator OrderValidator) http.Handler {
+	address := cfg.ServerAddress
+	scheme := "http"
+	if cfg.TLSEnabled() {
+		scheme = "https"
+	}
+	var limiter ratelimit.Store = ratelimit.NewMemoryStore()
+	if cfg.RateLimitStore == ratelimit.StorePostgres {
+		limiter = strg
+	}
+	// rateLimit limits requests to route by user id after authorization and by ip before.
+	rateLimit := func(method, path string, byUser bool) func(http.Handler) http.Handler {
+		return middlewares.RateLimitMiddleware(logger, limiter, method+" "+path, rt.rateLimit, byUser)
+	}
+	// limit returns body size limit for route, applied to decompressed body.
+	limit := func(path string) func(http.Handler) http.Handler {
+		if size, ok := cfg.BodyLimits[path]; ok {
+			return middlewares.BodyLimitMiddleware(size)
+		}
+		return middlewares.BodyLimitMiddleware(cfg.MaxBodySize)
+	}
+	idempotent := middlewares.IdempotencyMiddleware(logger, strg, time.Duration(cfg.IdempotencyTTL)*time.Second)
+	proxies, err := parseTrustedProxies(cfg.TrustedProxies)
+	if err != nil {
+		logger.Warnf("trusted proxies error: %v", err)
+	}
 	router := chi.NewRouter()
 	docs.SwaggerInfo.Host = address
-	router.Use(middleware.RealIP, middlewares.GzipMiddleware(logger), middleware.Recoverer,
-		cors.Handler(cors.Options{
-			AllowedOrigins: []string{"https://*", "http://*"},
-			AllowedMethods: []string{"GET", "POST", "OPTIONS"},
-		}),
+	docs.SwaggerInfo.Schemes = []string{scheme}
+	router.Use(middlewares.RealIPMiddleware(proxies), middlewares.TracingMiddleware, middlewares.LoggerMiddleware(logger),
+		middlewares.MetricsMiddleware,
+		middlewares.RateLimitMiddleware(logger, limiter, allRoutes, rt.rateLimit, false),
+		middlewares.BodyLimitMiddleware(cfg.MaxBodySize), middlewares.GzipMiddleware(logger, cfg.MaxDecompressedSize),
+		middleware.Recoverer, rt.corsMiddleware,
 	)
 
-	router.Post("/api/user/register", func(w http.ResponseWriter, r *http.Request) {
-		loginRegistrationCommon(w, r, logger, key, strg, tokenLiveTime, Register)
-	})
+	router.With(rateLimit(http.MethodPost, registerURL, false), limit(registerURL)).
+		Post(registerURL, func(w http.ResponseWriter, r *http.Request) {
+			loginRegistrationCommon(w, r, logger, rt, strg, Register)
+		})
 
-	router.Post(loginURL, func(w http.ResponseWriter, r *http.Request) {
-		loginRegistrationCommon(w, r, logger, key, strg, tokenLiveTime, Login)
-	})
+	router.With(rateLimit(http.MethodPost, loginURL, false), limit(loginURL)).
+		Post(loginURL, func(w http.ResponseWriter, r *http.Request) {
+			loginRegistrationCommon(w, r, logger, rt, strg, Login)
+		})
 
 	router.Get("/swagger/*", httpSwagger.Handler(
-		httpSwagger.URL(fmt.Sprintf("http://%s/swagger/doc.json", address)),
+		httpSwagger.URL(fmt.Sprintf("%s://%s/swagger/doc.json", scheme, address)),
 	))
 
+	if !cfg.MetricsAdminOnly || cfg.AdminAddress == "" {
+		router.Method(http.MethodGet, metricsURL, metrics.Handler())
+	}
+
+	router.Get("/healthz", hlth.Liveness)
+	router.Get("/readyz", hlth.Readiness)
+
 	router.Get("/favicon.ico", func(w http.ResponseWriter, r *http.Request) {
+		logger := middlewares.RequestLogger(r.Context(), logger)
 		fileBytes, err := os.ReadFile("./static/icon.png")
 		if err != nil {
-			logger.Warnf("icon not found: %w", err)
+			logger.Warnf("icon not found: %v", err)
 			w.WriteHeader(http.StatusNotFound)
 			return
 		}
 		w.Header().Set("Content-Type", "application/octet-stream")
 		_, err = w.Write(fileBytes)
 		if err != nil {
-			logger.Warnf("write icon file error: %w", err)
+			logger.Warnf("write icon file error: %v", err)
 		}
 	})
 
 	router.Group(func(r chi.Router) {
-		r.Use(middlewares.AuthMiddleware(logger, loginURL, key))
+		r.Use(middlewares.AuthMiddleware(logger, loginURL, rt.verifyKeys, strg))
 
-		r.Get(ordersListURL, func(w http.ResponseWriter, r *http.Request) {
-			GetOrdersList(requestResponce{r: r, w: w, strg: strg, logger: logger})
-		})
+		r.With(rateLimit(http.MethodGet, ordersListURL, true)).
+			Get(ordersListURL, func(w http.ResponseWriter, r *http.Request) {
+				GetOrdersList(newRequestResponce(w, r, strg, logger))
+			})
+
+		r.With(rateLimit(http.MethodPost, ordersListURL, true), limit(ordersListURL), idempotent).
+			Post(ordersListURL, func(w http.ResponseWriter, r *http.Request) {
+				AddOrder(newRequestResponce(w, r, strg, logger), validator)
+			})
 
-		r.Post(ordersListURL, func(w http.ResponseWriter, r *http.Request) {
-			AddOrder(requestResponce{r: r, w: w, strg: strg, logger: logger})
+		r.With(rateLimit(http.MethodPost, ordersBulkURL, true), limit(ordersBulkURL), idempotent).
+			Post(ordersBulkURL, func(w http.ResponseWriter, r *http.Request) {
+				AddOrders(newRequestResponce(w, r, strg, logger), validator)
+			})
+
+		r.With(rateLimit(http.MethodGet, balanceURL, true)).Get(balanceURL, func(w http.ResponseWriter, r *http.Request) {
+			GetUserBalance(newRequestResponce(w, r, strg, logger))
 		})
 
-		r.Get("/api/user/balance", func(w http.ResponseWriter, r *http.Request) {
-			GetUserBalance(requestResponce{r: r, w: w, strg: strg, logger: logger})
+		r.With(rateLimit(http.MethodPost, withdrawURL, true), limit(withdrawURL), idempotent).
+			Post(withdrawURL, func(w http.ResponseWriter, r *http.Request) {
+				AddWithdraw(newRequestResponce(w, r, strg, logger),
+					time.Duration(cfg.WithdrawCancelWindow)*time.Second, validator)
+			})
+
+		r.With(rateLimit(http.MethodGet, withdrawalsURL, true)).
+			Get(withdrawalsURL, func(w http.ResponseWriter, r *http.Request) {
+				GetWithdrawsList(newRequestResponce(w, r, strg, logger))
+			})
+
+		r.With(rateLimit(http.MethodPost, withdrawCancelURL, true)).
+			Post(withdrawCancelURL, func(w http.ResponseWriter, r *http.Request) {
+				CancelWithdraw(newRequestResponce(w, r, strg, logger))
+			})
+
+		r.With(rateLimit(http.MethodPost, holdsURL, true), limit(holdsURL), idempotent).
+			Post(holdsURL, func(w http.ResponseWriter, r *http.Request) {
+				AddHold(newRequestResponce(w, r, strg, logger), time.Duration(cfg.HoldTTL)*time.Second, validator)
+			})
+
+		r.With(rateLimit(http.MethodPost, holdCaptureURL, true)).
+			Post(holdCaptureURL, func(w http.ResponseWriter, r *http.Request) {
+				CaptureHold(newRequestResponce(w, r, strg, logger), time.Duration(cfg.WithdrawCancelWindow)*time.Second)
+			})
+
+		r.With(rateLimit(http.MethodPost, holdReleaseURL, true)).
+			Post(holdReleaseURL, func(w http.ResponseWriter, r *http.Request) {
+				ReleaseHold(newRequestResponce(w, r, strg, logger))
+			})
+
+		r.With(rateLimit(http.MethodPost, transferURL, true), limit(transferURL), idempotent).
+			Post(transferURL, func(w http.ResponseWriter, r *http.Request) {
+				AddTransfer(newRequestResponce(w, r, strg, logger), time.Duration(cfg.TransferConfirmTTL)*time.Second)
+			})
+
+		r.With(rateLimit(http.MethodPost, transferConfirmURL, true)).
+			Post(transferConfirmURL, func(w http.ResponseWriter, r *http.Request) {
+				ConfirmTransfer(newRequestResponce(w, r, strg, logger), transferLimits{
+					sum: float32(cfg.TransferDailyLimit), count: cfg.TransferDailyCount})
+			})
+
+		r.With(rateLimit(http.MethodGet, historyURL, true)).Get(historyURL, func(w http.ResponseWriter, r *http.Request) {
+			GetHistoryList(newRequestResponce(w, r, strg, logger))
 		})
 
-		r.Post("/api/user/balance/withdraw", func(w http.ResponseWriter, r *http.Request) {
-			AddWithdraw(requestResponce{r: r, w: w, strg: strg, logger: logger})
+		r.With(rateLimit(http.MethodGet, tierURL, true)).Get(tierURL, func(w http.ResponseWriter, r *http.Request) {
+			GetUserTier(newRequestResponce(w, r, strg, logger))
 		})
 
-		r.Get("/api/user/withdrawals", func(w http.ResponseWriter, r *http.Request) {
-			GetWithdrawsList(requestResponce{r: r, w: w, strg: strg, logger: logger})
+		r.With(rateLimit(http.MethodPost, promoURL, true), limit(promoURL)).
+			Post(promoURL, func(w http.ResponseWriter, r *http.Request) {
+				RedeemPromo(newRequestResponce(w, r, strg, logger))
+			})
+
+		r.With(rateLimit(http.MethodGet, referralsURL, true)).Get(referralsURL, func(w http.ResponseWriter, r *http.Request) {
+			GetReferrals(newRequestResponce(w, r, strg, logger))
 		})
 	})
 
 	return router
 }
 
-func RunServer(cfg *ServerConfig, strg Storage, logger *zap.SugaredLogger) error {
+// makeAdminRouter creates administrative routes. Refund route changes users balances, so it is registered
+// only if clients of administrative server are authenticated by certificates (mTLS).
+func makeAdminRouter(logLevel zap.AtomicLevel, strg Storage, logger *zap.SugaredLogger, mTLS bool) http.Handler {
+	router := chi.NewRouter()
+	router.Use(middleware.Recoverer)
+	router.Method(http.MethodGet, "/log/level", logLevel)
+	router.Method(http.MethodPut, "/log/level", logLevel)
+	router.Method(http.MethodGet, metricsURL, metrics.Handler())
+	if !mTLS {
+		logger.Infoln("Withdrawals refund is disabled: admin client ca is not set")
+		return router
+	}
+	router.Post(withdrawRefundURL, func(w http.ResponseWriter, r *http.Request) {
+		RefundWithdraw(newRequestResponce(w, r, strg, logger))
+	})
+	return router
+}
+
+// listen starts https listener if server has tls config and http listener otherwise.
+func listen(srv *http.Server) error {
+	if srv.TLSConfig != nil {
+		return srv.ListenAndServeTLS("", "") //nolint:wrapcheck // <- returned as is
+	}
+	return srv.ListenAndServe() //nolint:wrapcheck // <- returned as is
+}
+
+// runAuxServer runs admin or redirect server until ctx is done.
+func runAuxServer(ctx context.Context, name string, srv *http.Server, logger *zap.SugaredLogger) {
+	go func() {
+		<-ctx.Done()
+		shtCtx, cancelFunc := context.WithTimeout(context.Background(), time.Duration(shutdownTimeout)*time.Second)
+		defer cancelFunc()
+		if err := srv.Shutdown(shtCtx); err != nil {
+			logger.Warnf("shutdown %s server erorr: %v", name, err)
+		}
+	}()
+	logger.Infof("Run %s server at adress: %s", name, srv.Addr)
+	if err := listen(srv); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		logger.Warnf("%s server listen error: %v", name, err)
+	}
+}
+
+// reloadOnSignal applies configuration returned by reload on every SIGHUP.
+// Invalid configuration is rejected and the current one is kept.
+func reloadOnSignal(ctx context.Context, reload ConfigReloader, rt *runtimeConfig, hlth *health,
+	logger *zap.SugaredLogger) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			logger.Infoln("SIGHUP received, reload config")
+			cfg, err := reload()
+			if err != nil {
+				logger.Warnf("config reload rejected: %v", err)
+				continue
+			}
+			rt.apply(cfg, logger)
+			hlth.setInterval(rt.load().interval)
+		}
+	}
+}
+
+func RunServer(cfg *ServerConfig, strg Storage, logger *zap.SugaredLogger, logLevel zap.AtomicLevel,
+	reload ConfigReloader) error {
 	if cfg == nil {
 		return errors.New("server options is nil")
 	}
 	logger.Infof("Run server at adress: %s", cfg.ServerAddress)
-	handler := makeRouter(strg, logger, cfg.AuthSecretKey, cfg.AuthTokenLiveTime, cfg.ServerAddress)
-	ctx, cancelFunc := signal.NotifyContext(context.Background(), os.Interrupt)
+	hlth := newHealth(strg, logger, time.Duration(cfg.AccrualRequestInterval)*time.Second,
+		time.Duration(cfg.PollerStaleTimeout)*time.Second)
+	rt := newRuntimeConfig(cfg)
+	validator, err := cfg.orderValidator()
+	if err != nil {
+		return fmt.Errorf("order validator error: %w", err)
+	}
+	handler := makeRouter(cfg, strg, logger, hlth, rt, validator)
+	ctx, cancelFunc := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer cancelFunc()
-
-	serverFinishError := make(chan error, 1)
-	srv := http.Server{Addr: cfg.ServerAddress, Handler: handler}
-	go timeRequest(ctx, fmt.Sprintf("%s/api/orders", cfg.AccuralAddress),
-		logger, strg, cfg.AccrualRequestInterval)
-
-	go func() {
-		err := srv.ListenAndServe()
-		if errors.Is(err, http.ErrServerClosed) {
-			serverFinishError <- nil
-		} else {
-			serverFinishError <- err
-			logger.Warnf("server lister error: %w", err)
+	if reload != nil {
+		go reloadOnSignal(ctx, reload, rt, hlth, logger)
+	}
+	srv := cfg.newHTTPServer(cfg.ServerAddress, handler)
+	adminSrv := cfg.newHTTPServer(cfg.AdminAddress, makeAdminRouter(logLevel, strg, logger,
+		cfg.AdminClientCAFile != ""))
+	if cfg.TLSEnabled() {
+		cr, err := newCertReloader(cfg.TLSCertFile, cfg.TLSKeyFile)
+		if err != nil {
+			return err
 		}
-		logger.Debugln("Server listen finished")
-		if err := strg.Close(); err != nil {
-			logger.Warnf("close storage connection error: %w", err)
+		go cr.watch(ctx, certReloadInterval, logger)
+		srv.TLSConfig = newTLSConfig(cfg, cr)
+		if adminSrv.TLSConfig, err = newAdminTLSConfig(cfg, cr); err != nil {
+			return err
+		}
+		if cfg.HTTPRedirectAddress != "" {
+			redirectSrv := cfg.newHTTPServer(cfg.HTTPRedirectAddress, redirectHandler(cfg.ServerAddress))
+			go runAuxServer(ctx, "redirect", redirectSrv, logger)
 		}
-		close(serverFinishError)
+	}
+	if cfg.AdminAddress != "" {
+		go runAuxServer(ctx, "admin", adminSrv, logger)
+	}
+
+	// Poller and background jobs stop taking new work on pollerStop, in-flight requests use workCtx
+	// and are cancelled only if they do not finish before the shutdown deadline.
+	pollerStop, stopPoller := context.WithCancel(context.Background())
+	defer stopPoller()
+	workCtx, cancelWork := context.WithCancel(context.Background())
+	defer cancelWork()
+	pollerDone := make(chan struct{})
+	jobs := []backgroundJob{
+		{name: "expire holds", interval: holdsExpireInterval, run: func(ctx context.Context) (int64, error) {
+			count, err := strg.ExpireHolds(ctx)
+			metrics.Holds.WithLabelValues("expired").Add(float64(count))
+			return count, err //nolint:wrapcheck // <- storage errors are wrapped
+		}},
+		{name: "expire points", interval: pointsExpireInterval, run: strg.ExpirePoints},
+		{name: "recalculate tiers", interval: time.Duration(cfg.TiersRecalcHour) * time.Hour, daily: true,
+			run: strg.RecalculateTiers},
+	}
+	go func() {
+		defer close(pollerDone)
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			runJobs(pollerStop, workCtx, jobs, logger)
+		}()
+		timeRequest(pollerStop, workCtx, fmt.Sprintf("%s/api/orders", cfg.AccuralAddress),
+			logger, strg, rt, hlth.poller)
+		wg.Wait()
 	}()
 
+	listenError := make(chan error, 1)
 	go func() {
-		<-ctx.Done()
-		shtCtx, cancelFunc := context.WithTimeout(context.Background(), time.Duration(shutdownTimeout)*time.Second)
-		defer cancelFunc()
-		if err := srv.Shutdown(shtCtx); err != nil {
-			logger.Warnf("shutdown server erorr: %w", err)
+		if err := listen(srv); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			listenError <- err
 		}
+		logger.Debugln("Server listen finished")
+		close(listenError)
 	}()
 
-	return <-serverFinishError
+	select {
+	case <-ctx.Done():
+		logger.Infoln("Shutdown signal received")
+	case err = <-listenError:
+		logger.Warnf("server lister error: %v", err)
+	}
+	cancelFunc()
+	sht := shutdown{
+		srv:        srv,
+		hlth:       hlth,
+		strg:       strg,
+		logger:     logger,
+		stopPoller: stopPoller,
+		cancelWork: cancelWork,
+		pollerDone: pollerDone,
+		delay:      time.Duration(cfg.ShutdownDelay) * time.Second,
+		timeout:    time.Duration(shutdownTimeout) * time.Second,
+	}
+	return errors.Join(err, sht.run())
 }
 
+// timeRequest polls accrual system until ctxStop is done, then waits for workers to finish.
+// Requests to accrual system and storage updates use ctxWork.
 func timeRequest(
 	ctxStop context.Context,
+	ctxWork context.Context,
 	url string,
 	logger *zap.SugaredLogger,
 	strg CheckOrdersStorage,
-	interval int,
+	rt *runtimeConfig,
+	state *pollerState,
 ) {
-	updateTicker := time.NewTicker(time.Duration(interval) * time.Second)
+	settings := rt.load()
+	updateTicker := time.NewTicker(settings.interval)
 	defer updateTicker.Stop()
-	sleepTime := time.Now()
+	state.started.Store(time.Now().UnixNano())
+	state.setBeat()
 	sleepChan := make(chan int, defaultRequestPoll)
 	errorChan := make(chan error, defaultRequestPoll)
 	ordersChan := make(chan string, defaultRequestPoll)
+	workersDone := make(chan struct{})
+	var wg sync.WaitGroup
 
 	go func() {
 		for {
 			select {
 			case secs := <-sleepChan:
 				logger.Debugf("wait accural system %d seconds", secs)
-				sleepTime = time.Now().Add(time.Duration(time.Duration(secs).Seconds()))
+				state.pause(time.Duration(secs) * time.Second)
 			case err := <-errorChan:
 				if errors.Is(err, syscall.ECONNREFUSED) {
 					logger.Debugln("accureal system connection refised")
 				} else {
-					logger.Warnf("accural request error: %w", err)
+					logger.Warnf("accural request error: %v", err)
 				}
-			case <-ctxStop.Done():
+			case <-workersDone:
 				return
 			}
 		}
 	}()
 
-	for i := 0; i < cap(ordersChan); i++ {
-		go createAccrualRequest(ordersChan, url, sleepChan, errorChan, strg)
+	// workers contains stop channels of running accrual request gorutines.
+	workers := make([]chan struct{}, 0, settings.workers)
+	resize := func(count int) {
+		for len(workers) < count {
+			stop := make(chan struct{})
+			workers = append(workers, stop)
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				createAccrualRequest(ctxWork, stop, ordersChan, url, sleepChan, errorChan, strg)
+			}()
+		}
+		for len(workers) > count {
+			close(workers[len(workers)-1])
+			workers = workers[:len(workers)-1]
+		}
 	}
+	resize(settings.workers)
 
 	for {
 		select {
 		case <-updateTicker.C:
-			if !time.Now().After(sleepTime) {
+			state.setBeat()
+			if state.isPaused() {
+				break
+			}
+			pollCtx, span := tracing.Tracer().Start(ctxStop, "accrual.poll")
+			numbers, err := strg.GetAccrualOrders(pollCtx)
+			if err != nil {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+				span.End()
+				logger.Warnf("accrual poll error: %v", err)
 				break
 			}
-			for _, order := range strg.GetAccrualOrders() {
-				ordersChan <- order
+		orders:
+			for _, order := range numbers {
+				select {
+				case ordersChan <- order:
+					metrics.AccrualQueueDepth.Set(float64(len(ordersChan)))
+				case <-ctxStop.Done():
+					break orders
+				}
 			}
+			span.End()
+			state.setPoll()
+		case <-rt.changed:
+			settings = rt.load()
+			updateTicker.Reset(settings.interval)
+			resize(settings.workers)
+			logger.Debugf("Accrual poller interval %v, workers %d", settings.interval, settings.workers)
 		case <-ctxStop.Done():
-			close(sleepChan)
-			close(errorChan)
-			close(ordersChan)
+			// Orders left in the queue keep their status and are requested after restart.
+			resize(0)
+			wg.Wait()
+			close(workersDone)
 			logger.Debugln("Accrual gorutine finished")
 			return
 		}
@@ -237,62 +732,89 @@ func timeRequest(
 }
 
 func createAccrualRequest(
+	ctx context.Context,
+	stop chan struct{},
 	ordersChan chan string,
 	baseURL string,
 	sleepChan chan int,
 	errorChan chan error,
 	strg CheckOrdersStorage,
 ) {
-	client := http.Client{}
-	for order := range ordersChan {
-		request(client, fmt.Sprintf("%s/%s", baseURL, order), sleepChan, errorChan, strg)
+	client := http.Client{Transport: otelhttp.NewTransport(http.DefaultTransport)}
+	for {
+		var order string
+		select {
+		case <-stop:
+			return
+		case order = <-ordersChan:
+		}
+		metrics.AccrualQueueDepth.Set(float64(len(ordersChan)))
+		reqCtx, span := tracing.Tracer().Start(ctx, "accrual.order",
+			trace.WithAttributes(tracing.OrderNumberKey.String(order)))
+		err := request(reqCtx, client, fmt.Sprintf("%s/%s", baseURL, order), sleepChan, strg)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			errorChan <- err
+		}
+		span.End()
 	}
 }
 
 func request(
+	ctx context.Context,
 	client http.Client,
 	url string,
 	sleepChan chan int,
-	errorChan chan error,
 	strg CheckOrdersStorage,
-) {
-	req, err := http.NewRequest(http.MethodGet, url, nil)
+) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
-		errorChan <- fmt.Errorf("create request error: %w", err)
+		metrics.AccrualRequests.WithLabelValues(metrics.AccrualResultError).Inc()
+		return fmt.Errorf("create request error: %w", err)
 	}
 	resp, err := client.Do(req)
 	if err != nil {
-		errorChan <- fmt.Errorf("do request error: %w", err)
-		return
+		metrics.AccrualRequests.WithLabelValues(metrics.AccrualResultError).Inc()
+		return fmt.Errorf("do request error: %w", err)
 	}
 	defer resp.Body.Close() //nolint:errcheck // <- senselessly
 	if resp.StatusCode == http.StatusTooManyRequests {
+		metrics.AccrualRequests.WithLabelValues(metrics.AccrualResultManyRequest).Inc()
+		metrics.AccrualPauses.Inc()
 		wait, err := strconv.Atoi(resp.Header.Get("Retry-After"))
 		if err != nil {
 			sleepChan <- manyRequestsWaitTimeDef
-			errorChan <- fmt.Errorf("too many requests. Default wait time. error: %w", err)
-			return
+			return fmt.Errorf("too many requests. Default wait time. error: %w", err)
 		}
 		sleepChan <- wait
-		return
+		return nil
 	}
 	if resp.StatusCode != http.StatusOK {
-		errorChan <- fmt.Errorf("responce order (%s) status code incorrect: %d", url, resp.StatusCode)
-		return
+		metrics.AccrualRequests.WithLabelValues(metrics.AccrualResultBadStatus).Inc()
+		return fmt.Errorf("responce order (%s) status code incorrect: %d", url, resp.StatusCode)
 	}
-	data, err := io.ReadAll(resp.Body)
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxAccrualResponseSize))
 	if err != nil {
-		errorChan <- fmt.Errorf("responce body read error: %w", err)
-		return
+		metrics.AccrualRequests.WithLabelValues(metrics.AccrualResultError).Inc()
+		return fmt.Errorf("responce body read error: %w", err)
 	}
 	var item ordersStatus
 	err = json.Unmarshal(data, &item)
 	if err != nil {
-		errorChan <- fmt.Errorf("json conver error: %w", err)
-		return
+		metrics.AccrualRequests.WithLabelValues(metrics.AccrualResultError).Inc()
+		return fmt.Errorf("json conver error: %w", err)
 	}
-	err = strg.SetOrderData(item.Order, item.Status, item.Accrual)
+	trace.SpanFromContext(ctx).SetAttributes(attribute.String("order.status", item.Status))
+	credited, err := strg.SetOrderData(ctx, item.Order, item.Status, item.Accrual)
 	if err != nil {
-		errorChan <- fmt.Errorf("set order data error: %w", err)
+		metrics.AccrualRequests.WithLabelValues(metrics.AccrualResultError).Inc()
+		return fmt.Errorf("set order data error: %w", err)
+	}
+	metrics.AccrualRequests.WithLabelValues(metrics.AccrualResultOK).Inc()
+	if credited > 0 {
+		metrics.PointsAccrued.Add(float64(credited))
 	}
+	metrics.SetAccrualSuccess()
+	return nil
 }
@@ -0,0 +1,55 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+type httpServer interface {
+	Shutdown(context.Context) error
+}
+
+// shutdown stops server parts in order: http server, accrual poller, storage.
+type shutdown struct {
+	srv        httpServer
+	hlth       *health
+	strg       io.Closer
+	logger     *zap.SugaredLogger
+	stopPoller context.CancelFunc
+	cancelWork context.CancelFunc
+	pollerDone <-chan struct{}
+	delay      time.Duration
+	timeout    time.Duration
+}
+
+func (s *shutdown) run() error {
+	s.hlth.shuttingDown.Store(true)
+	if s.delay > 0 {
+		s.logger.Debugf("Wait %v before shutdown", s.delay)
+		time.Sleep(s.delay)
+	}
+	ctx, cancelFunc := context.WithTimeout(context.Background(), s.timeout)
+	defer cancelFunc()
+	errs := make([]error, 0)
+	if err := s.srv.Shutdown(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("shutdown server error: %w", err))
+	}
+	s.stopPoller()
+	select {
+	case <-s.pollerDone:
+	case <-ctx.Done():
+		s.logger.Warnln("accrual requests are not finished in time and will be cancelled")
+		s.cancelWork()
+		<-s.pollerDone
+	}
+	s.logger.Debugln("Accrual poller stopped")
+	if err := s.strg.Close(); err != nil {
+		errs = append(errs, fmt.Errorf("close storage connection error: %w", err))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,183 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/gostuding/goMarket/internal/mocks"
+	"go.uber.org/zap"
+)
+
+type shutdownSteps struct {
+	steps []string
+	mutex sync.Mutex
+}
+
+func (s *shutdownSteps) add(step string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.steps = append(s.steps, step)
+}
+
+func (s *shutdownSteps) get() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]string{}, s.steps...)
+}
+
+type stepServer struct{ steps *shutdownSteps }
+
+func (s stepServer) Shutdown(context.Context) error {
+	s.steps.add("http")
+	return nil
+}
+
+type stepStorage struct{ steps *shutdownSteps }
+
+func (s stepStorage) Close() error {
+	s.steps.add("storage")
+	return nil
+}
+
+func TestShutdown(t *testing.T) {
+	tests := []struct {
+		name string
+		// poller emulates accrual poller which finishes after stop or work context cancel.
+		poller    func(stop, work context.Context, steps *shutdownSteps)
+		wantSteps []string
+	}{
+		{
+			name: "Обработчики завершились до истечения времени",
+			poller: func(stop, work context.Context, steps *shutdownSteps) {
+				<-stop.Done()
+				steps.add("poller")
+			},
+			wantSteps: []string{"http", "poller", "storage"},
+		},
+		{
+			name: "Обработчики прерваны по истечении времени",
+			poller: func(stop, work context.Context, steps *shutdownSteps) {
+				<-work.Done()
+				steps.add("cancel")
+			},
+			wantSteps: []string{"http", "cancel", "storage"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			steps := &shutdownSteps{}
+			stop, stopPoller := context.WithCancel(context.Background())
+			work, cancelWork := context.WithCancel(context.Background())
+			defer cancelWork()
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				tt.poller(stop, work, steps)
+			}()
+			hlth := newHealth(nil, zap.NewNop().Sugar(), time.Second, time.Second)
+			sht := shutdown{
+				srv:        stepServer{steps: steps},
+				hlth:       hlth,
+				strg:       stepStorage{steps: steps},
+				logger:     zap.NewNop().Sugar(),
+				stopPoller: stopPoller,
+				cancelWork: cancelWork,
+				pollerDone: done,
+				timeout:    50 * time.Millisecond,
+			}
+			if err := sht.run(); err != nil {
+				t.Errorf("shutdown error: %v", err)
+			}
+			if !hlth.shuttingDown.Load() {
+				t.Error("readiness is not switched to shutdown")
+			}
+			if got := fmt.Sprint(steps.get()); got != fmt.Sprint(tt.wantSteps) {
+				t.Errorf("shutdown steps = %s, want %v", got, tt.wantSteps)
+			}
+		})
+	}
+}
+
+func TestTimeRequestStop(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	strg := mocks.NewMockCheckOrdersStorage(ctrl)
+	started := make(chan struct{})
+	release := make(chan struct{})
+	accrual := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		fmt.Fprint(w, `{"order": "12345678903", "status": "PROCESSED", "accrual": 10}`)
+	}))
+	defer accrual.Close()
+	strg.EXPECT().GetAccrualOrders(gomock.Any()).Return([]string{"12345678903"}, nil)
+	strg.EXPECT().GetAccrualOrders(gomock.Any()).Return(nil, nil).AnyTimes()
+	strg.EXPECT().SetOrderData(gomock.Any(), "12345678903", "PROCESSED", float32(10)).Return(float32(10), nil)
+
+	cfg := NewServerConfig()
+	cfg.AuthSecretKey = []byte("key")
+	rt := newRuntimeConfig(cfg)
+	settings := *rt.load()
+	settings.interval, settings.workers = 10*time.Millisecond, 3
+	rt.settings.Store(&settings)
+
+	stop, stopPoller := context.WithCancel(context.Background())
+	work, cancelWork := context.WithCancel(context.Background())
+	defer cancelWork()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		timeRequest(stop, work, accrual.URL+"/api/orders", zap.NewNop().Sugar(), strg, rt, &pollerState{})
+	}()
+	select {
+	case <-started:
+	case <-time.After(time.Second):
+		t.Fatal("accrual request is not started")
+	}
+	stopPoller()
+	select {
+	case <-done:
+		t.Fatal("poller finished before in-flight request")
+	case <-time.After(20 * time.Millisecond):
+	}
+	close(release)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("poller is not finished after in-flight request")
+	}
+}
+
+func TestTimeRequestPollError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	strg := mocks.NewMockCheckOrdersStorage(ctrl)
+	strg.EXPECT().GetAccrualOrders(gomock.Any()).Return(nil, errors.New("connection refused")).MinTimes(1)
+
+	cfg := NewServerConfig()
+	cfg.AuthSecretKey = []byte("key")
+	rt := newRuntimeConfig(cfg)
+	settings := *rt.load()
+	settings.interval = time.Millisecond
+	rt.settings.Store(&settings)
+
+	stop, stopPoller := context.WithCancel(context.Background())
+	state := &pollerState{}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		timeRequest(stop, context.Background(), "http://localhost/api/orders", zap.NewNop().Sugar(), strg, rt, state)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	stopPoller()
+	<-done
+	if state.beat.Load() == 0 || state.lastPoll.Load() != 0 {
+		t.Errorf("poller state: beat %d, last poll %d, want beat and no poll", state.beat.Load(),
+			state.lastPoll.Load())
+	}
+}
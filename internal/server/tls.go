@@ -0,0 +1,205 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	certReloadInterval = 10 * time.Second
+	defaultTLSVersion  = "1.2"
+	defaultHTTPSPort   = "443"
+)
+
+var tlsVersions = map[string]uint16{
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// cipherSuite returns id of secure cipher suite by its name. TLS 1.3 suites are not configurable,
+// so only suites supported by TLS 1.2 are returned.
+func cipherSuite(name string) (uint16, bool) {
+	for _, item := range tls.CipherSuites() {
+		if item.Name != name {
+			continue
+		}
+		for _, version := range item.SupportedVersions {
+			if version == tls.VersionTLS12 {
+				return item.ID, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// checkTLS validates TLS options of server config.
+func (cfg *ServerConfig) checkTLS() []error {
+	errs := make([]error, 0)
+	if (cfg.TLSCertFile == "") != (cfg.TLSKeyFile == "") {
+		errs = append(errs, errors.New("tls certificate and key files must be set together"))
+	}
+	if _, ok := tlsVersions[cfg.TLSMinVersion]; !ok {
+		errs = append(errs, fmt.Errorf("tls min version '%s' incorrect, use 1.2 or 1.3", cfg.TLSMinVersion))
+	}
+	http2Suite := false
+	for _, name := range cfg.TLSCipherSuites {
+		id, ok := cipherSuite(name)
+		if !ok {
+			errs = append(errs, fmt.Errorf("unknown, insecure or tls 1.3 cipher suite: '%s'", name))
+		}
+		// HTTP/2 server requires one of these suites if the list is set.
+		if id == tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256 || id == tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256 {
+			http2Suite = true
+		}
+	}
+	if len(cfg.TLSCipherSuites) > 0 && !http2Suite {
+		errs = append(errs, errors.New("tls cipher suites must include TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256 "+
+			"or TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256 required by HTTP/2"))
+	}
+	if !cfg.TLSEnabled() && (cfg.AdminClientCAFile != "" || cfg.HTTPRedirectAddress != "") {
+		errs = append(errs, errors.New("admin client ca and http redirect require tls certificate"))
+	}
+	if cfg.AdminClientCAFile != "" && cfg.AdminAddress == "" {
+		errs = append(errs, errors.New("admin client ca is set, but admin address is empty"))
+	}
+	if cfg.HTTPRedirectAddress != "" {
+		if err := checkAddress("http redirect address", cfg.HTTPRedirectAddress); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// TLSEnabled returns true if server must listen https.
+func (cfg *ServerConfig) TLSEnabled() bool {
+	return cfg.TLSCertFile != "" && cfg.TLSKeyFile != ""
+}
+
+// certReloader loads certificate again when its files are changed on disk.
+type certReloader struct {
+	cert     atomic.Pointer[tls.Certificate]
+	certFile string
+	keyFile  string
+	modTime  time.Time
+}
+
+func newCertReloader(certFile, keyFile string) (*certReloader, error) {
+	cr := certReloader{certFile: certFile, keyFile: keyFile}
+	if _, err := cr.reloadIfChanged(); err != nil {
+		return nil, err
+	}
+	return &cr, nil
+}
+
+// lastModified returns the latest modification time of certificate and key files.
+func (cr *certReloader) lastModified() (time.Time, error) {
+	var last time.Time
+	for _, name := range []string{cr.certFile, cr.keyFile} {
+		info, err := os.Stat(name)
+		if err != nil {
+			return last, fmt.Errorf("tls file stat error: %w", err)
+		}
+		if info.ModTime().After(last) {
+			last = info.ModTime()
+		}
+	}
+	return last, nil
+}
+
+func (cr *certReloader) reloadIfChanged() (bool, error) {
+	modTime, err := cr.lastModified()
+	if err != nil {
+		return false, err
+	}
+	if modTime.Equal(cr.modTime) {
+		return false, nil
+	}
+	cert, err := tls.LoadX509KeyPair(cr.certFile, cr.keyFile)
+	if err != nil {
+		return false, fmt.Errorf("load tls certificate error: %w", err)
+	}
+	cr.cert.Store(&cert)
+	cr.modTime = modTime
+	return true, nil
+}
+
+// watch checks certificate files until ctx is done. On load error the previous certificate is used.
+func (cr *certReloader) watch(ctx context.Context, interval time.Duration, logger *zap.SugaredLogger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			changed, err := cr.reloadIfChanged()
+			if err != nil {
+				logger.Warnf("tls certificate reload error: %v", err)
+			} else if changed {
+				logger.Infof("TLS certificate reloaded from '%s'", cr.certFile)
+			}
+		}
+	}
+}
+
+func (cr *certReloader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return cr.cert.Load(), nil
+}
+
+// newTLSConfig returns server tls config. HTTP/2 is enabled by http.Server for tls listeners.
+func newTLSConfig(cfg *ServerConfig, cr *certReloader) *tls.Config {
+	tlsCfg := tls.Config{
+		MinVersion:     tlsVersions[cfg.TLSMinVersion],
+		GetCertificate: cr.getCertificate,
+		NextProtos:     []string{"h2", "http/1.1"},
+	}
+	for _, name := range cfg.TLSCipherSuites {
+		id, _ := cipherSuite(name)
+		tlsCfg.CipherSuites = append(tlsCfg.CipherSuites, id)
+	}
+	return &tlsCfg
+}
+
+// newAdminTLSConfig adds client certificate verification for admin listener.
+func newAdminTLSConfig(cfg *ServerConfig, cr *certReloader) (*tls.Config, error) {
+	tlsCfg := newTLSConfig(cfg, cr)
+	if cfg.AdminClientCAFile == "" {
+		return tlsCfg, nil
+	}
+	data, err := os.ReadFile(cfg.AdminClientCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("read admin client ca error: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("admin client ca '%s' has no certificates", cfg.AdminClientCAFile)
+	}
+	tlsCfg.ClientCAs = pool
+	tlsCfg.ClientAuth = tls.RequireAndVerifyClientCert
+	return tlsCfg, nil
+}
+
+// redirectHandler sends clients to https address of the server.
+func redirectHandler(httpsAddress string) http.Handler {
+	_, port, _ := net.SplitHostPort(httpsAddress)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.Host)
+		if err != nil {
+			host = r.Host
+		}
+		if port != defaultHTTPSPort {
+			host = net.JoinHostPort(host, port)
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+	})
+}
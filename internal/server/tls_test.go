@@ -0,0 +1,184 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeCert(t *testing.T, dir, name string, modTime time.Time) (string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key error: %v", err)
+	}
+	tmpl := x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &tmpl, &tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("create certificate error: %v", err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("marshal key error: %v", err)
+	}
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+	for file, data := range map[string][]byte{certFile: certPEM, keyFile: keyPEM} {
+		if err = os.WriteFile(file, data, 0o600); err != nil {
+			t.Fatalf("write file error: %v", err)
+		}
+		if err = os.Chtimes(file, modTime, modTime); err != nil {
+			t.Fatalf("change file time error: %v", err)
+		}
+	}
+	return certFile, keyFile
+}
+
+func certName(t *testing.T, cr *certReloader) string {
+	t.Helper()
+	cert, err := cr.getCertificate(nil)
+	if err != nil {
+		t.Fatalf("get certificate error: %v", err)
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatalf("parse certificate error: %v", err)
+	}
+	return leaf.Subject.CommonName
+}
+
+func TestCertReloader(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	certFile, keyFile := writeCert(t, dir, "first", now.Add(-time.Minute))
+	cr, err := newCertReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatalf("newCertReloader() error = %v", err)
+	}
+	if name := certName(t, cr); name != "first" {
+		t.Errorf("certificate = %s, want first", name)
+	}
+	if changed, err := cr.reloadIfChanged(); err != nil || changed {
+		t.Errorf("reload without changes: changed %v, error %v", changed, err)
+	}
+	writeCert(t, dir, "second", now)
+	if changed, err := cr.reloadIfChanged(); err != nil || !changed {
+		t.Errorf("reload after change: changed %v, error %v", changed, err)
+	}
+	if name := certName(t, cr); name != "second" {
+		t.Errorf("certificate = %s, want second", name)
+	}
+	if err = os.WriteFile(keyFile, []byte("broken"), 0o600); err != nil {
+		t.Fatalf("write file error: %v", err)
+	}
+	if _, err = cr.reloadIfChanged(); err == nil {
+		t.Error("broken key loaded without error")
+	}
+	if name := certName(t, cr); name != "second" {
+		t.Errorf("certificate after broken reload = %s, want second", name)
+	}
+}
+
+func TestRedirectHandler(t *testing.T) {
+	tests := []struct {
+		name     string
+		address  string
+		target   string
+		location string
+	}{
+		{name: "Нестандартный порт", address: "localhost:8443", target: "http://example.com:8080/api/user/orders?a=1",
+			location: "https://example.com:8443/api/user/orders?a=1"},
+		{name: "Стандартный порт", address: ":443", target: "http://example.com/swagger/",
+			location: "https://example.com/swagger/"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			redirectHandler(tt.address).ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.target, nil))
+			if w.Code != http.StatusPermanentRedirect || w.Header().Get("Location") != tt.location {
+				t.Errorf("redirect = %d %s, want %s", w.Code, w.Header().Get("Location"), tt.location)
+			}
+		})
+	}
+}
+
+func TestCheckTLS(t *testing.T) {
+	tests := []struct {
+		change  func(cfg *ServerConfig)
+		name    string
+		wantErr bool
+	}{
+		{name: "Без TLS", change: func(cfg *ServerConfig) {}},
+		{name: "Сертификат без ключа", change: func(cfg *ServerConfig) { cfg.TLSCertFile = "cert.pem" }, wantErr: true},
+		{name: "Неизвестная версия", change: func(cfg *ServerConfig) { cfg.TLSMinVersion = "1.0" }, wantErr: true},
+		{
+			name: "Разрешённые шифры",
+			change: func(cfg *ServerConfig) {
+				cfg.TLSCertFile, cfg.TLSKeyFile = "cert.pem", "key.pem"
+				cfg.TLSCipherSuites = []string{"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256"}
+			},
+		},
+		{
+			name:    "Небезопасный шифр",
+			change:  func(cfg *ServerConfig) { cfg.TLSCipherSuites = []string{"TLS_RSA_WITH_RC4_128_SHA"} },
+			wantErr: true,
+		},
+		{
+			name: "Нет шифра для HTTP/2",
+			change: func(cfg *ServerConfig) {
+				cfg.TLSCipherSuites = []string{"TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384"}
+			},
+			wantErr: true,
+		},
+		{
+			name: "Шифр TLS 1.3",
+			change: func(cfg *ServerConfig) {
+				cfg.TLSCipherSuites = []string{"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256", "TLS_AES_128_GCM_SHA256"}
+			},
+			wantErr: true,
+		},
+		{
+			name:    "Перенаправление без TLS",
+			change:  func(cfg *ServerConfig) { cfg.HTTPRedirectAddress = ":80" },
+			wantErr: true,
+		},
+		{
+			name: "TLS с корректным адресом перенаправления",
+			change: func(cfg *ServerConfig) {
+				cfg.TLSCertFile, cfg.TLSKeyFile, cfg.HTTPRedirectAddress = "cert.pem", "key.pem", ":80"
+			},
+		},
+		{
+			name: "TLS с неверным адресом перенаправления",
+			change: func(cfg *ServerConfig) {
+				cfg.TLSCertFile, cfg.TLSKeyFile, cfg.HTTPRedirectAddress = "cert.pem", "key.pem", "localhost"
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := NewServerConfig()
+			tt.change(cfg)
+			if errs := cfg.checkTLS(); (len(errs) > 0) != tt.wantErr {
+				t.Errorf("checkTLS() errors = %v, wantErr %v", errs, tt.wantErr)
+			}
+		})
+	}
+}
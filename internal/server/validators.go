@@ -0,0 +1,183 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Order number validators names.
+const (
+	ValidatorLuhn   = "luhn"
+	ValidatorLength = "length"
+	ValidatorPrefix = "prefix"
+	ValidatorRegex  = "regex"
+	ValidatorNone   = "none"
+)
+
+const luhnMinLength = 2
+
+var errOrderEmpty = errors.New("order number is empty")
+
+// OrderValidator checks normalized order number.
+type OrderValidator interface {
+	Validate(number string) error
+}
+
+// orderValidators is validators chain. Number is correct if all validators accept it.
+type orderValidators []OrderValidator
+
+func (chain orderValidators) Validate(number string) error {
+	for _, validator := range chain {
+		if err := validator.Validate(number); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// luhnValidator checks digits and control sum of number by Luhn algorithm.
+type luhnValidator struct{}
+
+func (luhnValidator) Validate(number string) error {
+	digits := make([]int, 0, len(number))
+	for i, r := range []rune(number) {
+		if r < '0' || r > '9' {
+			return fmt.Errorf("order number character %q at position %d is not a digit", r, i+1)
+		}
+		digits = append(digits, int(r-'0'))
+	}
+	if len(digits) < luhnMinLength {
+		return fmt.Errorf("order number '%s' is too short for control sum", number)
+	}
+	summ := 0
+	for i := range digits {
+		value := digits[len(digits)-1-i]
+		if i%2 == 1 {
+			value *= 2
+			if value > 9 { //nolint:gomnd // <- algoritm constants
+				value -= 9
+			}
+		}
+		summ += value
+	}
+	if summ%10 != 0 { //nolint:gomnd // <- algoritm constants
+		return fmt.Errorf("order control summ error. Order: '%s'", number)
+	}
+	return nil
+}
+
+// lengthValidator checks number length in characters. Zero max means no upper bound.
+type lengthValidator struct {
+	min int
+	max int
+}
+
+func (v lengthValidator) Validate(number string) error {
+	length := utf8.RuneCountInString(number)
+	if length < v.min || (v.max > 0 && length > v.max) {
+		return fmt.Errorf("order number '%s' length %d is out of [%d, %d]", number, length, v.min, v.max)
+	}
+	return nil
+}
+
+// prefixValidator checks that number starts with prefix of one of stores.
+type prefixValidator struct {
+	prefixes []string
+}
+
+func (v prefixValidator) Validate(number string) error {
+	for _, prefix := range v.prefixes {
+		if strings.HasPrefix(number, prefix) {
+			return nil
+		}
+	}
+	return fmt.Errorf("order number '%s' has no store prefix", number)
+}
+
+// regexValidator checks that the whole number matches pattern.
+type regexValidator struct {
+	pattern *regexp.Regexp
+}
+
+func (v regexValidator) Validate(number string) error {
+	if !v.pattern.MatchString(number) {
+		return fmt.Errorf("order number '%s' does not match pattern '%s'", number, v.pattern)
+	}
+	return nil
+}
+
+// normalizeOrderNumber removes whitespaces and dashes from order number.
+func normalizeOrderNumber(number string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '-' || unicode.IsSpace(r) {
+			return -1
+		}
+		return r
+	}, number)
+}
+
+// checkOrder returns normalized order number or error if validator rejects it.
+func checkOrder(validator OrderValidator, number string) (string, error) {
+	number = normalizeOrderNumber(number)
+	if number == "" {
+		return "", errOrderEmpty
+	}
+	if err := validator.Validate(number); err != nil {
+		return "", err //nolint:wrapcheck // <- validators errors are descriptive
+	}
+	return number, nil
+}
+
+// orderValidator creates validators chain from server options.
+func (cfg *ServerConfig) orderValidator() (OrderValidator, error) {
+	chain := make(orderValidators, 0, len(cfg.OrderValidators))
+	errs := make([]error, 0)
+	for _, name := range cfg.OrderValidators {
+		switch name {
+		case ValidatorLuhn:
+			chain = append(chain, luhnValidator{})
+		case ValidatorLength:
+			if cfg.OrderMinLength < 0 || cfg.OrderMaxLength < 0 ||
+				(cfg.OrderMaxLength > 0 && cfg.OrderMaxLength < cfg.OrderMinLength) {
+				errs = append(errs, fmt.Errorf("order length bounds [%d, %d] incorrect", cfg.OrderMinLength,
+					cfg.OrderMaxLength))
+			}
+			chain = append(chain, lengthValidator{min: cfg.OrderMinLength, max: cfg.OrderMaxLength})
+		case ValidatorPrefix:
+			prefixes := make([]string, 0, len(cfg.OrderPrefixes))
+			for store, prefix := range cfg.OrderPrefixes {
+				if prefix = normalizeOrderNumber(prefix); prefix == "" {
+					errs = append(errs, fmt.Errorf("order prefix of store '%s' is empty", store))
+				}
+				prefixes = append(prefixes, prefix)
+			}
+			if len(prefixes) == 0 {
+				errs = append(errs, errors.New("order prefixes list is empty"))
+			}
+			sort.Strings(prefixes)
+			chain = append(chain, prefixValidator{prefixes: prefixes})
+		case ValidatorRegex:
+			pattern, err := regexp.Compile("^(?:" + cfg.OrderPattern + ")$")
+			if err != nil || cfg.OrderPattern == "" {
+				errs = append(errs, fmt.Errorf("order pattern '%s' incorrect: %v", cfg.OrderPattern, err))
+				continue
+			}
+			chain = append(chain, regexValidator{pattern: pattern})
+		case ValidatorNone:
+			if len(cfg.OrderValidators) > 1 {
+				errs = append(errs, errors.New("order validator 'none' can not be combined with others"))
+			}
+		default:
+			errs = append(errs, fmt.Errorf("unknown order validator: '%s'", name))
+		}
+	}
+	if len(cfg.OrderValidators) == 0 {
+		errs = append(errs, errors.New("order validators list is empty, use 'none' to disable checks"))
+	}
+	return chain, errors.Join(errs...)
+}
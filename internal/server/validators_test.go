@@ -0,0 +1,135 @@
+package server
+
+import (
+	"strings"
+	"testing"
+	"unicode"
+)
+
+// luhnCheckDigit returns control digit of digits payload.
+func luhnCheckDigit(payload string) byte {
+	doubled := [10]int{0, 2, 4, 6, 8, 1, 3, 5, 7, 9}
+	summ := 0
+	for i := 0; i < len(payload); i++ {
+		value := int(payload[len(payload)-1-i] - '0')
+		if i%2 == 0 {
+			value = doubled[value]
+		}
+		summ += value
+	}
+	return byte('0' + (10-summ%10)%10)
+}
+
+func TestOrderValidator(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     ServerConfig
+		number  string
+		want    string
+		wantErr bool
+	}{
+		{name: "Корректный номер", cfg: ServerConfig{OrderValidators: []string{ValidatorLuhn}},
+			number: "12345678903", want: "12345678903"},
+		{name: "Удвоенная девятка", cfg: ServerConfig{OrderValidators: []string{ValidatorLuhn}},
+			number: "91", want: "91"},
+		{name: "Неверная сумма с девяткой", cfg: ServerConfig{OrderValidators: []string{ValidatorLuhn}},
+			number: "90", wantErr: true},
+		{name: "Пробелы и дефисы", cfg: ServerConfig{OrderValidators: []string{ValidatorLuhn}},
+			number: " 4561-2612 1234\t5467\n", want: "4561261212345467"},
+		{name: "Не цифры", cfg: ServerConfig{OrderValidators: []string{ValidatorLuhn}},
+			number: "１２３４５６７８９０３", wantErr: true},
+		{name: "Пустой номер", cfg: ServerConfig{OrderValidators: []string{ValidatorNone}}, number: " - ",
+			wantErr: true},
+		{name: "Без проверки", cfg: ServerConfig{OrderValidators: []string{ValidatorNone}}, number: "abc",
+			want: "abc"},
+		{name: "Длина в границах", cfg: ServerConfig{OrderValidators: []string{ValidatorLength, ValidatorLuhn},
+			OrderMinLength: 10, OrderMaxLength: 11}, number: "2377225624", want: "2377225624"},
+		{name: "Длина вне границ", cfg: ServerConfig{OrderValidators: []string{ValidatorLength, ValidatorLuhn},
+			OrderMinLength: 11, OrderMaxLength: 16}, number: "2377225624", wantErr: true},
+		{name: "Префикс магазина", cfg: ServerConfig{OrderValidators: []string{ValidatorPrefix},
+			OrderPrefixes: map[string]string{"north": "45", "south": "23-7"}}, number: "2377225624",
+			want: "2377225624"},
+		{name: "Чужой префикс", cfg: ServerConfig{OrderValidators: []string{ValidatorPrefix},
+			OrderPrefixes: map[string]string{"north": "45"}}, number: "2377225624", wantErr: true},
+		{name: "Шаблон", cfg: ServerConfig{OrderValidators: []string{ValidatorRegex}, OrderPattern: `[A-Z]{2}\d+`},
+			number: "AB-123", want: "AB123"},
+		{name: "Шаблон для всего номера", cfg: ServerConfig{OrderValidators: []string{ValidatorRegex},
+			OrderPattern: `\d+`}, number: "123AB", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			validator, err := tt.cfg.orderValidator()
+			if err != nil {
+				t.Fatalf("orderValidator() error = %v", err)
+			}
+			got, err := checkOrder(validator, tt.number)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("checkOrder() = '%s', %v, want '%s', wantErr %v", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestOrderValidatorConfig(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  ServerConfig
+	}{
+		{name: "Пустой список", cfg: ServerConfig{}},
+		{name: "Неизвестная проверка", cfg: ServerConfig{OrderValidators: []string{"crc"}}},
+		{name: "Без проверки вместе с другими", cfg: ServerConfig{OrderValidators: []string{ValidatorNone,
+			ValidatorLuhn}}},
+		{name: "Неверные границы длины", cfg: ServerConfig{OrderValidators: []string{ValidatorLength},
+			OrderMinLength: 10, OrderMaxLength: 5}},
+		{name: "Нет префиксов", cfg: ServerConfig{OrderValidators: []string{ValidatorPrefix}}},
+		{name: "Пустой префикс", cfg: ServerConfig{OrderValidators: []string{ValidatorPrefix},
+			OrderPrefixes: map[string]string{"north": " "}}},
+		{name: "Неверный шаблон", cfg: ServerConfig{OrderValidators: []string{ValidatorRegex}, OrderPattern: "("}},
+		{name: "Пустой шаблон", cfg: ServerConfig{OrderValidators: []string{ValidatorRegex}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.cfg.orderValidator(); err == nil {
+				t.Error("orderValidator() error is nil")
+			}
+		})
+	}
+}
+
+func FuzzNormalizeOrderNumber(f *testing.F) {
+	for _, seed := range []string{"12345678903", " 4561-2612 1234\t5467\n", "— １２", ""} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, number string) {
+		got := normalizeOrderNumber(number)
+		if strings.IndexFunc(got, func(r rune) bool { return r == '-' || unicode.IsSpace(r) }) >= 0 {
+			t.Errorf("normalizeOrderNumber(%q) = %q contains separators", number, got)
+		}
+		if again := normalizeOrderNumber(got); again != got {
+			t.Errorf("normalizeOrderNumber(%q) = %q is not stable", got, again)
+		}
+	})
+}
+
+func FuzzLuhnValidator(f *testing.F) {
+	for _, seed := range []string{"1234567890", "9", "0", "4561261212345467", "12a", "٣٤"} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, payload string) {
+		err := luhnValidator{}.Validate(payload)
+		if payload == "" || strings.IndexFunc(payload, func(r rune) bool { return r < '0' || r > '9' }) >= 0 {
+			if err == nil {
+				t.Errorf("Validate(%q) accepts non digits number", payload)
+			}
+			return
+		}
+		check := luhnCheckDigit(payload)
+		if err = (luhnValidator{}).Validate(payload + string(check)); err != nil {
+			t.Errorf("Validate(%q) error = %v", payload+string(check), err)
+		}
+		wrong := '0' + (check-'0'+1)%10
+		if err = (luhnValidator{}).Validate(payload + string(wrong)); err == nil {
+			t.Errorf("Validate(%q) accepts wrong control digit", payload+string(wrong))
+		}
+	})
+}
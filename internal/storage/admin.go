@@ -0,0 +1,164 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var ErrNotFound = errors.New("record not found")
+
+type exportUser struct {
+	CreatedAt time.Time `json:"created_at"`
+	Login     string    `json:"login"`
+	IP        string    `json:"ip"`
+	Tier      string    `json:"tier"`
+	Balance   float32   `json:"balance"`
+	Withdrawn float32   `json:"withdrawn"`
+	ID        uint      `json:"id"`
+	Blocked   bool      `json:"blocked"`
+}
+
+type exportOrder struct {
+	CreatedAt time.Time `json:"created_at"`
+	Number    string    `json:"number"`
+	Status    string    `json:"status"`
+	Accrual   float32   `json:"accrual"`
+	TierBonus float32   `json:"tier_bonus"`
+	UID       int       `json:"uid"`
+}
+
+type exportWithdraw struct {
+	CreatedAt time.Time `json:"created_at"`
+	Number    string    `json:"number"`
+	Status    string    `json:"status"`
+	Sum       float32   `json:"sum"`
+	UID       int       `json:"uid"`
+}
+
+type exportData struct {
+	Users     []exportUser     `json:"users"`
+	Orders    []exportOrder    `json:"orders"`
+	Withdraws []exportWithdraw `json:"withdraws"`
+}
+
+// CreateUser adds user from administrative cli.
+func (s *psqlStorage) CreateUser(ctx context.Context, login, pwd string) (int, error) {
+	return s.Registration(ctx, login, pwd, "", "", "")
+}
+
+func (s *psqlStorage) updateUser(ctx context.Context, login string, values map[string]any) error {
+	result := s.con.WithContext(ctx).Model(&Users{}).Where("login = ?", login).Updates(values)
+	if result.Error != nil {
+		return fmt.Errorf("update user error: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("user '%s': %w", login, ErrNotFound)
+	}
+	return nil
+}
+
+// BlockUser blocks or unblocks user. Blocking revokes issued user tokens.
+func (s *psqlStorage) BlockUser(ctx context.Context, login string, blocked bool) error {
+	values := map[string]any{"blocked": blocked}
+	if blocked {
+		values["auth_reset_at"] = time.Now()
+	}
+	return s.updateUser(ctx, login, values)
+}
+
+func (s *psqlStorage) ResetPassword(ctx context.Context, login, pwd string) error {
+	passwd, err := hashPassword([]byte(pwd))
+	if err != nil {
+		return err
+	}
+	return s.updateUser(ctx, login, map[string]any{"pwd": string(passwd), "auth_reset_at": time.Now()})
+}
+
+// UserAllowed checks that user exists, is not blocked and token is issued after the last tokens revocation.
+// Token time has seconds precision, so revocation time is truncated too.
+func (s *psqlStorage) UserAllowed(ctx context.Context, uid int, issuedAt time.Time) (bool, error) {
+	var user Users
+	result := s.con.WithContext(ctx).Select("blocked", "auth_reset_at").Where("id = ?", uid).Limit(1).Find(&user)
+	if result.Error != nil {
+		return false, fmt.Errorf("get user access error: %w", result.Error)
+	}
+	if result.RowsAffected == 0 || user.Blocked || (user.AuthResetAt != nil && issuedAt.Before(user.AuthResetAt.Truncate(time.Second))) {
+		return false, nil
+	}
+	return true, nil
+}
+
+// RecomputeBalances rebuilds users balances from orders accruals and tier bonuses, campaign credits, referral
+// rewards, transfers, withdrawals and expired points.
+// Cancelled and refunded withdrawals are not counted, held sum is rebuilt from active holds.
+func (s *psqlStorage) RecomputeBalances(ctx context.Context) (int64, error) {
+	result := s.con.WithContext(ctx).Exec(`
+		WITH totals AS (
+			SELECT id,
+				coalesce((SELECT sum(accrual + tier_bonus) FROM orders WHERE uid = users.id), 0) +
+				coalesce((SELECT sum(sum) FROM campaign_credits WHERE uid = users.id), 0) +
+				coalesce((SELECT sum(reward) FROM referrals
+					WHERE referrer_uid = users.id AND status = 'REWARDED'), 0) AS accrued,
+				coalesce((SELECT sum(sum) FROM withdraws
+					WHERE uid = users.id AND status IN ('PENDING', 'COMPLETED')), 0) AS withdrawn,
+				coalesce((SELECT sum(sum) FROM holds WHERE uid = users.id AND status = 'ACTIVE'), 0) AS held,
+				coalesce((SELECT sum(sum) FROM transfers
+					WHERE recipient_uid = users.id AND status = 'COMPLETED'), 0) -
+				coalesce((SELECT sum(sum) FROM transfers
+					WHERE sender_uid = users.id AND status = 'COMPLETED'), 0) AS transferred,
+				coalesce((SELECT sum(sum) FROM point_expirations WHERE uid = users.id), 0) AS expired
+			FROM users
+		)
+		UPDATE users SET balance = totals.accrued + totals.transferred - totals.withdrawn - totals.expired,
+			withdrawn = totals.withdrawn,
+			held = totals.held
+		FROM totals WHERE users.id = totals.id`)
+	if result.Error != nil {
+		return 0, fmt.Errorf("recompute balances error: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+// RecheckOrder returns order to the accrual poller queue.
+func (s *psqlStorage) RecheckOrder(ctx context.Context, number string) error {
+	result := s.con.WithContext(ctx).Model(&Orders{}).Where("number = ?", number).Update("status", "NEW")
+	if result.Error != nil {
+		return fmt.Errorf("recheck order error: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("order '%s': %w", number, ErrNotFound)
+	}
+	return nil
+}
+
+// Export writes users, orders and withdrawals in json format.
+func (s *psqlStorage) Export(ctx context.Context, w io.Writer) error {
+	var data exportData
+	err := s.con.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&Users{}).Order("id").Find(&data.Users).Error; err != nil {
+			return fmt.Errorf("export users error: %w", err)
+		}
+		if err := tx.Model(&Orders{}).Order("id").Find(&data.Orders).Error; err != nil {
+			return fmt.Errorf("export orders error: %w", err)
+		}
+		if err := tx.Model(&Withdraws{}).Order("id").Find(&data.Withdraws).Error; err != nil {
+			return fmt.Errorf("export withdraws error: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("export transaction error: %w", err)
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err = encoder.Encode(data); err != nil {
+		return fmt.Errorf("export write error: %w", err)
+	}
+	return nil
+}
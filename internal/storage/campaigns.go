@@ -0,0 +1,312 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Campaign events. Register campaign credits bonus on user registration, order campaign credits bonus
+// or multiplies accrual of processed order, promo campaign credits bonus on promo code redemption.
+const (
+	CampaignRegister = "register"
+	CampaignOrder    = "order"
+	CampaignPromo    = "promo"
+)
+
+var (
+	errPromoNotFound    = errors.New("promo code not found")
+	errPromoUsed        = errors.New("promo code is used up")
+	errPromoRedeemed    = errors.New("promo code is already redeemed by user")
+	errCampaignInactive = errors.New("campaign is not active or its budget is spent")
+	errCampaignTarget   = errors.New("user is not campaign target")
+)
+
+// Campaigns is marketing campaign active from StartsAt till EndsAt. Campaign credits fixed Bonus and
+// accrual multiplied by Multiplier minus one while Spent sum does not reach Budget (zero Budget means no cap).
+// Campaign applies to all users, unless target Tier or Targets logins are set.
+type Campaigns struct {
+	CreatedAt  time.Time    `json:"created_at"`
+	UpdatedAt  time.Time    `json:"-"`
+	StartsAt   time.Time    `json:"starts_at"`
+	EndsAt     time.Time    `json:"ends_at"`
+	Name       string       `gorm:"unique" json:"name"`
+	Event      string       `gorm:"type:varchar(10)" json:"event"`
+	Tier       string       `gorm:"type:varchar(10)" json:"tier,omitempty"`
+	Targets    []string     `gorm:"-" json:"targets,omitempty"`
+	Codes      []PromoCodes `gorm:"-" json:"codes,omitempty"`
+	Bonus      float32      `gorm:"type:numeric" json:"bonus,omitempty"`
+	Multiplier float32      `gorm:"type:numeric" json:"multiplier,omitempty"`
+	Budget     float32      `gorm:"type:numeric" json:"budget,omitempty"`
+	Spent      float32      `gorm:"type:numeric" json:"spent"`
+	ID         uint         `gorm:"primarykey" json:"id"`
+}
+
+// PromoCodes is code of promo campaign. Code can be redeemed MaxUses times (zero means no limit),
+// but only once by each user.
+type PromoCodes struct {
+	Code       string `gorm:"unique" json:"code"`
+	MaxUses    int    `json:"max_uses"`
+	Uses       int    `json:"uses"`
+	ID         uint   `gorm:"primarykey" json:"-"`
+	CampaignID uint   `json:"-"`
+}
+
+type CampaignTargets struct {
+	CampaignID uint `gorm:"primaryKey"`
+	UID        int  `gorm:"primaryKey;type:bigint"`
+}
+
+// CampaignCredits is points credited to user by campaign.
+type CampaignCredits struct {
+	CreatedAt  time.Time
+	Number     *string
+	CodeID     *uint
+	Sum        float32 `gorm:"type:numeric"`
+	ID         uint    `gorm:"primarykey"`
+	CampaignID uint
+	UID        int `gorm:"type:bigint"`
+}
+
+// PromoResult is response on promo code redemption.
+type PromoResult struct {
+	Campaign string  `json:"campaign"`
+	Code     string  `json:"code"`
+	Sum      float32 `json:"sum"`
+}
+
+// Validate checks campaign definition.
+func (c *Campaigns) Validate() error {
+	errs := make([]error, 0)
+	if c.Name == "" {
+		errs = append(errs, errors.New("campaign name is empty"))
+	}
+	if c.Event != CampaignRegister && c.Event != CampaignOrder && c.Event != CampaignPromo {
+		errs = append(errs, fmt.Errorf("campaign event must be '%s', '%s' or '%s', got '%s'",
+			CampaignRegister, CampaignOrder, CampaignPromo, c.Event))
+	}
+	if !c.EndsAt.After(c.StartsAt) {
+		errs = append(errs, errors.New("campaign must end after start"))
+	}
+	if c.Bonus < 0 || c.Budget < 0 {
+		errs = append(errs, errors.New("campaign bonus and budget must not be negative"))
+	}
+	if c.Multiplier != 0 && (c.Multiplier < 1 || c.Event != CampaignOrder) {
+		errs = append(errs, errors.New("campaign multiplier must be at least 1 and is used by order campaigns only"))
+	}
+	if c.Bonus == 0 && c.Multiplier <= 1 {
+		errs = append(errs, errors.New("campaign must have bonus or multiplier"))
+	}
+	if c.Tier != "" && c.Tier != TierBronze && c.Tier != TierSilver && c.Tier != TierGold {
+		errs = append(errs, fmt.Errorf("unknown campaign tier '%s'", c.Tier))
+	}
+	if (c.Event == CampaignPromo) != (len(c.Codes) > 0) {
+		errs = append(errs, errors.New("promo campaign must have codes, other campaigns must not"))
+	}
+	for _, code := range c.Codes {
+		if code.Code == "" || code.MaxUses < 0 {
+			errs = append(errs, fmt.Errorf("incorrect promo code '%s' with max uses %d", code.Code, code.MaxUses))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// CreateCampaign saves campaign with its promo codes and target users.
+func (s *psqlStorage) CreateCampaign(ctx context.Context, campaign *Campaigns) error {
+	if err := campaign.Validate(); err != nil {
+		return err
+	}
+	err := s.con.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(campaign).Error; err != nil {
+			return fmt.Errorf("create campaign error: %w", err)
+		}
+		for i := range campaign.Codes {
+			campaign.Codes[i].CampaignID = campaign.ID
+			if err := tx.Create(&campaign.Codes[i]).Error; err != nil {
+				return fmt.Errorf("create promo code '%s' error: %w", campaign.Codes[i].Code, err)
+			}
+		}
+		for _, login := range campaign.Targets {
+			var user Users
+			result := tx.Where("login = ?", login).Limit(1).Find(&user)
+			if result.Error != nil {
+				return fmt.Errorf("select target user error: %w", result.Error)
+			}
+			if result.RowsAffected == 0 {
+				return fmt.Errorf("target user '%s': %w", login, ErrNotFound)
+			}
+			if err := tx.Create(&CampaignTargets{CampaignID: campaign.ID, UID: int(user.ID)}).Error; err != nil {
+				return fmt.Errorf("create campaign target error: %w", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("campaign transaction error: %w", err)
+	}
+	return nil
+}
+
+// GetCampaigns returns campaigns with promo codes ordered by start time.
+func (s *psqlStorage) GetCampaigns(ctx context.Context) ([]Campaigns, error) {
+	var campaigns []Campaigns
+	if err := s.con.WithContext(ctx).Order("starts_at, id").Find(&campaigns).Error; err != nil {
+		return nil, fmt.Errorf("select campaigns error: %w", err)
+	}
+	for i := range campaigns {
+		err := s.con.WithContext(ctx).Where("campaign_id = ?", campaigns[i].ID).Order("id").
+			Find(&campaigns[i].Codes).Error
+		if err != nil {
+			return nil, fmt.Errorf("select promo codes error: %w", err)
+		}
+	}
+	return campaigns, nil
+}
+
+// campaignActive selects campaigns running now with not spent budget.
+func campaignActive(db *gorm.DB) *gorm.DB {
+	now := time.Now()
+	return db.Where("starts_at <= ? AND ends_at > ? AND (budget = 0 OR spent < budget)", now, now)
+}
+
+// campaignTargeted selects campaigns targeting the user.
+func campaignTargeted(user *Users) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("(tier = '' OR tier = ?) AND (NOT EXISTS "+
+			"(SELECT 1 FROM campaign_targets t WHERE t.campaign_id = campaigns.id) OR EXISTS "+
+			"(SELECT 1 FROM campaign_targets t WHERE t.campaign_id = campaigns.id AND t.uid = ?))",
+			user.Tier, user.ID)
+	}
+}
+
+// creditCampaign credits sum to user by locked campaign. Sum is reduced to the rest of campaign budget.
+// Returns credited sum. User balance is not changed.
+func (s *psqlStorage) creditCampaign(tx *gorm.DB, campaign *Campaigns, uid int, sum float32,
+	number *string, codeID *uint) (float32, error) {
+	if campaign.Budget > 0 && campaign.Spent+sum > campaign.Budget {
+		sum = campaign.Budget - campaign.Spent
+	}
+	if sum <= 0 {
+		return 0, nil
+	}
+	campaign.Spent += sum
+	if err := tx.Model(campaign).Update("spent", campaign.Spent).Error; err != nil {
+		return 0, fmt.Errorf("update campaign spent error: %w", err)
+	}
+	credit := CampaignCredits{CampaignID: campaign.ID, UID: uid, Sum: sum, Number: number, CodeID: codeID}
+	if err := tx.Create(&credit).Error; err != nil {
+		return 0, fmt.Errorf("create campaign credit error: %w", err)
+	}
+	if err := creditLot(tx, uid, sum, LotCampaign, number, s.expiresAt()); err != nil {
+		return 0, err
+	}
+	return sum, nil
+}
+
+// applyCampaigns credits bonuses of active campaigns of event targeting locked user.
+// Accrual is multiplied for order campaigns. Returns credited sum, user balance is not changed.
+func (s *psqlStorage) applyCampaigns(tx *gorm.DB, user *Users, event string, accrual float32,
+	number *string) (float32, error) {
+	var campaigns []Campaigns
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Scopes(campaignActive, campaignTargeted(user)).
+		Where("event = ?", event).Order("id").Find(&campaigns).Error
+	if err != nil {
+		return 0, fmt.Errorf("select active campaigns error: %w", err)
+	}
+	total := float32(0)
+	for i := range campaigns {
+		sum := campaigns[i].Bonus
+		if campaigns[i].Multiplier > 1 {
+			sum += accrual * (campaigns[i].Multiplier - 1)
+		}
+		credited, err := s.creditCampaign(tx, &campaigns[i], int(user.ID), sum, number, nil)
+		if err != nil {
+			return 0, err
+		}
+		total += credited
+	}
+	return total, nil
+}
+
+// RedeemPromo credits bonus of promo code campaign to user.
+func (s *psqlStorage) RedeemPromo(ctx context.Context, uid int, code string) ([]byte, int, error) {
+	result := PromoResult{Code: code}
+	err := s.con.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var user Users
+		if err := lockUser(tx, uid, &user); err != nil {
+			return err
+		}
+		var promo PromoCodes
+		found := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("code = ?", code).Limit(1).Find(&promo)
+		if found.Error != nil {
+			return fmt.Errorf("select promo code error: %w", found.Error)
+		}
+		if found.RowsAffected == 0 {
+			return errPromoNotFound
+		}
+		if promo.MaxUses > 0 && promo.Uses >= promo.MaxUses {
+			return errPromoUsed
+		}
+		var count int64
+		err := tx.Model(&CampaignCredits{}).Where("code_id = ? AND uid = ?", promo.ID, uid).Count(&count).Error
+		if err != nil {
+			return fmt.Errorf("select promo redemptions error: %w", err)
+		}
+		if count > 0 {
+			return errPromoRedeemed
+		}
+		var campaign Campaigns
+		found = tx.Clauses(clause.Locking{Strength: "UPDATE"}).Scopes(campaignActive).
+			Where("id = ? AND event = ?", promo.CampaignID, CampaignPromo).Limit(1).Find(&campaign)
+		if found.Error != nil {
+			return fmt.Errorf("select promo campaign error: %w", found.Error)
+		}
+		if found.RowsAffected == 0 {
+			return errCampaignInactive
+		}
+		found = tx.Model(&Campaigns{}).Scopes(campaignTargeted(&user)).Where("id = ?", campaign.ID).Limit(1).
+			Find(&Campaigns{})
+		if found.Error != nil {
+			return fmt.Errorf("select campaign target error: %w", found.Error)
+		}
+		if found.RowsAffected == 0 {
+			return errCampaignTarget
+		}
+		result.Campaign = campaign.Name
+		result.Sum, err = s.creditCampaign(tx, &campaign, uid, campaign.Bonus, nil, &promo.ID)
+		if err != nil {
+			return err
+		}
+		if err = tx.Model(&promo).Update("uses", gorm.Expr("uses + 1")).Error; err != nil {
+			return fmt.Errorf("update promo code uses error: %w", err)
+		}
+		if err = tx.Model(&user).Update("balance", gorm.Expr("balance + ?", result.Sum)).Error; err != nil {
+			return fmt.Errorf("update user balance error: %w", err)
+		}
+		return nil
+	})
+	switch {
+	case err == nil:
+	case errors.Is(err, errPromoNotFound):
+		return nil, http.StatusNotFound, fmt.Errorf("code '%s': %w", code, err)
+	case errors.Is(err, errPromoRedeemed):
+		return nil, http.StatusConflict, fmt.Errorf("code '%s': %w", code, err)
+	case errors.Is(err, errPromoUsed), errors.Is(err, errCampaignInactive):
+		return nil, http.StatusGone, fmt.Errorf("code '%s': %w", code, err)
+	case errors.Is(err, errCampaignTarget):
+		return nil, http.StatusForbidden, fmt.Errorf("code '%s': %w", code, err)
+	default:
+		return nil, http.StatusInternalServerError, fmt.Errorf("redeem promo transaction error: %w", err)
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		return nil, http.StatusInternalServerError, fmt.Errorf("convert promo result to json error: %w", err)
+	}
+	return data, http.StatusOK, nil
+}
@@ -0,0 +1,44 @@
+package storage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCampaignsValidate(t *testing.T) {
+	start := time.Date(2024, time.June, 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 0, 2)
+	tests := []struct {
+		name     string
+		campaign Campaigns
+		wantErr  bool
+	}{
+		{name: "Двойные баллы по заказам", campaign: Campaigns{Name: "weekend", Event: CampaignOrder,
+			StartsAt: start, EndsAt: end, Multiplier: 2, Budget: 10000}},
+		{name: "Бонус при регистрации", campaign: Campaigns{Name: "welcome", Event: CampaignRegister,
+			StartsAt: start, EndsAt: end, Bonus: 500}},
+		{name: "Промокоды", campaign: Campaigns{Name: "promo", Event: CampaignPromo, StartsAt: start, EndsAt: end,
+			Bonus: 100, Codes: []PromoCodes{{Code: "ONCE", MaxUses: 1}, {Code: "MANY"}}}},
+		{name: "Промокампания без кодов", campaign: Campaigns{Name: "promo", Event: CampaignPromo,
+			StartsAt: start, EndsAt: end, Bonus: 100}, wantErr: true},
+		{name: "Коды у кампании заказов", campaign: Campaigns{Name: "order", Event: CampaignOrder,
+			StartsAt: start, EndsAt: end, Bonus: 100, Codes: []PromoCodes{{Code: "A"}}}, wantErr: true},
+		{name: "Множитель при регистрации", campaign: Campaigns{Name: "welcome", Event: CampaignRegister,
+			StartsAt: start, EndsAt: end, Multiplier: 2}, wantErr: true},
+		{name: "Без бонуса и множителя", campaign: Campaigns{Name: "empty", Event: CampaignOrder,
+			StartsAt: start, EndsAt: end}, wantErr: true},
+		{name: "Окончание раньше начала", campaign: Campaigns{Name: "late", Event: CampaignOrder,
+			StartsAt: end, EndsAt: start, Bonus: 1}, wantErr: true},
+		{name: "Неизвестный уровень", campaign: Campaigns{Name: "tier", Event: CampaignOrder,
+			StartsAt: start, EndsAt: end, Bonus: 1, Tier: "platinum"}, wantErr: true},
+		{name: "Неизвестное событие", campaign: Campaigns{Name: "login", Event: "login",
+			StartsAt: start, EndsAt: end, Bonus: 1}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.campaign.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
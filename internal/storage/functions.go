@@ -1,19 +1,34 @@
 package storage
 
 import (
+	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 	"time"
-
-	"gorm.io/gorm"
 )
 
 const (
 	defaultMaxConnectionPull = 100
+	ordersBatchSize          = 100
 )
 
 type StorageConfig struct {
-	DBConnect        string
-	DBConnectionPull int
+	DBConnect        string `json:"database_uri"`
+	DBConnectionPull int    `json:"pool_size"`
+	AutoMigrate      bool   `json:"auto_migrate"`
+}
+
+// Validate checks storage options values.
+func (cfg *StorageConfig) Validate() error {
+	errs := make([]error, 0)
+	if cfg.DBConnect == "" {
+		errs = append(errs, errors.New("database connection string is empty"))
+	}
+	if cfg.DBConnectionPull <= 0 {
+		errs = append(errs, fmt.Errorf("database pool size must be positive, got %d", cfg.DBConnectionPull))
+	}
+	return errors.Join(errs...)
 }
 
 func NewStorageConfig() *StorageConfig {
@@ -24,39 +39,118 @@ func NewStorageConfig() *StorageConfig {
 }
 
 type Users struct {
-	CreatedAt time.Time `json:"-"`
-	UpdatedAt time.Time `json:"-"`
-	Login     string    `gorm:"unique" json:"-"`
-	Pwd       string    `gorm:"type:varchar(255)" json:"-"`
-	UserAgent string    `gorm:"type:varchar(255)" json:"-"`
-	IP        string    `gorm:"type:varchar(15)" json:"-"`
-	Balance   float32   `gorm:"type:numeric" json:"curent"`
-	Withdrawn float32   `gorm:"type:numeric" json:"withdrawn"`
-	ID        uint      `gorm:"primarykey" json:"-"`
+	CreatedAt    time.Time  `json:"-"`
+	UpdatedAt    time.Time  `json:"-"`
+	Login        string     `gorm:"unique" json:"-"`
+	Pwd          string     `gorm:"type:varchar(255)" json:"-"`
+	UserAgent    string     `gorm:"type:varchar(255)" json:"-"`
+	IP           string     `gorm:"type:varchar(45)" json:"-"`
+	Tier         string     `gorm:"type:varchar(10);not null;default:bronze" json:"-"`
+	ReferralCode string     `gorm:"type:varchar(16);unique" json:"-"`
+	Balance      float32    `gorm:"type:numeric" json:"curent"`
+	Withdrawn    float32    `gorm:"type:numeric" json:"withdrawn"`
+	Held         float32    `gorm:"type:numeric;not null;default:0" json:"-"`
+	ID           uint       `gorm:"primarykey" json:"-"`
+	AuthResetAt  *time.Time `json:"-"`
+	Blocked      bool       `gorm:"not null;default:false" json:"-"`
 }
 
+// orderProcessed is final status of order with calculated accrual.
+const orderProcessed = "PROCESSED"
+
 type Orders struct {
 	CreatedAt time.Time `json:"uploaded_at"`
 	UpdatedAt time.Time `json:"-"`
 	Number    string    `gorm:"unique" json:"number"`
 	Status    string    `gorm:"type:varchar(10)" json:"status"`
 	Accrual   float32   `gorm:"type:numeric" json:"accrual,omitempty"`
+	TierBonus float32   `gorm:"type:numeric;not null;default:0" json:"tier_bonus,omitempty"`
 	ID        uint      `gorm:"primarykey" json:"-"`
-	UID       int       `gorm:"type:int" json:"-"`
+	UID       int       `gorm:"type:bigint;index:orders_uid_id_idx" json:"-"`
 }
 
-type Withdraws struct {
-	CreatedAt time.Time `json:"processed_at"`
-	Number    string    `gorm:"unique" json:"order"`
+// Withdrawal statuses. Pending withdrawal can be cancelled by user until CancelUntil time.
+const (
+	WithdrawPending   = "PENDING"
+	WithdrawCompleted = "COMPLETED"
+	WithdrawCancelled = "CANCELLED"
+	WithdrawRefunded  = "REFUNDED"
+)
+
+// Hold statuses. Active hold reduces available balance until it is captured, released or expired.
+const (
+	HoldActive   = "ACTIVE"
+	HoldCaptured = "CAPTURED"
+	HoldReleased = "RELEASED"
+	HoldExpired  = "EXPIRED"
+)
+
+type Holds struct {
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"-"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Number    string    `json:"order"`
+	Status    string    `gorm:"type:varchar(10)" json:"status"`
 	Sum       float32   `gorm:"type:numeric" json:"sum"`
 	ID        uint      `gorm:"primarykey" json:"-"`
-	UID       int       `gorm:"type:int" json:"-"`
+	UID       int       `gorm:"type:bigint" json:"-"`
+}
+
+// Transfer statuses. Pending transfer is executed after confirmation by sender.
+const (
+	TransferPending   = "PENDING"
+	TransferCompleted = "COMPLETED"
+	TransferExpired   = "EXPIRED"
+)
+
+type Transfers struct {
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"-"`
+	ExpiresAt    time.Time  `json:"expires_at"`
+	ConfirmedAt  *time.Time `json:"confirmed_at,omitempty"`
+	Login        string     `gorm:"-" json:"login"`
+	Status       string     `gorm:"type:varchar(10)" json:"status"`
+	Sum          float32    `gorm:"type:numeric" json:"sum"`
+	ID           uint       `gorm:"primarykey" json:"id"`
+	SenderUID    int        `gorm:"type:bigint" json:"-"`
+	RecipientUID int        `gorm:"type:bigint" json:"-"`
 }
 
-func structCheck(con *gorm.DB) error {
-	err := con.AutoMigrate(&Users{}, &Orders{}, &Withdraws{})
+// HistoryItem is balance change event of user. Sum is positive for credits and negative for debits.
+type HistoryItem struct {
+	CreatedAt time.Time `json:"created_at"`
+	Type      string    `json:"type"`
+	Order     string    `json:"order,omitempty"`
+	Login     string    `json:"login,omitempty"`
+	Campaign  string    `json:"campaign,omitempty"`
+	Status    string    `json:"status,omitempty"`
+	Sum       float32   `json:"sum"`
+}
+
+type Withdraws struct {
+	CreatedAt   time.Time  `json:"processed_at"`
+	UpdatedAt   time.Time  `json:"-"`
+	CancelUntil *time.Time `json:"cancel_until,omitempty"`
+	Number      string     `gorm:"unique" json:"order"`
+	Status      string     `gorm:"type:varchar(10)" json:"status"`
+	Sum         float32    `gorm:"type:numeric" json:"sum"`
+	ID          uint       `gorm:"primarykey" json:"-"`
+	UID         int        `gorm:"type:bigint;index:withdraws_uid_id_idx" json:"-"`
+}
+
+func structCheck(ctx context.Context, db *sql.DB, autoMigrate bool) error {
+	if autoMigrate {
+		if _, err := MigrateUp(ctx, db); err != nil {
+			return fmt.Errorf("database structure error: %w", err)
+		}
+		return nil
+	}
+	actual, err := IsSchemaActual(ctx, db)
 	if err != nil {
 		return fmt.Errorf("database structure error: %w", err)
 	}
+	if !actual {
+		return ErrSchemaOutdated
+	}
 	return nil
 }
@@ -0,0 +1,64 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// History event types.
+const (
+	HistoryAccrual     = "accrual"
+	HistoryWithdrawal  = "withdrawal"
+	HistoryTransferIn  = "transfer_in"
+	HistoryTransferOut = "transfer_out"
+	HistoryExpiry      = "expiry"
+	HistoryTierBonus   = "tier_bonus"
+	HistoryCampaign    = "campaign"
+	HistoryReferral    = "referral"
+)
+
+// historyQueries select balance events of user @uid as created_at, type, "order", login, campaign, status and sum.
+var historyQueries = []string{
+	`SELECT updated_at AS created_at, '` + HistoryAccrual + `' AS type, number AS "order", '' AS login,
+		'' AS campaign, status, accrual AS sum
+	FROM orders WHERE uid = @uid AND accrual > 0`,
+	`SELECT updated_at, '` + HistoryTierBonus + `', number, '', '', status, tier_bonus
+	FROM orders WHERE uid = @uid AND tier_bonus > 0`,
+	`SELECT created_at, '` + HistoryWithdrawal + `', number, '', '', status, -sum
+	FROM withdraws WHERE uid = @uid`,
+	`SELECT t.confirmed_at, '` + HistoryTransferOut + `', '', u.login, '', t.status, -t.sum
+	FROM transfers t JOIN users u ON u.id = t.recipient_uid
+	WHERE t.sender_uid = @uid AND t.status = 'COMPLETED'`,
+	`SELECT t.confirmed_at, '` + HistoryTransferIn + `', '', u.login, '', t.status, t.sum
+	FROM transfers t JOIN users u ON u.id = t.sender_uid
+	WHERE t.recipient_uid = @uid AND t.status = 'COMPLETED'`,
+	`SELECT e.created_at, '` + HistoryExpiry + `', coalesce(l.number, ''), '', '', '', -e.sum
+	FROM point_expirations e JOIN point_lots l ON l.id = e.lot_id
+	WHERE e.uid = @uid`,
+	`SELECT cc.created_at, '` + HistoryCampaign + `', coalesce(cc.number, ''), '', c.name, '', cc.sum
+	FROM campaign_credits cc JOIN campaigns c ON c.id = cc.campaign_id
+	WHERE cc.uid = @uid`,
+	`SELECT r.updated_at, '` + HistoryReferral + `', coalesce(r.number, ''), u.login, '', r.status, r.reward
+	FROM referrals r JOIN users u ON u.id = r.referee_uid
+	WHERE r.referrer_uid = @uid AND r.status = '` + ReferralRewarded + `'`,
+}
+
+// GetHistory returns orders accruals and tier bonuses, campaign credits, referral rewards, withdrawals, transfers
+// and points expirations of user ordered by time.
+func (s *psqlStorage) GetHistory(ctx context.Context, uid int) ([]byte, error) {
+	var items []HistoryItem
+	query := "SELECT * FROM (" + strings.Join(historyQueries, "\nUNION ALL\n") + ") history ORDER BY created_at DESC"
+	if err := s.con.WithContext(ctx).Raw(query, map[string]any{"uid": uid}).Scan(&items).Error; err != nil {
+		return nil, fmt.Errorf("get history error: %w", err)
+	}
+	if len(items) == 0 {
+		return nil, nil
+	}
+	data, err := json.Marshal(items)
+	if err != nil {
+		return nil, fmt.Errorf("json convert error: %w", err)
+	}
+	return data, nil
+}
@@ -0,0 +1,199 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gostuding/goMarket/internal/problem"
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	errHoldNotFound  = errors.New("active hold not found")
+	errHoldExpired   = errors.New("hold expired")
+	errLowAvailable  = errors.New("low available balance level")
+	errWithdrawExist = errors.New("withdraw for order exists")
+	errHoldExist     = errors.New("active hold for order exists")
+)
+
+// AddHold reserves sum of user available balance for order until ttl expires.
+// Withdrawal rules are checked on hold, so captured hold is not checked again.
+func (s *psqlStorage) AddHold(ctx context.Context, uid int, order string, sum, orderTotal float32,
+	ttl time.Duration) ([]byte, int, error) {
+	if err := s.loyalty.checkWithdrawSum(sum, orderTotal); err != nil {
+		status, err := withdrawStatus(err)
+		return nil, status, err
+	}
+	hold := Holds{UID: uid, Number: order, Sum: sum, Status: HoldActive, ExpiresAt: time.Now().Add(ttl)}
+	err := s.con.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var user Users
+		if err := lockUser(tx, uid, &user); err != nil {
+			return err
+		}
+		if user.Balance-user.Held < sum {
+			return errLowAvailable
+		}
+		if err := s.checkWithdrawRules(tx, &user, sum); err != nil {
+			return err
+		}
+		var count int64
+		if err := tx.Model(&Withdraws{}).Where("number = ?", order).Count(&count).Error; err != nil {
+			return fmt.Errorf("select withdraw error: %w", err)
+		}
+		if count > 0 {
+			return errWithdrawExist
+		}
+		if err := tx.Create(&hold).Error; err != nil {
+			return fmt.Errorf("create hold error: %w", err)
+		}
+		if err := tx.Model(&user).Update("held", gorm.Expr("held + ?", sum)).Error; err != nil {
+			return fmt.Errorf("update user held error: %w", err)
+		}
+		return nil
+	})
+	var pgErr *pgconn.PgError
+	var rule *problem.Problem
+	switch {
+	case err == nil:
+	case errors.Is(err, errLowAvailable):
+		return nil, http.StatusPaymentRequired, nil
+	case errors.As(err, &rule):
+		return nil, rule.Status, rule
+	case errors.Is(err, errWithdrawExist), errors.As(err, &pgErr) && pgErr.Code == pgerrcode.UniqueViolation:
+		return nil, http.StatusConflict, fmt.Errorf("order '%s' hold error: %w", order, err)
+	default:
+		return nil, http.StatusInternalServerError, fmt.Errorf("hold transaction error: %w", err)
+	}
+	data, err := json.Marshal(hold)
+	if err != nil {
+		return nil, http.StatusInternalServerError, fmt.Errorf("convert hold to json error: %w", err)
+	}
+	return data, http.StatusCreated, nil
+}
+
+// finishHold locks user and the user's active hold of order, then changes hold status.
+// Expired hold can only be released.
+func finishHold(tx *gorm.DB, uid int, order, status string, user *Users) (*Holds, error) {
+	var hold Holds
+	if err := lockUser(tx, uid, user); err != nil {
+		return nil, err
+	}
+	result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("number = ? AND uid = ? AND status = ?", order, uid, HoldActive).Limit(1).Find(&hold)
+	if result.Error != nil {
+		return nil, fmt.Errorf("select hold error: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, errHoldNotFound
+	}
+	if status == HoldCaptured && hold.ExpiresAt.Before(time.Now()) {
+		return nil, errHoldExpired
+	}
+	if err := tx.Model(&hold).Update("status", status).Error; err != nil {
+		return nil, fmt.Errorf("update hold status error: %w", err)
+	}
+	user.Held -= hold.Sum
+	return &hold, nil
+}
+
+func holdStatus(order string, err error) (int, error) {
+	switch {
+	case err == nil:
+		return http.StatusOK, nil
+	case errors.Is(err, errHoldNotFound):
+		return http.StatusNotFound, fmt.Errorf("order '%s': %w", order, err)
+	case errors.Is(err, errHoldExpired):
+		return http.StatusConflict, fmt.Errorf("order '%s': %w", order, err)
+	default:
+		return withdrawStatus(err)
+	}
+}
+
+// CaptureHold converts user's active hold of order to withdrawal.
+func (s *psqlStorage) CaptureHold(ctx context.Context, uid int, order string, cancelWindow time.Duration) (int, error) {
+	err := s.con.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var user Users
+		hold, err := finishHold(tx, uid, order, HoldCaptured, &user)
+		if err != nil {
+			return err
+		}
+		return addWithdraw(tx, &user, order, hold.Sum, cancelWindow)
+	})
+	return holdStatus(order, err)
+}
+
+// ReleaseHold returns user's active hold of order to available balance.
+func (s *psqlStorage) ReleaseHold(ctx context.Context, uid int, order string) (int, error) {
+	err := s.con.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var user Users
+		if _, err := finishHold(tx, uid, order, HoldReleased, &user); err != nil {
+			return err
+		}
+		if err := tx.Save(&user).Error; err != nil {
+			return fmt.Errorf("update user held error: %w", err)
+		}
+		return nil
+	})
+	return holdStatus(order, err)
+}
+
+// takeHolds returns holds to release, so sum of other holds does not exceed balance.
+// Holds are taken in order of slice.
+func takeHolds(holds []Holds, held, balance float32) []Holds {
+	count := 0
+	for count < len(holds) && held > balance {
+		held -= holds[count].Sum
+		count++
+	}
+	return holds[:count]
+}
+
+// releaseExcessHolds releases the newest active holds of locked user if held sum exceeds balance.
+// It is used when order accrual decreases, so held sum can not become greater than balance.
+func releaseExcessHolds(tx *gorm.DB, user *Users) error {
+	if user.Held <= user.Balance {
+		return nil
+	}
+	var holds []Holds
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("uid = ? AND status = ?", user.ID, HoldActive).Order("created_at DESC").Find(&holds).Error
+	if err != nil {
+		return fmt.Errorf("select holds error: %w", err)
+	}
+	for _, hold := range takeHolds(holds, user.Held, user.Balance) {
+		if err := tx.Model(&hold).Update("status", HoldReleased).Error; err != nil {
+			return fmt.Errorf("release hold error: %w", err)
+		}
+		user.Held -= hold.Sum
+	}
+	return nil
+}
+
+// ExpireHolds marks active holds with expired time and returns their sums to available balances.
+// Returns count of expired holds.
+func (s *psqlStorage) ExpireHolds(ctx context.Context) (int64, error) {
+	var count int64
+	err := s.con.WithContext(ctx).Raw(`
+		WITH expired AS (
+			UPDATE holds SET status = ?, updated_at = ? WHERE status = ? AND expires_at < ?
+			RETURNING uid, sum
+		), totals AS (
+			SELECT uid, sum(sum) AS sum, count(*) AS count FROM expired GROUP BY uid
+		), updated AS (
+			UPDATE users SET held = held - totals.sum FROM totals WHERE users.id = totals.uid
+			RETURNING totals.count
+		)
+		SELECT coalesce(sum(count), 0) FROM updated`, HoldExpired, time.Now(), HoldActive, time.Now()).
+		Scan(&count).Error
+	if err != nil {
+		return 0, fmt.Errorf("expire holds error: %w", err)
+	}
+	return count, nil
+}
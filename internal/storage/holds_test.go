@@ -0,0 +1,29 @@
+package storage
+
+import "testing"
+
+func TestTakeHolds(t *testing.T) {
+	tests := []struct {
+		name    string
+		sums    []float32
+		held    float32
+		balance float32
+		want    int
+	}{
+		{name: "Резервы не превышают баланс", sums: []float32{30, 20}, held: 50, balance: 60},
+		{name: "Отмена одного резерва", sums: []float32{30, 20}, held: 50, balance: 40, want: 1},
+		{name: "Отмена всех резервов", sums: []float32{30, 20}, held: 50, balance: 10, want: 2},
+		{name: "Нулевой баланс", sums: []float32{30, 20}, held: 50, want: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			holds := make([]Holds, 0, len(tt.sums))
+			for _, sum := range tt.sums {
+				holds = append(holds, Holds{Sum: sum, Status: HoldActive})
+			}
+			if got := takeHolds(holds, tt.held, tt.balance); len(got) != tt.want {
+				t.Errorf("takeHolds() released %d holds, want %d", len(got), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gostuding/goMarket/internal/idempotency"
+	"gorm.io/gorm"
+)
+
+const idempotencySweepInterval = time.Minute
+
+type idempotencyRow struct {
+	Fingerprint string
+	ContentType string
+	Body        []byte
+	Status      int
+	Done        bool
+}
+
+// sweepIdempotencyKeys removes expired keys not often than once a minute per instance.
+func (s *psqlStorage) sweepIdempotencyKeys(ctx context.Context, now time.Time) error {
+	last := s.idempotencySweep.Load()
+	if now.Sub(time.Unix(0, last)) < idempotencySweepInterval ||
+		!s.idempotencySweep.CompareAndSwap(last, now.UnixNano()) {
+		return nil
+	}
+	if err := s.con.WithContext(ctx).Exec("DELETE FROM idempotency_keys WHERE expires_at < ?", now).Error; err != nil {
+		return fmt.Errorf("delete expired idempotency keys error: %w", err)
+	}
+	return nil
+}
+
+// StartIdempotent saves new idempotency key of user or returns record of the key used earlier.
+func (s *psqlStorage) StartIdempotent(ctx context.Context, uid int, key, fingerprint string,
+	ttl time.Duration) (*idempotency.Record, bool, error) {
+	now := time.Now()
+	if err := s.sweepIdempotencyKeys(ctx, now); err != nil {
+		return nil, false, err
+	}
+	var (
+		row     idempotencyRow
+		created bool
+	)
+	err := s.con.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Exec("DELETE FROM idempotency_keys WHERE uid = ? AND key = ? AND expires_at < ?",
+			uid, key, now).Error
+		if err != nil {
+			return fmt.Errorf("delete expired idempotency key error: %w", err)
+		}
+		result := tx.Exec(`INSERT INTO idempotency_keys (uid, key, fingerprint, created_at, expires_at)
+			VALUES (?, ?, ?, ?, ?) ON CONFLICT (uid, key) DO NOTHING`, uid, key, fingerprint, now, now.Add(ttl))
+		if result.Error != nil {
+			return fmt.Errorf("insert idempotency key error: %w", result.Error)
+		}
+		if result.RowsAffected > 0 {
+			created = true
+			return nil
+		}
+		err = tx.Raw(`SELECT fingerprint, content_type, body, status, done FROM idempotency_keys
+			WHERE uid = ? AND key = ?`, uid, key).Scan(&row).Error
+		if err != nil {
+			return fmt.Errorf("select idempotency key error: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, false, fmt.Errorf("idempotency key transaction error: %w", err)
+	}
+	if created {
+		return nil, true, nil
+	}
+	record := idempotency.Record(row)
+	return &record, false, nil
+}
+
+// FinishIdempotent saves response of request with idempotency key.
+func (s *psqlStorage) FinishIdempotent(ctx context.Context, uid int, key string, record *idempotency.Record) error {
+	err := s.con.WithContext(ctx).Exec(`UPDATE idempotency_keys SET done = true, status = ?, content_type = ?, body = ?
+		WHERE uid = ? AND key = ?`, record.Status, record.ContentType, record.Body, uid, key).Error
+	if err != nil {
+		return fmt.Errorf("update idempotency key error: %w", err)
+	}
+	return nil
+}
+
+// CancelIdempotent removes unfinished idempotency key, so request can be retried.
+func (s *psqlStorage) CancelIdempotent(ctx context.Context, uid int, key string) error {
+	err := s.con.WithContext(ctx).Exec("DELETE FROM idempotency_keys WHERE uid = ? AND key = ? AND NOT done",
+		uid, key).Error
+	if err != nil {
+		return fmt.Errorf("delete idempotency key error: %w", err)
+	}
+	return nil
+}
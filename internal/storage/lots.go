@@ -0,0 +1,220 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Point lot sources.
+const (
+	LotAccrual  = "accrual"
+	LotTransfer = "transfer"
+	LotRefund   = "refund"
+	LotTier     = "tier"
+	LotCampaign = "campaign"
+	LotReferral = "referral"
+	LotLegacy   = "legacy" // balance accrued before lots existed, created by migration
+)
+
+// PointLots is part of user balance credited at once. Points of lot expire at ExpiresAt if it is set.
+type PointLots struct {
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	ExpiresAt *time.Time
+	Number    *string
+	Source    string  `gorm:"type:varchar(10)"`
+	Amount    float32 `gorm:"type:numeric"`
+	Remaining float32 `gorm:"type:numeric"`
+	ID        uint    `gorm:"primarykey"`
+	UID       int     `gorm:"type:bigint"`
+}
+
+type LotSpends struct {
+	LotID      uint    `gorm:"primaryKey"`
+	WithdrawID uint    `gorm:"primaryKey"`
+	Amount     float32 `gorm:"type:numeric"`
+}
+
+type PointExpirations struct {
+	CreatedAt time.Time
+	LotID     uint
+	Sum       float32 `gorm:"type:numeric"`
+	ID        uint    `gorm:"primarykey"`
+	UID       int     `gorm:"type:bigint"`
+}
+
+type lotSpend struct {
+	lot    *PointLots
+	amount float32
+}
+
+// takeLots takes sum from lots in their order. Returns taken parts and sum not covered by lots.
+func takeLots(lots []PointLots, sum float32) ([]lotSpend, float32) {
+	spends := make([]lotSpend, 0)
+	for i := range lots {
+		if sum <= 0 {
+			break
+		}
+		amount := lots[i].Remaining
+		if amount > sum {
+			amount = sum
+		}
+		if amount <= 0 {
+			continue
+		}
+		lots[i].Remaining -= amount
+		sum -= amount
+		spends = append(spends, lotSpend{lot: &lots[i], amount: amount})
+	}
+	return spends, sum
+}
+
+// expiresAt returns expiry time of points credited now.
+func (s *psqlStorage) expiresAt() *time.Time {
+	if s.loyalty.PointsExpiryMonths == 0 {
+		return nil
+	}
+	expires := time.Now().AddDate(0, s.loyalty.PointsExpiryMonths, 0)
+	return &expires
+}
+
+// creditLot adds new lot of user points.
+func creditLot(tx *gorm.DB, uid int, amount float32, source string, number *string, expiresAt *time.Time) error {
+	if amount <= 0 {
+		return nil
+	}
+	lot := PointLots{UID: uid, Amount: amount, Remaining: amount, Source: source, Number: number,
+		ExpiresAt: expiresAt}
+	if err := tx.Create(&lot).Error; err != nil {
+		return fmt.Errorf("create points lot error: %w", err)
+	}
+	return nil
+}
+
+// consumeLots takes sum from user lots, the soonest expiring first. Taken parts are saved for withdrawal
+// if withdrawID is not zero, so they can be restored on withdrawal cancel.
+// Returns the earliest expiry time of taken lots.
+func consumeLots(tx *gorm.DB, uid int, sum float32, withdrawID uint) (*time.Time, error) {
+	var lots []PointLots
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("uid = ? AND remaining > 0", uid).
+		Order("expires_at NULLS LAST, id").Find(&lots).Error
+	if err != nil {
+		return nil, fmt.Errorf("select points lots error: %w", err)
+	}
+	spends, _ := takeLots(lots, sum)
+	var earliest *time.Time
+	for _, spend := range spends {
+		if err = tx.Model(spend.lot).Update("remaining", spend.lot.Remaining).Error; err != nil {
+			return nil, fmt.Errorf("update points lot error: %w", err)
+		}
+		if withdrawID != 0 {
+			err = tx.Create(&LotSpends{LotID: spend.lot.ID, WithdrawID: withdrawID, Amount: spend.amount}).Error
+			if err != nil {
+				return nil, fmt.Errorf("create lot spend error: %w", err)
+			}
+		}
+		if spend.lot.ExpiresAt != nil && (earliest == nil || spend.lot.ExpiresAt.Before(*earliest)) {
+			earliest = spend.lot.ExpiresAt
+		}
+	}
+	return earliest, nil
+}
+
+// restoreLots returns points of withdrawal to lots they were taken from.
+// Points taken before lots existed are returned as new lot.
+func (s *psqlStorage) restoreLots(tx *gorm.DB, withdraw *Withdraws) error {
+	var spends []LotSpends
+	if err := tx.Where("withdraw_id = ?", withdraw.ID).Find(&spends).Error; err != nil {
+		return fmt.Errorf("select lot spends error: %w", err)
+	}
+	restored := float32(0)
+	for _, spend := range spends {
+		err := tx.Model(&PointLots{}).Where("id = ?", spend.LotID).
+			Update("remaining", gorm.Expr("remaining + ?", spend.Amount)).Error
+		if err != nil {
+			return fmt.Errorf("restore points lot error: %w", err)
+		}
+		restored += spend.Amount
+	}
+	if err := tx.Where("withdraw_id = ?", withdraw.ID).Delete(&LotSpends{}).Error; err != nil {
+		return fmt.Errorf("delete lot spends error: %w", err)
+	}
+	return creditLot(tx, withdraw.UID, withdraw.Sum-restored, LotRefund, &withdraw.Number, s.expiresAt())
+}
+
+// expireUserPoints expires points of user lots with expired time. Held points are not expired,
+// they expire by the next run if hold is released.
+func expireUserPoints(tx *gorm.DB, uid int) (int64, error) {
+	var user Users
+	if err := lockUser(tx, uid, &user); err != nil {
+		return 0, err
+	}
+	var lots []PointLots
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("uid = ? AND remaining > 0 AND expires_at < ?", uid, time.Now()).
+		Order("expires_at, id").Find(&lots).Error
+	if err != nil {
+		return 0, fmt.Errorf("select expired lots error: %w", err)
+	}
+	spends, _ := takeLots(lots, user.Balance-user.Held)
+	expired := float32(0)
+	for _, spend := range spends {
+		if err = tx.Model(spend.lot).Update("remaining", spend.lot.Remaining).Error; err != nil {
+			return 0, fmt.Errorf("update points lot error: %w", err)
+		}
+		err = tx.Create(&PointExpirations{UID: uid, LotID: spend.lot.ID, Sum: spend.amount}).Error
+		if err != nil {
+			return 0, fmt.Errorf("create points expiration error: %w", err)
+		}
+		expired += spend.amount
+	}
+	if expired > 0 {
+		if err = tx.Model(&user).Update("balance", gorm.Expr("balance - ?", expired)).Error; err != nil {
+			return 0, fmt.Errorf("update user balance error: %w", err)
+		}
+	}
+	return int64(len(spends)), nil
+}
+
+// ExpirePoints expires points of lots with expired time. Returns count of expired lots.
+func (s *psqlStorage) ExpirePoints(ctx context.Context) (int64, error) {
+	var uids []int
+	err := s.con.WithContext(ctx).Model(&PointLots{}).Distinct("uid").
+		Where("remaining > 0 AND expires_at < ?", time.Now()).Pluck("uid", &uids).Error
+	if err != nil {
+		return 0, fmt.Errorf("select users with expired points error: %w", err)
+	}
+	var total int64
+	for _, uid := range uids {
+		var count int64
+		err = s.con.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			count, err = expireUserPoints(tx, uid)
+			return err
+		})
+		if err != nil {
+			return total, fmt.Errorf("expire points of user %d error: %w", uid, err)
+		}
+		total += count
+	}
+	return total, nil
+}
+
+// expiringSoon returns sum of user points expiring in configured period and the nearest expiry time.
+func (s *psqlStorage) expiringSoon(ctx context.Context, uid int) (float32, *time.Time, error) {
+	var soon struct {
+		At  *time.Time
+		Sum float32
+	}
+	err := s.con.WithContext(ctx).Model(&PointLots{}).
+		Select("coalesce(sum(remaining), 0) AS sum, min(expires_at) AS at").
+		Where("uid = ? AND remaining > 0 AND expires_at < ?", uid, time.Now().AddDate(0, 0, s.loyalty.ExpiringSoonDays)).
+		Scan(&soon).Error
+	if err != nil {
+		return 0, nil, fmt.Errorf("select expiring points error: %w", err)
+	}
+	return soon.Sum, soon.At, nil
+}
@@ -0,0 +1,39 @@
+package storage
+
+import "testing"
+
+func TestTakeLots(t *testing.T) {
+	tests := []struct {
+		name      string
+		remaining []float32
+		sum       float32
+		want      []float32
+		wantLeft  float32
+		spends    int
+	}{
+		{name: "Списание из первой партии", remaining: []float32{10, 20}, sum: 5, want: []float32{5, 20}, spends: 1},
+		{name: "Списание из нескольких партий", remaining: []float32{10, 20}, sum: 15, want: []float32{0, 15},
+			spends: 2},
+		{name: "Пустые партии пропускаются", remaining: []float32{0, 20}, sum: 5, want: []float32{0, 15}, spends: 1},
+		{name: "Недостаточно баллов в партиях", remaining: []float32{10}, sum: 15, want: []float32{0}, wantLeft: 5,
+			spends: 1},
+		{name: "Нулевая сумма", remaining: []float32{10}, sum: 0, want: []float32{10}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lots := make([]PointLots, 0, len(tt.remaining))
+			for _, value := range tt.remaining {
+				lots = append(lots, PointLots{Remaining: value, Amount: value})
+			}
+			spends, left := takeLots(lots, tt.sum)
+			if left != tt.wantLeft || len(spends) != tt.spends {
+				t.Errorf("takeLots() left = %v, spends = %d, want %v, %d", left, len(spends), tt.wantLeft, tt.spends)
+			}
+			for i, lot := range lots {
+				if lot.Remaining != tt.want[i] {
+					t.Errorf("lot %d remaining = %v, want %v", i, lot.Remaining, tt.want[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+)
+
+const (
+	defaultExpiringSoonDays     = 30
+	defaultTierPeriodDays       = 365
+	defaultTierSilverThreshold  = 1000
+	defaultTierGoldThreshold    = 5000
+	defaultTierMultiplier       = 1
+	maxTierMultiplier           = 10
+	defaultTierBasis            = TierBasisEarned
+	defaultReferralReward       = 100
+	defaultReferralCap          = 10
+	defaultWithdrawMaxShare     = 1
+	tierThresholdsErrorTemplate = "tier thresholds must be 0 < silver < gold, got %v and %v"
+)
+
+// LoyaltyConfig contains points accrual and spending rules.
+type LoyaltyConfig struct {
+	TierBasis             string  `json:"tier_basis"`
+	TierSilverThreshold   float64 `json:"tier_silver_threshold"`
+	TierGoldThreshold     float64 `json:"tier_gold_threshold"`
+	TierBronzeMultiplier  float64 `json:"tier_bronze_multiplier"`
+	TierSilverMultiplier  float64 `json:"tier_silver_multiplier"`
+	TierGoldMultiplier    float64 `json:"tier_gold_multiplier"`
+	ReferralReward        float64 `json:"referral_reward"`
+	WithdrawMinSum        float64 `json:"withdraw_min_sum"`
+	WithdrawMaxSum        float64 `json:"withdraw_max_sum"`
+	WithdrawDailyLimit    float64 `json:"withdraw_daily_limit"`
+	WithdrawMonthlyLimit  float64 `json:"withdraw_monthly_limit"`
+	WithdrawMaxOrderShare float64 `json:"withdraw_max_order_share"`
+	PointsExpiryMonths    int     `json:"points_expiry_months"`
+	ExpiringSoonDays      int     `json:"expiring_soon_days"`
+	TierPeriodDays        int     `json:"tier_period_days"`
+	ReferralCap           int     `json:"referral_cap"`
+	PointsCoolingOffHours int     `json:"points_cooling_off_hours"`
+}
+
+// Validate checks loyalty options values.
+func (cfg *LoyaltyConfig) Validate() error {
+	errs := make([]error, 0)
+	if cfg.PointsExpiryMonths < 0 {
+		errs = append(errs, fmt.Errorf("points expiry months must not be negative, got %d", cfg.PointsExpiryMonths))
+	}
+	if cfg.ExpiringSoonDays <= 0 {
+		errs = append(errs, fmt.Errorf("expiring soon days must be positive, got %d", cfg.ExpiringSoonDays))
+	}
+	if cfg.TierBasis != TierBasisEarned && cfg.TierBasis != TierBasisSpend {
+		errs = append(errs, fmt.Errorf("tier basis must be '%s' or '%s', got '%s'",
+			TierBasisEarned, TierBasisSpend, cfg.TierBasis))
+	}
+	if cfg.TierPeriodDays <= 0 {
+		errs = append(errs, fmt.Errorf("tier period days must be positive, got %d", cfg.TierPeriodDays))
+	}
+	if cfg.TierSilverThreshold <= 0 || cfg.TierGoldThreshold <= cfg.TierSilverThreshold {
+		errs = append(errs, fmt.Errorf(tierThresholdsErrorTemplate, cfg.TierSilverThreshold, cfg.TierGoldThreshold))
+	}
+	for _, tier := range tiers {
+		if value := cfg.tierMultiplier(tier); value < 1 || value > maxTierMultiplier {
+			errs = append(errs, fmt.Errorf("%s tier multiplier must be in [1, %d], got %v", tier, maxTierMultiplier, value))
+		}
+	}
+	if cfg.ReferralReward < 0 || cfg.ReferralCap < 0 {
+		errs = append(errs, errors.New("referral reward and cap must not be negative"))
+	}
+	if cfg.WithdrawMinSum < 0 || cfg.WithdrawMaxSum < 0 || cfg.WithdrawDailyLimit < 0 ||
+		cfg.WithdrawMonthlyLimit < 0 || cfg.PointsCoolingOffHours < 0 {
+		errs = append(errs, errors.New("withdrawal limits and cooling-off period must not be negative"))
+	}
+	if cfg.WithdrawMaxSum > 0 && cfg.WithdrawMaxSum < cfg.WithdrawMinSum {
+		errs = append(errs, fmt.Errorf("withdrawal maximum %v is less than minimum %v",
+			cfg.WithdrawMaxSum, cfg.WithdrawMinSum))
+	}
+	if cfg.WithdrawMaxOrderShare <= 0 || cfg.WithdrawMaxOrderShare > 1 {
+		errs = append(errs, fmt.Errorf("withdrawal order share must be in (0, 1], got %v", cfg.WithdrawMaxOrderShare))
+	}
+	return errors.Join(errs...)
+}
+
+func NewLoyaltyConfig() *LoyaltyConfig {
+	return &LoyaltyConfig{
+		ExpiringSoonDays:      defaultExpiringSoonDays,
+		TierBasis:             defaultTierBasis,
+		TierPeriodDays:        defaultTierPeriodDays,
+		TierSilverThreshold:   defaultTierSilverThreshold,
+		TierGoldThreshold:     defaultTierGoldThreshold,
+		TierBronzeMultiplier:  defaultTierMultiplier,
+		TierSilverMultiplier:  defaultTierMultiplier,
+		TierGoldMultiplier:    defaultTierMultiplier,
+		ReferralReward:        defaultReferralReward,
+		ReferralCap:           defaultReferralCap,
+		WithdrawMaxOrderShare: defaultWithdrawMaxShare,
+	}
+}
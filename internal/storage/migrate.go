@@ -0,0 +1,271 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed migrations/*.sql
+var migrationsFS embed.FS
+
+const (
+	migrationsDir      = "migrations"
+	migrationsLockKey  = 7205372741
+	upSuffix           = ".up.sql"
+	downSuffix         = ".down.sql"
+	createMigrationsDB = `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version bigint PRIMARY KEY,
+		name text NOT NULL,
+		applied_at timestamptz NOT NULL DEFAULT now()
+	)`
+)
+
+var ErrSchemaOutdated = errors.New("database schema is out of date")
+
+type Migration struct {
+	Name    string
+	Up      string
+	Down    string
+	Version int
+}
+
+type MigrationStatus struct {
+	AppliedAt time.Time `json:"applied_at,omitempty"`
+	Name      string    `json:"name"`
+	Version   int       `json:"version"`
+	Applied   bool      `json:"applied"`
+}
+
+// loadMigrations reads embedded migrations named as NNNN_name.up.sql and NNNN_name.down.sql.
+func loadMigrations() ([]Migration, error) {
+	entries, err := migrationsFS.ReadDir(migrationsDir)
+	if err != nil {
+		return nil, fmt.Errorf("read migrations dir error: %w", err)
+	}
+	items := make(map[int]*Migration)
+	for _, entry := range entries {
+		name := entry.Name()
+		var suffix string
+		switch {
+		case strings.HasSuffix(name, upSuffix):
+			suffix = upSuffix
+		case strings.HasSuffix(name, downSuffix):
+			suffix = downSuffix
+		default:
+			continue
+		}
+		base := strings.TrimSuffix(name, suffix)
+		number, title, ok := strings.Cut(base, "_")
+		if !ok {
+			return nil, fmt.Errorf("migration file name incorrect: '%s'", name)
+		}
+		version, err := strconv.Atoi(number)
+		if err != nil {
+			return nil, fmt.Errorf("migration '%s' version error: %w", name, err)
+		}
+		data, err := migrationsFS.ReadFile(path.Join(migrationsDir, name))
+		if err != nil {
+			return nil, fmt.Errorf("read migration '%s' error: %w", name, err)
+		}
+		item, ok := items[version]
+		if !ok {
+			item = &Migration{Version: version, Name: title}
+			items[version] = item
+		}
+		if suffix == upSuffix {
+			item.Up = string(data)
+		} else {
+			item.Down = string(data)
+		}
+	}
+	migrations := make([]Migration, 0, len(items))
+	for _, item := range items {
+		if item.Up == "" {
+			return nil, fmt.Errorf("migration %d has no up script", item.Version)
+		}
+		migrations = append(migrations, *item)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// withMigrationsLock runs f on single connection holding postgres advisory lock,
+// so replicas started together apply migrations one by one.
+func withMigrationsLock(ctx context.Context, db *sql.DB, f func(*sql.Conn) error) (err error) {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("get connection error: %w", err)
+	}
+	defer conn.Close() //nolint:errcheck // <- senselessly
+	if _, err = conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", migrationsLockKey); err != nil {
+		return fmt.Errorf("migrations lock error: %w", err)
+	}
+	defer func() {
+		_, unlockErr := conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", migrationsLockKey)
+		if unlockErr != nil {
+			err = errors.Join(err, fmt.Errorf("migrations unlock error: %w", unlockErr))
+		}
+	}()
+	if _, err = conn.ExecContext(ctx, createMigrationsDB); err != nil {
+		return fmt.Errorf("create schema_migrations error: %w", err)
+	}
+	return f(conn)
+}
+
+type queryer interface {
+	QueryContext(context.Context, string, ...any) (*sql.Rows, error)
+}
+
+func appliedMigrations(ctx context.Context, q queryer) (map[int]time.Time, error) {
+	rows, err := q.QueryContext(ctx, "SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, fmt.Errorf("select applied migrations error: %w", err)
+	}
+	defer rows.Close() //nolint:errcheck // <- senselessly
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err = rows.Scan(&version, &appliedAt); err != nil {
+			return nil, fmt.Errorf("scan applied migration error: %w", err)
+		}
+		applied[version] = appliedAt
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("applied migrations rows error: %w", err)
+	}
+	return applied, nil
+}
+
+func runMigration(ctx context.Context, conn *sql.Conn, script string, post string, args ...any) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction error: %w", err)
+	}
+	if _, err = tx.ExecContext(ctx, script); err != nil {
+		return errors.Join(fmt.Errorf("migration script error: %w", err), tx.Rollback())
+	}
+	if _, err = tx.ExecContext(ctx, post, args...); err != nil {
+		return errors.Join(fmt.Errorf("schema_migrations update error: %w", err), tx.Rollback())
+	}
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("commit migration error: %w", err)
+	}
+	return nil
+}
+
+// MigrateUp applies all not applied migrations and returns their versions.
+func MigrateUp(ctx context.Context, db *sql.DB) ([]int, error) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return nil, err
+	}
+	done := make([]int, 0)
+	err = withMigrationsLock(ctx, db, func(conn *sql.Conn) error {
+		applied, err := appliedMigrations(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, item := range migrations {
+			if _, ok := applied[item.Version]; ok {
+				continue
+			}
+			err = runMigration(ctx, conn, item.Up,
+				"INSERT INTO schema_migrations (version, name) VALUES ($1, $2)", item.Version, item.Name)
+			if err != nil {
+				return fmt.Errorf("migration %d (%s) up error: %w", item.Version, item.Name, err)
+			}
+			done = append(done, item.Version)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// MigrateDown rolls back the last steps applied migrations and returns their versions.
+func MigrateDown(ctx context.Context, db *sql.DB, steps int) ([]int, error) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return nil, err
+	}
+	done := make([]int, 0)
+	err = withMigrationsLock(ctx, db, func(conn *sql.Conn) error {
+		applied, err := appliedMigrations(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for i := len(migrations) - 1; i >= 0 && len(done) < steps; i-- {
+			item := migrations[i]
+			if _, ok := applied[item.Version]; !ok {
+				continue
+			}
+			if item.Down == "" {
+				return fmt.Errorf("migration %d (%s) has no down script", item.Version, item.Name)
+			}
+			err = runMigration(ctx, conn, item.Down, "DELETE FROM schema_migrations WHERE version = $1", item.Version)
+			if err != nil {
+				return fmt.Errorf("migration %d (%s) down error: %w", item.Version, item.Name, err)
+			}
+			done = append(done, item.Version)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// MigrationsStatus returns list of all known migrations with applied flag.
+func MigrationsStatus(ctx context.Context, db *sql.DB) ([]MigrationStatus, error) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return nil, err
+	}
+	if _, err = db.ExecContext(ctx, createMigrationsDB); err != nil {
+		return nil, fmt.Errorf("create schema_migrations error: %w", err)
+	}
+	applied, err := appliedMigrations(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	status := make([]MigrationStatus, 0, len(migrations))
+	for _, item := range migrations {
+		appliedAt, ok := applied[item.Version]
+		status = append(status, MigrationStatus{
+			Version: item.Version, Name: item.Name, Applied: ok, AppliedAt: appliedAt,
+		})
+	}
+	return status, nil
+}
+
+// IsSchemaActual checks that all embedded migrations are applied.
+func IsSchemaActual(ctx context.Context, db *sql.DB) (bool, error) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return false, err
+	}
+	var exists bool
+	err = db.QueryRowContext(ctx, "SELECT to_regclass('schema_migrations') IS NOT NULL").Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("check schema_migrations error: %w", err)
+	}
+	if !exists {
+		return false, nil
+	}
+	applied, err := appliedMigrations(ctx, db)
+	if err != nil {
+		return false, err
+	}
+	for _, item := range migrations {
+		if _, ok := applied[item.Version]; !ok {
+			return false, nil
+		}
+	}
+	return true, nil
+}
@@ -0,0 +1,24 @@
+package storage
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLoadMigrations(t *testing.T) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		t.Fatalf("loadMigrations() error = %v", err)
+	}
+	if len(migrations) == 0 {
+		t.Fatal("loadMigrations() returned empty list")
+	}
+	for i, item := range migrations {
+		if i > 0 && item.Version <= migrations[i-1].Version {
+			t.Errorf("migration %d is not sorted by version", item.Version)
+		}
+		if strings.TrimSpace(item.Up) == "" || strings.TrimSpace(item.Down) == "" {
+			t.Errorf("migration %d (%s) has empty up or down script", item.Version, item.Name)
+		}
+	}
+}
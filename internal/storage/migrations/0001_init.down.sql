@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS withdraws;
+DROP TABLE IF EXISTS orders;
+DROP TABLE IF EXISTS users;
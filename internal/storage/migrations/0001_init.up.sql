@@ -0,0 +1,29 @@
+CREATE TABLE IF NOT EXISTS users (
+    id bigserial PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    login text UNIQUE,
+    pwd varchar(255),
+    user_agent varchar(255),
+    ip varchar(15),
+    balance numeric,
+    withdrawn numeric
+);
+
+CREATE TABLE IF NOT EXISTS orders (
+    id bigserial PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    number text UNIQUE,
+    status varchar(10),
+    accrual numeric,
+    uid int
+);
+
+CREATE TABLE IF NOT EXISTS withdraws (
+    id bigserial PRIMARY KEY,
+    created_at timestamptz,
+    number text UNIQUE,
+    sum numeric,
+    uid int
+);
@@ -0,0 +1,25 @@
+DROP INDEX IF EXISTS withdraws_uid_id_idx;
+ALTER TABLE withdraws
+    DROP CONSTRAINT IF EXISTS withdraws_sum_check,
+    DROP CONSTRAINT IF EXISTS withdraws_uid_fkey,
+    ALTER COLUMN uid DROP NOT NULL,
+    ALTER COLUMN uid TYPE int;
+
+DROP INDEX IF EXISTS orders_unfinished_idx;
+DROP INDEX IF EXISTS orders_uid_id_idx;
+ALTER TABLE orders
+    DROP CONSTRAINT IF EXISTS orders_accrual_check,
+    DROP CONSTRAINT IF EXISTS orders_status_check,
+    DROP CONSTRAINT IF EXISTS orders_uid_fkey,
+    ALTER COLUMN uid DROP NOT NULL,
+    ALTER COLUMN uid TYPE int;
+
+ALTER TABLE users
+    DROP CONSTRAINT IF EXISTS users_withdrawn_check,
+    DROP CONSTRAINT IF EXISTS users_balance_check,
+    ALTER COLUMN withdrawn DROP NOT NULL,
+    ALTER COLUMN withdrawn DROP DEFAULT,
+    ALTER COLUMN balance DROP NOT NULL,
+    ALTER COLUMN balance DROP DEFAULT;
+
+ALTER TABLE users ALTER COLUMN ip TYPE varchar(15);
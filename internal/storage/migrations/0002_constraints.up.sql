@@ -0,0 +1,30 @@
+ALTER TABLE users ALTER COLUMN ip TYPE varchar(45);
+
+UPDATE users SET balance = 0 WHERE balance IS NULL;
+UPDATE users SET withdrawn = 0 WHERE withdrawn IS NULL;
+ALTER TABLE users
+    ALTER COLUMN balance SET DEFAULT 0,
+    ALTER COLUMN balance SET NOT NULL,
+    ALTER COLUMN withdrawn SET DEFAULT 0,
+    ALTER COLUMN withdrawn SET NOT NULL,
+    ADD CONSTRAINT users_balance_check CHECK (balance >= 0),
+    ADD CONSTRAINT users_withdrawn_check CHECK (withdrawn >= 0);
+
+ALTER TABLE orders
+    ALTER COLUMN uid TYPE bigint,
+    ALTER COLUMN uid SET NOT NULL,
+    ADD CONSTRAINT orders_uid_fkey FOREIGN KEY (uid) REFERENCES users (id),
+    ADD CONSTRAINT orders_status_check
+        CHECK (status IN ('NEW', 'REGISTERED', 'PROCESSING', 'INVALID', 'PROCESSED')),
+    ADD CONSTRAINT orders_accrual_check CHECK (accrual IS NULL OR accrual >= 0);
+
+CREATE INDEX orders_uid_id_idx ON orders (uid, id DESC);
+CREATE INDEX orders_unfinished_idx ON orders (id) WHERE status NOT IN ('INVALID', 'PROCESSED');
+
+ALTER TABLE withdraws
+    ALTER COLUMN uid TYPE bigint,
+    ALTER COLUMN uid SET NOT NULL,
+    ADD CONSTRAINT withdraws_uid_fkey FOREIGN KEY (uid) REFERENCES users (id),
+    ADD CONSTRAINT withdraws_sum_check CHECK (sum > 0);
+
+CREATE INDEX withdraws_uid_id_idx ON withdraws (uid, id DESC);
@@ -0,0 +1 @@
+ALTER TABLE users ADD COLUMN blocked boolean NOT NULL DEFAULT false;
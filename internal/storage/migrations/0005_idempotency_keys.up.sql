@@ -0,0 +1,14 @@
+CREATE TABLE idempotency_keys (
+    uid bigint NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    key varchar(255) NOT NULL,
+    fingerprint text NOT NULL,
+    done boolean NOT NULL DEFAULT false,
+    status integer NOT NULL DEFAULT 0,
+    content_type text NOT NULL DEFAULT '',
+    body bytea,
+    created_at timestamptz NOT NULL,
+    expires_at timestamptz NOT NULL,
+    PRIMARY KEY (uid, key)
+);
+
+CREATE INDEX idempotency_keys_expires_at_idx ON idempotency_keys (expires_at);
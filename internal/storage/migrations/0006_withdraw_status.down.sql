@@ -0,0 +1,11 @@
+-- Reverted withdrawals are already returned to users balances.
+
+DELETE FROM withdraws WHERE status IN ('CANCELLED', 'REFUNDED');
+
+DROP INDEX IF EXISTS withdraws_pending_idx;
+
+ALTER TABLE withdraws
+    DROP CONSTRAINT IF EXISTS withdraws_status_check,
+    DROP COLUMN IF EXISTS cancel_until,
+    DROP COLUMN IF EXISTS status,
+    DROP COLUMN IF EXISTS updated_at;
@@ -0,0 +1,8 @@
+ALTER TABLE withdraws
+    ADD COLUMN updated_at timestamptz,
+    ADD COLUMN status varchar(10) NOT NULL DEFAULT 'COMPLETED',
+    ADD COLUMN cancel_until timestamptz,
+    ADD CONSTRAINT withdraws_status_check
+        CHECK (status IN ('PENDING', 'COMPLETED', 'CANCELLED', 'REFUNDED'));
+
+CREATE INDEX withdraws_pending_idx ON withdraws (uid, cancel_until) WHERE status = 'PENDING';
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS holds;
+
+ALTER TABLE users
+    DROP CONSTRAINT IF EXISTS users_held_check,
+    DROP COLUMN IF EXISTS held;
@@ -0,0 +1,19 @@
+ALTER TABLE users
+    ADD COLUMN held numeric NOT NULL DEFAULT 0,
+    ADD CONSTRAINT users_held_check CHECK (held >= 0 AND held <= balance);
+
+CREATE TABLE holds (
+    id bigserial PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    expires_at timestamptz NOT NULL,
+    number text NOT NULL,
+    status varchar(10) NOT NULL,
+    sum numeric NOT NULL,
+    uid bigint NOT NULL REFERENCES users (id),
+    CONSTRAINT holds_status_check CHECK (status IN ('ACTIVE', 'CAPTURED', 'RELEASED', 'EXPIRED')),
+    CONSTRAINT holds_sum_check CHECK (sum > 0)
+);
+
+CREATE UNIQUE INDEX holds_active_number_idx ON holds (number) WHERE status = 'ACTIVE';
+CREATE INDEX holds_active_expires_at_idx ON holds (expires_at) WHERE status = 'ACTIVE';
@@ -0,0 +1,17 @@
+CREATE TABLE transfers (
+    id bigserial PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    expires_at timestamptz NOT NULL,
+    confirmed_at timestamptz,
+    status varchar(10) NOT NULL,
+    sum numeric NOT NULL,
+    sender_uid bigint NOT NULL REFERENCES users (id),
+    recipient_uid bigint NOT NULL REFERENCES users (id),
+    CONSTRAINT transfers_status_check CHECK (status IN ('PENDING', 'COMPLETED', 'EXPIRED')),
+    CONSTRAINT transfers_sum_check CHECK (sum > 0),
+    CONSTRAINT transfers_users_check CHECK (sender_uid <> recipient_uid)
+);
+
+CREATE INDEX transfers_sender_idx ON transfers (sender_uid, confirmed_at);
+CREATE INDEX transfers_recipient_idx ON transfers (recipient_uid, confirmed_at);
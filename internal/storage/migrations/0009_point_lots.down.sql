@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS point_expirations;
+DROP TABLE IF EXISTS lot_spends;
+DROP TABLE IF EXISTS point_lots;
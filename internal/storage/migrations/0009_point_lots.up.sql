@@ -0,0 +1,36 @@
+CREATE TABLE point_lots (
+    id bigserial PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    expires_at timestamptz,
+    source varchar(10) NOT NULL,
+    number text,
+    amount numeric NOT NULL,
+    remaining numeric NOT NULL,
+    uid bigint NOT NULL REFERENCES users (id),
+    CONSTRAINT point_lots_remaining_check CHECK (remaining >= 0 AND remaining <= amount)
+);
+
+CREATE INDEX point_lots_uid_idx ON point_lots (uid, expires_at) WHERE remaining > 0;
+CREATE INDEX point_lots_expires_at_idx ON point_lots (expires_at) WHERE remaining > 0;
+
+CREATE TABLE lot_spends (
+    lot_id bigint NOT NULL REFERENCES point_lots (id),
+    withdraw_id bigint NOT NULL REFERENCES withdraws (id),
+    amount numeric NOT NULL,
+    PRIMARY KEY (withdraw_id, lot_id)
+);
+
+CREATE TABLE point_expirations (
+    id bigserial PRIMARY KEY,
+    created_at timestamptz,
+    lot_id bigint NOT NULL REFERENCES point_lots (id),
+    sum numeric NOT NULL,
+    uid bigint NOT NULL REFERENCES users (id)
+);
+
+CREATE INDEX point_expirations_uid_idx ON point_expirations (uid, id DESC);
+
+-- Points accrued before lots existed do not expire.
+INSERT INTO point_lots (created_at, updated_at, source, amount, remaining, uid)
+SELECT now(), now(), 'legacy', balance, balance, id FROM users WHERE balance > 0;
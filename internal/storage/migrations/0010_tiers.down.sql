@@ -0,0 +1,2 @@
+ALTER TABLE orders DROP COLUMN tier_bonus;
+ALTER TABLE users DROP COLUMN tier;
@@ -0,0 +1,2 @@
+ALTER TABLE users ADD COLUMN tier varchar(10) NOT NULL DEFAULT 'bronze';
+ALTER TABLE orders ADD COLUMN tier_bonus numeric NOT NULL DEFAULT 0;
@@ -0,0 +1,4 @@
+DROP TABLE campaign_credits;
+DROP TABLE promo_codes;
+DROP TABLE campaign_targets;
+DROP TABLE campaigns;
@@ -0,0 +1,44 @@
+CREATE TABLE campaigns (
+    id bigserial PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    starts_at timestamptz NOT NULL,
+    ends_at timestamptz NOT NULL,
+    name text NOT NULL UNIQUE,
+    event varchar(10) NOT NULL,
+    tier varchar(10) NOT NULL DEFAULT '',
+    bonus numeric NOT NULL DEFAULT 0,
+    multiplier numeric NOT NULL DEFAULT 0,
+    budget numeric NOT NULL DEFAULT 0,
+    spent numeric NOT NULL DEFAULT 0,
+    CONSTRAINT campaigns_budget_check CHECK (budget = 0 OR spent <= budget)
+);
+
+CREATE INDEX campaigns_event_idx ON campaigns (event, ends_at);
+
+CREATE TABLE campaign_targets (
+    campaign_id bigint NOT NULL REFERENCES campaigns (id),
+    uid bigint NOT NULL REFERENCES users (id),
+    PRIMARY KEY (campaign_id, uid)
+);
+
+CREATE TABLE promo_codes (
+    id bigserial PRIMARY KEY,
+    code text NOT NULL UNIQUE,
+    max_uses integer NOT NULL DEFAULT 0,
+    uses integer NOT NULL DEFAULT 0,
+    campaign_id bigint NOT NULL REFERENCES campaigns (id)
+);
+
+CREATE TABLE campaign_credits (
+    id bigserial PRIMARY KEY,
+    created_at timestamptz,
+    number text,
+    code_id bigint REFERENCES promo_codes (id),
+    sum numeric NOT NULL,
+    campaign_id bigint NOT NULL REFERENCES campaigns (id),
+    uid bigint NOT NULL REFERENCES users (id)
+);
+
+CREATE INDEX campaign_credits_uid_idx ON campaign_credits (uid, id DESC);
+CREATE UNIQUE INDEX campaign_credits_code_uid_idx ON campaign_credits (code_id, uid) WHERE code_id IS NOT NULL;
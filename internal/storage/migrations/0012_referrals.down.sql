@@ -0,0 +1,3 @@
+DROP TABLE referrals;
+DROP INDEX users_referral_code_idx;
+ALTER TABLE users DROP COLUMN referral_code;
@@ -0,0 +1,18 @@
+ALTER TABLE users ADD COLUMN referral_code varchar(16);
+UPDATE users SET referral_code = upper(substr(md5(random()::text || id::text), 1, 10));
+ALTER TABLE users ALTER COLUMN referral_code SET NOT NULL;
+CREATE UNIQUE INDEX users_referral_code_idx ON users (referral_code);
+
+CREATE TABLE referrals (
+    id bigserial PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    number text,
+    status varchar(10) NOT NULL,
+    reason varchar(10) NOT NULL DEFAULT '',
+    reward numeric NOT NULL DEFAULT 0,
+    referrer_uid bigint NOT NULL REFERENCES users (id),
+    referee_uid bigint NOT NULL UNIQUE REFERENCES users (id)
+);
+
+CREATE INDEX referrals_referrer_uid_idx ON referrals (referrer_uid, status);
@@ -0,0 +1 @@
+ALTER TABLE users ADD COLUMN auth_reset_at timestamptz;
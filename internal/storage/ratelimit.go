@@ -0,0 +1,63 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gostuding/goMarket/internal/ratelimit"
+	"gorm.io/gorm"
+)
+
+const rateLimitSweepInterval = time.Minute
+
+type rateLimitRow struct {
+	UpdatedAt time.Time
+	Tokens    float64
+}
+
+// sweepRateLimits removes full buckets not often than once a minute per instance.
+func (s *psqlStorage) sweepRateLimits(ctx context.Context, now time.Time) error {
+	last := s.rateLimitSweep.Load()
+	if now.Sub(time.Unix(0, last)) < rateLimitSweepInterval ||
+		!s.rateLimitSweep.CompareAndSwap(last, now.UnixNano()) {
+		return nil
+	}
+	if err := s.con.WithContext(ctx).Exec("DELETE FROM rate_limits WHERE full_at < ?", now).Error; err != nil {
+		return fmt.Errorf("delete full rate limits error: %w", err)
+	}
+	return nil
+}
+
+// Take takes token from bucket shared by all service instances.
+func (s *psqlStorage) Take(ctx context.Context, key string, rule ratelimit.Rule) (ratelimit.Result, error) {
+	var res ratelimit.Result
+	now := time.Now()
+	if err := s.sweepRateLimits(ctx, now); err != nil {
+		return res, err
+	}
+	err := s.con.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Exec(`INSERT INTO rate_limits (key, tokens, updated_at, full_at) VALUES (?, ?, ?, ?)
+			ON CONFLICT (key) DO NOTHING`, key, rule.Limit, now, now).Error
+		if err != nil {
+			return fmt.Errorf("insert rate limit error: %w", err)
+		}
+		var row rateLimitRow
+		err = tx.Raw("SELECT tokens, updated_at FROM rate_limits WHERE key = ? FOR UPDATE", key).Scan(&row).Error
+		if err != nil {
+			return fmt.Errorf("select rate limit error: %w", err)
+		}
+		bucket := ratelimit.Bucket{Tokens: row.Tokens, Updated: row.UpdatedAt}
+		res = bucket.Take(rule, now)
+		err = tx.Exec("UPDATE rate_limits SET tokens = ?, updated_at = ?, full_at = ? WHERE key = ?",
+			bucket.Tokens, bucket.Updated, bucket.Full(rule), key).Error
+		if err != nil {
+			return fmt.Errorf("update rate limit error: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return res, fmt.Errorf("rate limit transaction error: %w", err)
+	}
+	return res, nil
+}
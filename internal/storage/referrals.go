@@ -0,0 +1,198 @@
+package storage
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Referral statuses. Pending referral is rewarded after the first processed order of referee.
+const (
+	ReferralPending  = "PENDING"
+	ReferralRewarded = "REWARDED"
+	ReferralRejected = "REJECTED"
+)
+
+// Referral rejection reasons.
+const (
+	ReferralSelf    = "self"
+	ReferralSameIP  = "same_ip"
+	ReferralCap     = "cap"
+	ReferralBlocked = "blocked"
+)
+
+const referralCodeBytes = 5
+
+var errReferralNotFound = errors.New("referral code not found")
+
+// Referrals is invitation of referee user by referrer user.
+type Referrals struct {
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	Number      *string   `json:"-"`
+	Login       string    `gorm:"->;-:migration" json:"login"`
+	Status      string    `gorm:"type:varchar(10)" json:"status"`
+	Reason      string    `gorm:"type:varchar(10)" json:"reason,omitempty"`
+	Reward      float32   `gorm:"type:numeric" json:"reward,omitempty"`
+	ID          uint      `gorm:"primarykey" json:"-"`
+	ReferrerUID int       `gorm:"type:bigint" json:"-"`
+	RefereeUID  int       `gorm:"type:bigint;unique" json:"-"`
+}
+
+// ReferralsStruct is user referral code with invited users.
+type ReferralsStruct struct {
+	Code      string      `json:"code"`
+	Referrals []Referrals `json:"referrals"`
+}
+
+// newReferralCode returns random referral code.
+func newReferralCode() (string, error) {
+	data := make([]byte, referralCodeBytes)
+	if _, err := rand.Read(data); err != nil {
+		return "", fmt.Errorf("referral code generate error: %w", err)
+	}
+	return base32.StdEncoding.EncodeToString(data), nil
+}
+
+// referralAbuse returns rejection reason if referrer is blocked or referee looks like the referrer.
+// Users from the same device are treated as self-referral, users from the same address are suspicious.
+func referralAbuse(referrer, referee *Users) string {
+	switch {
+	case referrer.Blocked:
+		return ReferralBlocked
+	case referrer.ID == referee.ID:
+		return ReferralSelf
+	case referrer.IP == "" || referrer.IP != referee.IP:
+		return ""
+	case referrer.UserAgent == referee.UserAgent:
+		return ReferralSelf
+	default:
+		return ReferralSameIP
+	}
+}
+
+// addReferral creates referral of created referee user by referrer with code.
+func addReferral(tx *gorm.DB, referee *Users, code string) error {
+	var referrer Users
+	result := tx.Where("referral_code = ?", code).Limit(1).Find(&referrer)
+	if result.Error != nil {
+		return fmt.Errorf("select referrer error: %w", result.Error)
+	}
+	if result.RowsAffected == 0 || referrer.Blocked {
+		return fmt.Errorf("code '%s': %w", code, errReferralNotFound)
+	}
+	referral := Referrals{ReferrerUID: int(referrer.ID), RefereeUID: int(referee.ID), Status: ReferralPending}
+	if referral.Reason = referralAbuse(&referrer, referee); referral.Reason != "" {
+		referral.Status = ReferralRejected
+	}
+	if err := tx.Create(&referral).Error; err != nil {
+		return fmt.Errorf("create referral error: %w", err)
+	}
+	return nil
+}
+
+// lockReferee locks user with referrer of the user's pending referral in id order, as transfers do,
+// so referral reward does not deadlock with concurrent transfers between the same users.
+func lockReferee(tx *gorm.DB, uid int, referee *Users) error {
+	var referrerUID int
+	err := tx.Model(&Referrals{}).Select("referrer_uid").
+		Where("referee_uid = ? AND status = ?", uid, ReferralPending).Limit(1).Scan(&referrerUID).Error
+	if err != nil {
+		return fmt.Errorf("select referrer error: %w", err)
+	}
+	if referrerUID == 0 {
+		return lockUser(tx, uid, referee)
+	}
+	var users []Users
+	err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id IN ?", []int{uid, referrerUID}).Order("id").Find(&users).Error
+	if err != nil {
+		return fmt.Errorf("lock users error: %w", err)
+	}
+	for _, user := range users {
+		if int(user.ID) == uid {
+			*referee = user
+			return nil
+		}
+	}
+	return fmt.Errorf("lock user error: %w", gorm.ErrRecordNotFound)
+}
+
+// rewardReferral rewards referrer of referee user on the first processed order. Returns credited reward.
+// Both users must be locked by lockReferee.
+// Anti-abuse rules are checked again, because users addresses are updated on login.
+func (s *psqlStorage) rewardReferral(tx *gorm.DB, referee *Users, number string) (float32, error) {
+	var referral Referrals
+	result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("referee_uid = ? AND status = ?", referee.ID, ReferralPending).Limit(1).Find(&referral)
+	if result.Error != nil {
+		return 0, fmt.Errorf("select referral error: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return 0, nil
+	}
+	var referrer Users
+	if err := lockUser(tx, referral.ReferrerUID, &referrer); err != nil {
+		return 0, err
+	}
+	referral.Number = &number
+	referral.Status = ReferralRejected
+	if referral.Reason = referralAbuse(&referrer, referee); referral.Reason == "" && s.loyalty.ReferralCap > 0 {
+		var count int64
+		err := tx.Model(&Referrals{}).Where("referrer_uid = ? AND status = ?", referrer.ID, ReferralRewarded).
+			Count(&count).Error
+		if err != nil {
+			return 0, fmt.Errorf("select referrer rewards count error: %w", err)
+		}
+		if count >= int64(s.loyalty.ReferralCap) {
+			referral.Reason = ReferralCap
+		}
+	}
+	if referral.Reason == "" {
+		referral.Status = ReferralRewarded
+		referral.Reward = float32(s.loyalty.ReferralReward)
+		if err := creditLot(tx, referral.ReferrerUID, referral.Reward, LotReferral, &number, s.expiresAt()); err != nil {
+			return 0, err
+		}
+		err := tx.Model(&referrer).Update("balance", gorm.Expr("balance + ?", referral.Reward)).Error
+		if err != nil {
+			return 0, fmt.Errorf("update referrer balance error: %w", err)
+		}
+	}
+	if err := tx.Save(&referral).Error; err != nil {
+		return 0, fmt.Errorf("update referral error: %w", err)
+	}
+	return referral.Reward, nil
+}
+
+// GetReferrals returns user referral code and referrals of invited users.
+func (s *psqlStorage) GetReferrals(ctx context.Context, uid int) ([]byte, error) {
+	var user Users
+	if err := s.con.WithContext(ctx).Where("id = ?", uid).First(&user).Error; err != nil {
+		return nil, fmt.Errorf("get user referral code error: %w", err)
+	}
+	referrals := ReferralsStruct{Code: user.ReferralCode, Referrals: make([]Referrals, 0)}
+	err := s.con.WithContext(ctx).Model(&Referrals{}).
+		Select("referrals.*, users.login").Joins("JOIN users ON users.id = referrals.referee_uid").
+		Where("referrals.referrer_uid = ?", uid).Order("referrals.id DESC").Scan(&referrals.Referrals).Error
+	if err != nil {
+		return nil, fmt.Errorf("get referrals error: %w", err)
+	}
+	data, err := json.Marshal(referrals)
+	if err != nil {
+		return nil, fmt.Errorf("convert referrals to json error: %w", err)
+	}
+	return data, nil
+}
+
+// IsReferralNotFound checks that registration error is caused by unknown referral code.
+func (s *psqlStorage) IsReferralNotFound(err error) bool {
+	return errors.Is(err, errReferralNotFound)
+}
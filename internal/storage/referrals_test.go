@@ -0,0 +1,45 @@
+package storage
+
+import "testing"
+
+func TestReferralAbuse(t *testing.T) {
+	tests := []struct {
+		name     string
+		referrer Users
+		referee  Users
+		want     string
+	}{
+		{name: "Разные адреса", referrer: Users{ID: 1, IP: "10.0.0.1", UserAgent: "ua"},
+			referee: Users{ID: 2, IP: "10.0.0.2", UserAgent: "ua"}},
+		{name: "Пригласивший без адреса", referrer: Users{ID: 1},
+			referee: Users{ID: 2, IP: "10.0.0.2", UserAgent: "ua"}},
+		{name: "Тот же пользователь", referrer: Users{ID: 1}, referee: Users{ID: 1}, want: ReferralSelf},
+		{name: "То же устройство", referrer: Users{ID: 1, IP: "10.0.0.1", UserAgent: "ua"},
+			referee: Users{ID: 2, IP: "10.0.0.1", UserAgent: "ua"}, want: ReferralSelf},
+		{name: "Тот же адрес", referrer: Users{ID: 1, IP: "10.0.0.1", UserAgent: "ua"},
+			referee: Users{ID: 2, IP: "10.0.0.1", UserAgent: "other"}, want: ReferralSameIP},
+		{name: "Пригласивший заблокирован", referrer: Users{ID: 1, IP: "10.0.0.1", UserAgent: "ua", Blocked: true},
+			referee: Users{ID: 2, IP: "10.0.0.2", UserAgent: "ua"}, want: ReferralBlocked},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := referralAbuse(&tt.referrer, &tt.referee); got != tt.want {
+				t.Errorf("referralAbuse() = '%s', want '%s'", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewReferralCode(t *testing.T) {
+	first, err := newReferralCode()
+	if err != nil {
+		t.Fatalf("newReferralCode() error: %v", err)
+	}
+	second, err := newReferralCode()
+	if err != nil {
+		t.Fatalf("newReferralCode() error: %v", err)
+	}
+	if len(first) != 8 || first == second {
+		t.Errorf("newReferralCode() = '%s', '%s', want different codes of 8 symbols", first, second)
+	}
+}
@@ -7,7 +7,11 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"sync/atomic"
+	"time"
 
+	"github.com/gostuding/goMarket/internal/problem"
+	"github.com/gostuding/goMarket/internal/tracing"
 	"github.com/jackc/pgx/v5/pgconn"
 	_ "github.com/jackc/pgx/v5/stdlib"
 	"golang.org/x/crypto/bcrypt"
@@ -15,44 +19,91 @@ import (
 	"github.com/jackc/pgerrcode"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type psqlStorage struct {
-	con *gorm.DB
+	con              *gorm.DB
+	rateLimitSweep   atomic.Int64
+	idempotencySweep atomic.Int64
+	loyalty          *LoyaltyConfig
 }
 
 type BalanceStruct struct {
-	Current   float32 `json:"current"`
-	Withdrawn float32 `json:"withdrawn"`
+	ExpiringAt   *time.Time `json:"expiring_at,omitempty"`
+	Current      float32    `json:"current"`
+	Available    float32    `json:"available"`
+	Held         float32    `json:"held"`
+	Withdrawn    float32    `json:"withdrawn"`
+	ExpiringSoon float32    `json:"expiring_soon"`
 }
 
-func NewPSQLStorage(config *StorageConfig) (*psqlStorage, error) {
+// OpenDB opens database connections pool without schema checks.
+func OpenDB(config *StorageConfig) (*sql.DB, error) {
 	db, err := sql.Open("pgx", config.DBConnect)
 	if err != nil {
 		return nil, fmt.Errorf("pqx database connection error: %w", err)
 	}
 	db.SetMaxOpenConns(config.DBConnectionPull)
+	return db, nil
+}
+
+func NewPSQLStorage(config *StorageConfig, loyalty *LoyaltyConfig) (*psqlStorage, error) {
+	db, err := OpenDB(config)
+	if err != nil {
+		return nil, err
+	}
 	con, err := gorm.Open(
 		postgres.New(postgres.Config{Conn: db}),
 		&gorm.Config{})
 	if err != nil {
 		return nil, fmt.Errorf("gorm open connection error: %w", err)
 	}
+	if err = con.Use(tracing.NewGormPlugin()); err != nil {
+		return nil, fmt.Errorf("gorm tracing plugin error: %w", err)
+	}
+	if loyalty == nil {
+		loyalty = NewLoyaltyConfig()
+	}
 	storage := psqlStorage{
-		con: con,
+		con:     con,
+		loyalty: loyalty,
 	}
-	return &storage, structCheck(con)
+	return &storage, structCheck(context.Background(), db, config.AutoMigrate)
 }
 
-func (s *psqlStorage) Registration(ctx context.Context, login, pwd, ua, ip string) (int, error) {
+// Registration creates user with new referral code. User invited with referral code is saved as referee
+// of the code owner. Bonuses of registration campaigns are credited to the user.
+func (s *psqlStorage) Registration(ctx context.Context, login, pwd, ua, ip, referral string) (int, error) {
 	passwd, err := hashPassword([]byte(pwd))
 	if err != nil {
 		return 0, err
 	}
-	user := Users{Login: login, Pwd: string(passwd), UserAgent: ua, IP: ip}
-	result := s.con.WithContext(ctx).Create(&user)
-	if result.Error != nil {
-		return 0, fmt.Errorf("sql error: %w", result.Error)
+	code, err := newReferralCode()
+	if err != nil {
+		return 0, err
+	}
+	user := Users{Login: login, Pwd: string(passwd), UserAgent: ua, IP: ip, Tier: TierBronze, ReferralCode: code}
+	err = s.con.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&user).Error; err != nil {
+			return err //nolint:wrapcheck // <- wrapped below
+		}
+		if referral != "" {
+			if err := addReferral(tx, &user, referral); err != nil {
+				return err
+			}
+		}
+		bonus, err := s.applyCampaigns(tx, &user, CampaignRegister, 0, nil)
+		if err != nil || bonus == 0 {
+			return err
+		}
+		if err = tx.Model(&user).Update("balance", gorm.Expr("balance + ?", bonus)).Error; err != nil {
+			return fmt.Errorf("update user balance error: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("sql error: %w", err)
 	}
 	return int(user.ID), nil
 }
@@ -75,6 +126,9 @@ func (s *psqlStorage) Login(ctx context.Context, login, pwd, ua, ip string) (int
 	if err != nil {
 		return 0, gorm.ErrRecordNotFound
 	}
+	if user.Blocked {
+		return 0, fmt.Errorf("user '%s' is blocked: %w", login, gorm.ErrRecordNotFound)
+	}
 	user.UserAgent = ua
 	user.IP = ip
 	result = s.con.WithContext(ctx).Save(&user)
@@ -88,7 +142,7 @@ func (s *psqlStorage) AddOrder(ctx context.Context, uid int, order string) (int,
 	var item Orders
 	orderOk := errors.New("order ok")
 	orderConflict := errors.New("order conflict")
-	err := s.con.Transaction(func(tx *gorm.DB) error {
+	err := s.con.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
 		result := tx.Where("number = ? ", order).First(&item)
 		if result.Error != nil {
 			if errors.Is(result.Error, gorm.ErrRecordNotFound) {
@@ -118,6 +172,70 @@ func (s *psqlStorage) AddOrder(ctx context.Context, uid int, order string) (int,
 	}
 }
 
+// AddOrders registers user orders by batches, each batch in one transaction. Returns status of every number
+// like AddOrder does. Batches committed before an error are not rolled back, their statuses are returned
+// with the error.
+func (s *psqlStorage) AddOrders(ctx context.Context, uid int, orders []string) (map[string]int, error) {
+	statuses := make(map[string]int, len(orders))
+	for start := 0; start < len(orders); start += ordersBatchSize {
+		end := start + ordersBatchSize
+		if end > len(orders) {
+			end = len(orders)
+		}
+		var batch map[string]int
+		err := s.con.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			var err error
+			batch, err = addOrdersBatch(tx, uid, orders[start:end])
+			return err
+		})
+		if err != nil {
+			return statuses, err //nolint:wrapcheck // <-wrapped early
+		}
+		for number, status := range batch {
+			statuses[number] = status
+		}
+	}
+	return statuses, nil
+}
+
+// addOrdersBatch creates new orders of batch and returns statuses of batch numbers.
+func addOrdersBatch(tx *gorm.DB, uid int, numbers []string) (map[string]int, error) {
+	ownerStatus := func(owner int) int {
+		if owner == uid {
+			return http.StatusOK
+		}
+		return http.StatusConflict
+	}
+	var existing []Orders
+	if err := tx.Select("number", "uid").Where("number IN ?", numbers).Find(&existing).Error; err != nil {
+		return nil, fmt.Errorf("select orders error: %w", err)
+	}
+	statuses := make(map[string]int, len(numbers))
+	for _, item := range existing {
+		statuses[item.Number] = ownerStatus(item.UID)
+	}
+	for _, number := range numbers {
+		if _, ok := statuses[number]; ok {
+			continue
+		}
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&Orders{UID: uid, Number: number, Status: "NEW"})
+		if result.Error != nil {
+			return nil, fmt.Errorf("create order error: %w", result.Error)
+		}
+		if result.RowsAffected > 0 {
+			statuses[number] = http.StatusAccepted
+			continue
+		}
+		// order is created by concurrent request after select
+		var item Orders
+		if err := tx.Select("uid").Where("number = ?", number).First(&item).Error; err != nil {
+			return nil, fmt.Errorf("select order error: %w", err)
+		}
+		statuses[number] = ownerStatus(item.UID)
+	}
+	return statuses, nil
+}
+
 func (s *psqlStorage) getValues(ctx context.Context, uid int, values any) ([]byte, error) {
 	result := s.con.WithContext(ctx).Order("id desc").Where("uid = ?", uid).Find(values)
 	if result.Error != nil {
@@ -144,35 +262,88 @@ func (s *psqlStorage) GetUserBalance(ctx context.Context, uid int) ([]byte, erro
 	if result.Error != nil {
 		return nil, fmt.Errorf("get user balance error: %w", result.Error)
 	}
-	data, err := json.Marshal(BalanceStruct{Current: user.Balance, Withdrawn: user.Withdrawn})
+	soon, soonAt, err := s.expiringSoon(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(BalanceStruct{Current: user.Balance, Available: user.Balance - user.Held,
+		Held: user.Held, Withdrawn: user.Withdrawn, ExpiringSoon: soon, ExpiringAt: soonAt})
 	if err != nil {
 		return nil, fmt.Errorf("convert user balance to json error: %w", err)
 	}
 	return data, nil
 }
 
-func (s *psqlStorage) AddWithdraw(ctx context.Context, uid int, order string, sum float32) (int, error) {
+// addWithdraw debits sum from locked user balance and points lots and creates withdrawal.
+// Withdrawal is pending and can be cancelled during cancelWindow.
+func addWithdraw(tx *gorm.DB, user *Users, order string, sum float32, cancelWindow time.Duration) error {
+	user.Balance -= sum
+	user.Withdrawn += sum
+	if err := tx.Save(user).Error; err != nil {
+		return fmt.Errorf("update user balance error: %w", err)
+	}
+	item := Withdraws{Sum: sum, UID: int(user.ID), Number: order, Status: WithdrawCompleted}
+	if cancelWindow > 0 {
+		cancelUntil := time.Now().Add(cancelWindow)
+		item.Status, item.CancelUntil = WithdrawPending, &cancelUntil
+	}
+	if err := tx.Create(&item).Error; err != nil {
+		return fmt.Errorf("create withdraw error: %w", err)
+	}
+	_, err := consumeLots(tx, item.UID, sum, item.ID)
+	return err
+}
+
+// lockUser selects user for update, so balance changes are serialized.
+func lockUser(tx *gorm.DB, uid int, user *Users) error {
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", uid).First(user).Error; err != nil {
+		return fmt.Errorf("lock user error: %w", err)
+	}
+	return nil
+}
+
+// withdrawStatus converts withdraw transaction error to response status.
+func withdrawStatus(err error) (int, error) {
+	if p, ok := problem.As(err); ok {
+		return p.Status, p
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.UniqueViolation {
+		return http.StatusConflict, errors.New("withdraw order number repeat error")
+	}
+	return http.StatusInternalServerError, fmt.Errorf("transaction error: %w", err)
+}
+
+// AddWithdraw debits sum from available (not held) user balance for order. Order total is used to check
+// share of order payable by points. Withdrawal rules violations are returned as problem errors.
+func (s *psqlStorage) AddWithdraw(ctx context.Context, uid int, order string, sum, orderTotal float32,
+	cancelWindow time.Duration) (int, error) {
+	if err := s.loyalty.checkWithdrawSum(sum, orderTotal); err != nil {
+		return withdrawStatus(err)
+	}
 	var user Users
 	userNorFound := errors.New("user not found in database")
 	lowUserBalance := errors.New("low balance level")
 	err := s.con.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
-		result := tx.Where("id = ?", uid).First(&user)
-		if result.Error != nil {
+		if err := lockUser(tx, uid, &user); err != nil {
 			return fmt.Errorf("get user error: %w", userNorFound)
 		}
-		if user.Balance < sum {
+		if user.Balance-user.Held < sum {
 			return lowUserBalance
 		}
-		user.Balance -= sum
-		user.Withdrawn += sum
-		if err := tx.Save(&user).Error; err != nil {
-			return fmt.Errorf("update user balance error: %w", err)
+		if err := s.checkWithdrawRules(tx, &user, sum); err != nil {
+			return err
 		}
-		withdraw := Withdraws{Sum: sum, UID: int(user.ID), Number: order}
-		if err := tx.Create(&withdraw).Error; err != nil {
-			return fmt.Errorf("create withdraw error: %w", err)
+		var count int64
+		err := tx.Model(&Holds{}).Where("number = ? AND uid = ? AND status = ?", order, uid, HoldActive).
+			Count(&count).Error
+		if err != nil {
+			return fmt.Errorf("select hold error: %w", err)
 		}
-		return nil
+		if count > 0 {
+			return errHoldExist
+		}
+		return addWithdraw(tx, &user, order, sum, cancelWindow)
 	})
 	if err != nil {
 		if errors.Is(err, userNorFound) {
@@ -181,60 +352,186 @@ func (s *psqlStorage) AddWithdraw(ctx context.Context, uid int, order string, su
 		if errors.Is(err, lowUserBalance) {
 			return http.StatusPaymentRequired, nil
 		}
-		var pgErr *pgconn.PgError
-		if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.UniqueViolation {
-			return http.StatusConflict, errors.New("withdraw order number repeat error")
+		if errors.Is(err, errHoldExist) {
+			return http.StatusConflict, fmt.Errorf("order '%s' withdraw error: %w", order, err)
 		}
-		return http.StatusInternalServerError, fmt.Errorf("transaction error: %w", err)
+		return withdrawStatus(err)
 	}
 	return http.StatusOK, nil
 }
 
+// completeWithdraws marks pending withdrawals with expired cancel window as completed.
+func completeWithdraws(tx *gorm.DB, uid int) error {
+	err := tx.Model(&Withdraws{}).
+		Where("uid = ? AND status = ? AND cancel_until < ?", uid, WithdrawPending, time.Now()).
+		Updates(map[string]any{"status": WithdrawCompleted, "cancel_until": nil}).Error
+	if err != nil {
+		return fmt.Errorf("complete withdraws error: %w", err)
+	}
+	return nil
+}
+
 func (s *psqlStorage) GetWithdraws(ctx context.Context, uid int) ([]byte, error) {
 	var withdraws []Withdraws
+	if err := completeWithdraws(s.con.WithContext(ctx), uid); err != nil {
+		return nil, err
+	}
 	return s.getValues(ctx, uid, &withdraws)
 }
 
-func (s *psqlStorage) GetAccrualOrders() []string {
-	var orders []Orders
-	result := s.con.Order("id").Where("status NOT IN ?", []string{"INVALID", "PROCESSED"}).Find(&orders)
-	if result.Error != nil || result.RowsAffected == 0 {
+// revertWithdraw changes withdrawal status and returns its sum to user balance.
+// uid is checked if it is not zero, allowed checks if withdrawal can be reverted.
+func (s *psqlStorage) revertWithdraw(ctx context.Context, uid int, order, status string,
+	allowed func(*Withdraws) bool) (int, error) {
+	var withdraw Withdraws
+	notFound := errors.New("withdraw not found")
+	notAllowed := errors.New("withdraw can not be reverted")
+	err := s.con.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("number = ?", order).Limit(1).Find(&withdraw)
+		if result.Error != nil {
+			return fmt.Errorf("select withdraw error: %w", result.Error)
+		}
+		if result.RowsAffected == 0 || (uid != 0 && withdraw.UID != uid) {
+			return notFound
+		}
+		if !allowed(&withdraw) {
+			return notAllowed
+		}
+		err := tx.Model(&withdraw).Updates(map[string]any{"status": status, "cancel_until": nil}).Error
+		if err != nil {
+			return fmt.Errorf("update withdraw status error: %w", err)
+		}
+		if err = s.restoreLots(tx, &withdraw); err != nil {
+			return err
+		}
+		err = tx.Model(&Users{}).Where("id = ?", withdraw.UID).Updates(map[string]any{
+			"balance":   gorm.Expr("balance + ?", withdraw.Sum),
+			"withdrawn": gorm.Expr("withdrawn - ?", withdraw.Sum),
+		}).Error
+		if err != nil {
+			return fmt.Errorf("update user balance error: %w", err)
+		}
 		return nil
+	})
+	switch {
+	case err == nil:
+		return http.StatusOK, nil
+	case errors.Is(err, notFound):
+		return http.StatusNotFound, fmt.Errorf("order '%s': %w", order, err)
+	case errors.Is(err, notAllowed):
+		return http.StatusConflict, fmt.Errorf("order '%s' status '%s': %w", order, withdraw.Status, err)
+	default:
+		return http.StatusInternalServerError, fmt.Errorf("transaction error: %w", err)
 	}
-	numbers := make([]string, 0)
+}
+
+// CancelWithdraw cancels user's pending withdrawal if cancel window is not expired.
+func (s *psqlStorage) CancelWithdraw(ctx context.Context, uid int, order string) (int, error) {
+	return s.revertWithdraw(ctx, uid, order, WithdrawCancelled, func(w *Withdraws) bool {
+		return w.Status == WithdrawPending && w.CancelUntil != nil && time.Now().Before(*w.CancelUntil)
+	})
+}
+
+// RefundWithdraw returns points of pending or completed withdrawal to user balance.
+func (s *psqlStorage) RefundWithdraw(ctx context.Context, order string) (int, error) {
+	return s.revertWithdraw(ctx, 0, order, WithdrawRefunded, func(w *Withdraws) bool {
+		return w.Status == WithdrawPending || w.Status == WithdrawCompleted
+	})
+}
+
+// GetAccrualOrders returns numbers of orders waiting for accrual.
+func (s *psqlStorage) GetAccrualOrders(ctx context.Context) ([]string, error) {
+	var orders []Orders
+	result := s.con.WithContext(ctx).Order("id").Where("status NOT IN ?", []string{"INVALID", "PROCESSED"}).Find(&orders)
+	if result.Error != nil {
+		return nil, fmt.Errorf("get accrual orders error: %w", result.Error)
+	}
+	numbers := make([]string, 0, len(orders))
 	for _, item := range orders {
 		numbers = append(numbers, item.Number)
 	}
-	return numbers
+	return numbers, nil
 }
 
-func (s *psqlStorage) SetOrderData(number string, status string, balance float32) error {
+// SetOrderData saves order status and accrual from accrual system and changes user balance.
+// Returns sum of points credited by this update: accrual, tier, campaigns and referral bonuses.
+func (s *psqlStorage) SetOrderData(ctx context.Context, number string, status string, balance float32) (float32,
+	error) {
 	var order Orders
 	var user Users
-	err := s.con.Transaction(func(tx *gorm.DB) error {
+	credited := float32(0)
+	err := s.con.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
 		result := tx.Where("number = ?", number).First(&order)
 		if result.Error != nil {
 			return fmt.Errorf("update order status, get order (%s) error: %w", number, result.Error)
 		}
-		result = tx.Where("id = ?", order.UID).First(&user)
-		if result.Error != nil {
-			return fmt.Errorf("update order status, get user (%d) error: %w", order.UID, result.Error)
+		lock := lockUser
+		if status == orderProcessed && order.Status != orderProcessed {
+			lock = lockReferee
+		}
+		if err := lock(tx, order.UID, &user); err != nil {
+			return fmt.Errorf("update order status, get user (%d) error: %w", order.UID, err)
+		}
+		credit := balance - order.Accrual
+		// Tier multiplier of the user's tier before this credit is applied on top of accrual.
+		// If accrual decreases, tier bonus of removed points is taken back.
+		bonus := s.loyalty.tierBonus(user.Tier, credit, order.TierBonus)
+		if credit > 0 {
+			expiresAt := s.expiresAt()
+			if err := creditLot(tx, order.UID, credit, LotAccrual, &number, expiresAt); err != nil {
+				return err
+			}
+			if err := creditLot(tx, order.UID, bonus, LotTier, &number, expiresAt); err != nil {
+				return err
+			}
+		} else if credit < 0 {
+			if _, err := consumeLots(tx, order.UID, -(credit + bonus), 0); err != nil {
+				return err
+			}
+		}
+		user.Balance += credit + bonus
+		if credit > 0 {
+			credited += credit + bonus
+		}
+		if err := releaseExcessHolds(tx, &user); err != nil {
+			return err
+		}
+		if status == orderProcessed && order.Status != orderProcessed {
+			// Campaigns bonus is calculated from final order accrual once per order.
+			if balance > 0 {
+				extra, err := s.applyCampaigns(tx, &user, CampaignOrder, balance, &number)
+				if err != nil {
+					return err
+				}
+				user.Balance += extra
+				credited += extra
+			}
+			reward, err := s.rewardReferral(tx, &user, number)
+			if err != nil {
+				return err
+			}
+			credited += reward
 		}
-		user.Balance += balance
 		order.Status = status
 		order.Accrual = balance
-		if err := tx.Save(&user).Error; err != nil {
-			return fmt.Errorf("user balance update error: %w", err)
-		}
+		order.TierBonus += bonus
 		if err := tx.Save(&order).Error; err != nil {
 			return fmt.Errorf("update order status and accural error: %w", err)
 		}
+		if credit != 0 {
+			if err := s.updateTier(tx, &user); err != nil {
+				return err
+			}
+		}
+		if err := tx.Save(&user).Error; err != nil {
+			return fmt.Errorf("user balance update error: %w", err)
+		}
 		return nil
 	})
 	if err != nil {
-		return fmt.Errorf("update order status transaction error: %w", err)
+		return 0, fmt.Errorf("update order status transaction error: %w", err)
 	}
-	return nil
+	return credited, nil
 }
 
 func (s *psqlStorage) Close() error {
@@ -249,6 +546,35 @@ func (s *psqlStorage) Close() error {
 	return nil
 }
 
+func (s *psqlStorage) Ping(ctx context.Context) error {
+	db, err := s.DB()
+	if err != nil {
+		return err
+	}
+	if err = db.PingContext(ctx); err != nil {
+		return fmt.Errorf("database ping error: %w", err)
+	}
+	return nil
+}
+
+// IsMigrated checks that all migrations are applied.
+func (s *psqlStorage) IsMigrated(ctx context.Context) (bool, error) {
+	db, err := s.DB()
+	if err != nil {
+		return false, err
+	}
+	return IsSchemaActual(ctx, db)
+}
+
+// DB returns database connections pool of the storage.
+func (s *psqlStorage) DB() (*sql.DB, error) {
+	db, err := s.con.DB()
+	if err != nil {
+		return nil, fmt.Errorf("get db from gorm error: %w", err)
+	}
+	return db, nil
+}
+
 func (s *psqlStorage) IsUniqueViolation(err error) bool {
 	var pgErr *pgconn.PgError
 	if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.UniqueViolation {
@@ -0,0 +1,171 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// User tiers in ascending order.
+const (
+	TierBronze = "bronze"
+	TierSilver = "silver"
+	TierGold   = "gold"
+)
+
+// Tier progress bases: points earned by orders or points spent by withdrawals for the tier period.
+const (
+	TierBasisEarned = "earned"
+	TierBasisSpend  = "spend"
+)
+
+var tiers = []string{TierBronze, TierSilver, TierGold}
+
+// TierStruct is user tier with progress to the next one.
+type TierStruct struct {
+	Tier          string  `json:"tier"`
+	Basis         string  `json:"basis"`
+	Next          string  `json:"next,omitempty"`
+	Progress      float32 `json:"progress"`
+	NextThreshold float32 `json:"next_threshold,omitempty"`
+	Remaining     float32 `json:"remaining,omitempty"`
+	Multiplier    float32 `json:"multiplier"`
+	PeriodDays    int     `json:"period_days"`
+}
+
+func (cfg *LoyaltyConfig) tierThreshold(tier string) float64 {
+	switch tier {
+	case TierSilver:
+		return cfg.TierSilverThreshold
+	case TierGold:
+		return cfg.TierGoldThreshold
+	default:
+		return 0
+	}
+}
+
+func (cfg *LoyaltyConfig) tierMultiplier(tier string) float64 {
+	switch tier {
+	case TierSilver:
+		return cfg.TierSilverMultiplier
+	case TierGold:
+		return cfg.TierGoldMultiplier
+	default:
+		return cfg.TierBronzeMultiplier
+	}
+}
+
+// tierBonus returns tier bonus change for accrual change of order with paid tier bonus.
+// Bonus of accrual decrease is taken back in proportion, but not more than paid bonus.
+func (cfg *LoyaltyConfig) tierBonus(tier string, credit, paid float32) float32 {
+	bonus := credit * float32(cfg.tierMultiplier(tier)-1)
+	if bonus < -paid {
+		return -paid
+	}
+	return bonus
+}
+
+// tierFor returns the highest tier which threshold is reached by progress.
+func (cfg *LoyaltyConfig) tierFor(progress float64) string {
+	result := TierBronze
+	for _, tier := range tiers {
+		if progress >= cfg.tierThreshold(tier) {
+			result = tier
+		}
+	}
+	return result
+}
+
+// nextTier returns tier following the tier or empty string for the highest one.
+func nextTier(tier string) string {
+	for i := range tiers[:len(tiers)-1] {
+		if tiers[i] == tier {
+			return tiers[i+1]
+		}
+	}
+	return ""
+}
+
+// tierProgressQuery selects progress value of users for the tier period as uid and value.
+func (cfg *LoyaltyConfig) tierProgressQuery() string {
+	if cfg.TierBasis == TierBasisSpend {
+		return `SELECT uid, sum(sum) AS value FROM withdraws
+			WHERE status IN ('` + WithdrawPending + `', '` + WithdrawCompleted + `') AND created_at > @since
+			GROUP BY uid`
+	}
+	return `SELECT uid, sum(accrual) AS value FROM orders
+		WHERE status = '` + orderProcessed + `' AND updated_at > @since GROUP BY uid`
+}
+
+func (cfg *LoyaltyConfig) tierSince() time.Time {
+	return time.Now().AddDate(0, 0, -cfg.TierPeriodDays)
+}
+
+// tierProgress returns user progress value for the tier period.
+func (s *psqlStorage) tierProgress(tx *gorm.DB, uid int) (float64, error) {
+	var progress float64
+	err := tx.Raw("SELECT coalesce(sum(value), 0) FROM ("+s.loyalty.tierProgressQuery()+") p WHERE uid = @uid",
+		map[string]any{"since": s.loyalty.tierSince(), "uid": uid}).Scan(&progress).Error
+	if err != nil {
+		return 0, fmt.Errorf("select tier progress error: %w", err)
+	}
+	return progress, nil
+}
+
+// updateTier sets tier of user by the user's current progress. User is not saved.
+func (s *psqlStorage) updateTier(tx *gorm.DB, user *Users) error {
+	progress, err := s.tierProgress(tx, int(user.ID))
+	if err != nil {
+		return err
+	}
+	user.Tier = s.loyalty.tierFor(progress)
+	return nil
+}
+
+// RecalculateTiers updates tiers of all users by their progress for the tier period.
+// Returns count of users with changed tier.
+func (s *psqlStorage) RecalculateTiers(ctx context.Context) (int64, error) {
+	result := s.con.WithContext(ctx).Exec(`
+		UPDATE users SET tier = t.tier, updated_at = @now FROM (
+			SELECT u.id, CASE
+				WHEN coalesce(p.value, 0) >= @gold THEN '`+TierGold+`'
+				WHEN coalesce(p.value, 0) >= @silver THEN '`+TierSilver+`'
+				ELSE '`+TierBronze+`' END AS tier
+			FROM users u LEFT JOIN (`+s.loyalty.tierProgressQuery()+`) p ON p.uid = u.id
+		) t WHERE users.id = t.id AND users.tier <> t.tier`,
+		map[string]any{"since": s.loyalty.tierSince(), "now": time.Now(),
+			"silver": s.loyalty.TierSilverThreshold, "gold": s.loyalty.TierGoldThreshold})
+	if result.Error != nil {
+		return 0, fmt.Errorf("recalculate tiers error: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+// GetUserTier returns user tier and progress to the next tier.
+func (s *psqlStorage) GetUserTier(ctx context.Context, uid int) ([]byte, error) {
+	var user Users
+	if err := s.con.WithContext(ctx).Where("id = ?", uid).First(&user).Error; err != nil {
+		return nil, fmt.Errorf("get user tier error: %w", err)
+	}
+	progress, err := s.tierProgress(s.con.WithContext(ctx), uid)
+	if err != nil {
+		return nil, err
+	}
+	tier := TierStruct{Tier: user.Tier, Basis: s.loyalty.TierBasis, Progress: float32(progress),
+		Multiplier: float32(s.loyalty.tierMultiplier(user.Tier)), PeriodDays: s.loyalty.TierPeriodDays}
+	if tier.Next = nextTier(user.Tier); tier.Next != "" {
+		threshold := s.loyalty.tierThreshold(tier.Next)
+		tier.NextThreshold = float32(threshold)
+		if progress < threshold {
+			tier.Remaining = float32(threshold - progress)
+		}
+	}
+	data, err := json.Marshal(tier)
+	if err != nil {
+		return nil, fmt.Errorf("convert user tier to json error: %w", err)
+	}
+	return data, nil
+}
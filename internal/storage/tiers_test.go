@@ -0,0 +1,82 @@
+package storage
+
+import "testing"
+
+func TestTierFor(t *testing.T) {
+	cfg := NewLoyaltyConfig()
+	tests := []struct {
+		name     string
+		want     string
+		next     string
+		progress float64
+	}{
+		{name: "Без прогресса", progress: 0, want: TierBronze, next: TierSilver},
+		{name: "Ниже порога silver", progress: 999, want: TierBronze, next: TierSilver},
+		{name: "Порог silver", progress: 1000, want: TierSilver, next: TierGold},
+		{name: "Порог gold", progress: 5000, want: TierGold},
+		{name: "Выше порога gold", progress: 10000, want: TierGold},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := cfg.tierFor(tt.progress)
+			if got != tt.want {
+				t.Errorf("tierFor() = %s, want %s", got, tt.want)
+			}
+			if next := nextTier(got); next != tt.next {
+				t.Errorf("nextTier() = '%s', want '%s'", next, tt.next)
+			}
+		})
+	}
+}
+
+func TestTierBonus(t *testing.T) {
+	cfg := NewLoyaltyConfig()
+	cfg.TierSilverMultiplier = 1.5
+	tests := []struct {
+		name   string
+		tier   string
+		credit float32
+		paid   float32
+		want   float32
+	}{
+		{name: "Начисление bronze", tier: TierBronze, credit: 100},
+		{name: "Начисление silver", tier: TierSilver, credit: 100, want: 50},
+		{name: "Уменьшение начисления", tier: TierSilver, credit: -40, paid: 50, want: -20},
+		{name: "Уменьшение больше выплаченной надбавки", tier: TierSilver, credit: -100, paid: 30, want: -30},
+		{name: "Уменьшение без надбавки", tier: TierSilver, credit: -40},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cfg.tierBonus(tt.tier, tt.credit, tt.paid); got != tt.want {
+				t.Errorf("tierBonus() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoyaltyConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		update  func(*LoyaltyConfig)
+		wantErr bool
+	}{
+		{name: "Значения по умолчанию", update: func(cfg *LoyaltyConfig) {}},
+		{name: "Неизвестный показатель уровня", update: func(cfg *LoyaltyConfig) { cfg.TierBasis = "orders" },
+			wantErr: true},
+		{name: "Порог gold ниже silver", update: func(cfg *LoyaltyConfig) { cfg.TierGoldThreshold = 500 },
+			wantErr: true},
+		{name: "Множитель меньше единицы", update: func(cfg *LoyaltyConfig) { cfg.TierSilverMultiplier = 0.5 },
+			wantErr: true},
+		{name: "Отрицательный срок действия баллов", update: func(cfg *LoyaltyConfig) { cfg.PointsExpiryMonths = -1 },
+			wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := NewLoyaltyConfig()
+			tt.update(cfg)
+			if err := cfg.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
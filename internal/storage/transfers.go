@@ -0,0 +1,167 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const transferLimitPeriod = 24 * time.Hour
+
+var (
+	errRecipientNotFound = errors.New("recipient not found")
+	errSelfTransfer      = errors.New("transfer to yourself")
+	errTransferNotFound  = errors.New("pending transfer not found")
+	errTransferExpired   = errors.New("transfer confirmation expired")
+	errTransferLimit     = errors.New("daily transfers limit exceeded")
+)
+
+// AddTransfer creates pending transfer of sum to user with login. Transfer must be confirmed before ttl expires.
+func (s *psqlStorage) AddTransfer(ctx context.Context, uid int, login string, sum float32,
+	ttl time.Duration) ([]byte, int, error) {
+	var sender, recipient Users
+	transfer := Transfers{SenderUID: uid, Login: login, Sum: sum, Status: TransferPending,
+		ExpiresAt: time.Now().Add(ttl)}
+	err := s.con.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("login = ?", login).Limit(1).Find(&recipient)
+		if result.Error != nil {
+			return fmt.Errorf("select recipient error: %w", result.Error)
+		}
+		if result.RowsAffected == 0 || recipient.Blocked {
+			return errRecipientNotFound
+		}
+		if int(recipient.ID) == uid {
+			return errSelfTransfer
+		}
+		if err := tx.Where("id = ?", uid).First(&sender).Error; err != nil {
+			return fmt.Errorf("select sender error: %w", err)
+		}
+		if sender.Balance-sender.Held < sum {
+			return errLowAvailable
+		}
+		transfer.RecipientUID = int(recipient.ID)
+		if err := tx.Create(&transfer).Error; err != nil {
+			return fmt.Errorf("create transfer error: %w", err)
+		}
+		return nil
+	})
+	switch {
+	case err == nil:
+	case errors.Is(err, errRecipientNotFound):
+		return nil, http.StatusNotFound, fmt.Errorf("login '%s': %w", login, err)
+	case errors.Is(err, errSelfTransfer):
+		return nil, http.StatusBadRequest, err
+	case errors.Is(err, errLowAvailable):
+		return nil, http.StatusPaymentRequired, nil
+	default:
+		return nil, http.StatusInternalServerError, fmt.Errorf("transfer transaction error: %w", err)
+	}
+	data, err := json.Marshal(transfer)
+	if err != nil {
+		return nil, http.StatusInternalServerError, fmt.Errorf("convert transfer to json error: %w", err)
+	}
+	return data, http.StatusCreated, nil
+}
+
+// checkTransferLimits checks sum and count of sender's transfers for the last day.
+// Zero limit values mean no limit.
+func checkTransferLimits(tx *gorm.DB, uid int, sum, limitSum float32, limitCount int) error {
+	if limitSum <= 0 && limitCount <= 0 {
+		return nil
+	}
+	var total struct {
+		Sum   float32
+		Count int
+	}
+	err := tx.Model(&Transfers{}).Select("coalesce(sum(sum), 0) AS sum, count(*) AS count").
+		Where("sender_uid = ? AND status = ? AND confirmed_at > ?", uid, TransferCompleted,
+			time.Now().Add(-transferLimitPeriod)).Scan(&total).Error
+	if err != nil {
+		return fmt.Errorf("select transfers total error: %w", err)
+	}
+	if (limitSum > 0 && total.Sum+sum > limitSum) || (limitCount > 0 && total.Count+1 > limitCount) {
+		return errTransferLimit
+	}
+	return nil
+}
+
+// ConfirmTransfer executes pending transfer of user. Sender and recipient rows are locked in id order,
+// so concurrent transfers between the same users do not deadlock.
+func (s *psqlStorage) ConfirmTransfer(ctx context.Context, uid, id int, limitSum float32,
+	limitCount int) (int, error) {
+	expired := false
+	err := s.con.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var transfer Transfers
+		result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND sender_uid = ? AND status = ?", id, uid, TransferPending).Limit(1).Find(&transfer)
+		if result.Error != nil {
+			return fmt.Errorf("select transfer error: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return errTransferNotFound
+		}
+		now := time.Now()
+		if transfer.ExpiresAt.Before(now) {
+			expired = true
+			return tx.Model(&transfer).Update("status", TransferExpired).Error //nolint:wrapcheck // <- checked below
+		}
+		var users []Users
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id IN ?", []int{transfer.SenderUID, transfer.RecipientUID}).Order("id").Find(&users).Error
+		if err != nil {
+			return fmt.Errorf("lock users error: %w", err)
+		}
+		for _, user := range users {
+			if int(user.ID) == uid && user.Balance-user.Held < transfer.Sum {
+				return errLowAvailable
+			}
+		}
+		if err = checkTransferLimits(tx, uid, transfer.Sum, limitSum, limitCount); err != nil {
+			return err
+		}
+		err = tx.Model(&Users{}).Where("id = ?", transfer.SenderUID).
+			Update("balance", gorm.Expr("balance - ?", transfer.Sum)).Error
+		if err != nil {
+			return fmt.Errorf("update sender balance error: %w", err)
+		}
+		err = tx.Model(&Users{}).Where("id = ?", transfer.RecipientUID).
+			Update("balance", gorm.Expr("balance + ?", transfer.Sum)).Error
+		if err != nil {
+			return fmt.Errorf("update recipient balance error: %w", err)
+		}
+		// Transferred points keep the earliest expiry time of sender lots.
+		expiresAt, err := consumeLots(tx, transfer.SenderUID, transfer.Sum, 0)
+		if err != nil {
+			return err
+		}
+		err = creditLot(tx, transfer.RecipientUID, transfer.Sum, LotTransfer, nil, expiresAt)
+		if err != nil {
+			return err
+		}
+		err = tx.Model(&transfer).Updates(map[string]any{"status": TransferCompleted, "confirmed_at": now}).Error
+		if err != nil {
+			return fmt.Errorf("update transfer status error: %w", err)
+		}
+		return nil
+	})
+	switch {
+	case err == nil && expired:
+		return http.StatusConflict, fmt.Errorf("transfer %d: %w", id, errTransferExpired)
+	case err == nil:
+		return http.StatusOK, nil
+	case errors.Is(err, errTransferNotFound):
+		return http.StatusNotFound, fmt.Errorf("transfer %d: %w", id, err)
+	case errors.Is(err, errLowAvailable):
+		return http.StatusPaymentRequired, nil
+	case errors.Is(err, errTransferLimit):
+		return http.StatusForbidden, fmt.Errorf("transfer %d: %w", id, err)
+	default:
+		return http.StatusInternalServerError, fmt.Errorf("transfer transaction error: %w", err)
+	}
+}
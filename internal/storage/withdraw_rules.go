@@ -0,0 +1,122 @@
+package storage
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gostuding/goMarket/internal/problem"
+	"gorm.io/gorm"
+)
+
+// Withdrawal rules violations.
+var (
+	ProblemOrderTotalRequired = problem.New(http.StatusBadRequest, "withdraw-order-total-required",
+		"order total is required to check share payable by points")
+	ProblemWithdrawBelowMin = problem.New(http.StatusUnprocessableEntity, "withdraw-below-min",
+		"withdrawal sum is less than minimum")
+	ProblemWithdrawAboveMax = problem.New(http.StatusUnprocessableEntity, "withdraw-above-max",
+		"withdrawal sum is more than maximum")
+	ProblemOrderShare = problem.New(http.StatusUnprocessableEntity, "withdraw-order-share",
+		"withdrawal sum exceeds order share payable by points")
+	ProblemDailyLimit = problem.New(http.StatusForbidden, "withdraw-daily-limit",
+		"daily withdrawals limit exceeded")
+	ProblemMonthlyLimit = problem.New(http.StatusForbidden, "withdraw-monthly-limit",
+		"monthly withdrawals limit exceeded")
+	ProblemCoolingOff = problem.New(http.StatusPaymentRequired, "withdraw-cooling-off",
+		"freshly accrued points are not available yet")
+)
+
+// checkWithdrawSum checks withdrawal sum limits which do not depend on user data.
+func (cfg *LoyaltyConfig) checkWithdrawSum(sum, orderTotal float32) error {
+	share := float32(cfg.WithdrawMaxOrderShare)
+	switch {
+	case share < 1 && orderTotal <= 0:
+		return ProblemOrderTotalRequired
+	case sum < float32(cfg.WithdrawMinSum):
+		return ProblemWithdrawBelowMin.WithDetail("minimum withdrawal sum is %v", cfg.WithdrawMinSum)
+	case cfg.WithdrawMaxSum > 0 && sum > float32(cfg.WithdrawMaxSum):
+		return ProblemWithdrawAboveMax.WithDetail("maximum withdrawal sum is %v", cfg.WithdrawMaxSum)
+	case share < 1 && sum > orderTotal*share:
+		return ProblemOrderShare.WithDetail("maximum sum payable by points is %v", orderTotal*share)
+	default:
+		return nil
+	}
+}
+
+// checkWithdrawPeriods checks daily and monthly limits of user withdrawals and active holds.
+func (cfg *LoyaltyConfig) checkWithdrawPeriods(tx *gorm.DB, uid int, sum float32) error {
+	if cfg.WithdrawDailyLimit <= 0 && cfg.WithdrawMonthlyLimit <= 0 {
+		return nil
+	}
+	now := time.Now()
+	day, since := now.AddDate(0, 0, -1), now.AddDate(0, 0, -1)
+	if cfg.WithdrawMonthlyLimit > 0 {
+		since = now.AddDate(0, -1, 0)
+	}
+	var spent struct {
+		Day   float32
+		Month float32
+	}
+	err := tx.Raw(`
+		SELECT coalesce(sum(sum) FILTER (WHERE created_at > @day), 0) AS day, coalesce(sum(sum), 0) AS month
+		FROM (
+			SELECT created_at, sum FROM withdraws
+			WHERE uid = @uid AND status IN (@withdrawn) AND created_at > @since
+			UNION ALL
+			SELECT created_at, sum FROM holds WHERE uid = @uid AND status = @held AND created_at > @since
+		) spent`, map[string]any{"uid": uid, "day": day, "since": since, "held": HoldActive,
+		"withdrawn": []string{WithdrawPending, WithdrawCompleted}}).Scan(&spent).Error
+	if err != nil {
+		return fmt.Errorf("select withdrawals total error: %w", err)
+	}
+	if cfg.WithdrawDailyLimit > 0 && spent.Day+sum > float32(cfg.WithdrawDailyLimit) {
+		return ProblemDailyLimit.WithDetail("withdrawn for the last day %v of %v", spent.Day, cfg.WithdrawDailyLimit)
+	}
+	if cfg.WithdrawMonthlyLimit > 0 && spent.Month+sum > float32(cfg.WithdrawMonthlyLimit) {
+		return ProblemMonthlyLimit.WithDetail("withdrawn for the last month %v of %v", spent.Month,
+			cfg.WithdrawMonthlyLimit)
+	}
+	return nil
+}
+
+// freshPoints returns remaining points of lots credited in the cooling-off period.
+// Refunded points are returned to lots they were taken from and legacy lots are created by migration
+// from old balance, so they are not fresh.
+func freshPoints(lots []PointLots) float32 {
+	fresh := float32(0)
+	for _, lot := range lots {
+		if lot.Source != LotRefund && lot.Source != LotLegacy {
+			fresh += lot.Remaining
+		}
+	}
+	return fresh
+}
+
+// checkCoolingOff checks that sum can be paid by points credited before the cooling-off period.
+func (cfg *LoyaltyConfig) checkCoolingOff(tx *gorm.DB, user *Users, sum float32) error {
+	if cfg.PointsCoolingOffHours <= 0 {
+		return nil
+	}
+	var lots []PointLots
+	err := tx.Select("source", "remaining").
+		Where("uid = ? AND remaining > 0 AND created_at > ?", user.ID,
+			time.Now().Add(-time.Duration(cfg.PointsCoolingOffHours)*time.Hour)).Find(&lots).Error
+	if err != nil {
+		return fmt.Errorf("select fresh points error: %w", err)
+	}
+	fresh := freshPoints(lots)
+	if mature := user.Balance - user.Held - fresh; mature < sum {
+		return ProblemCoolingOff.WithDetail("available now %v, fresh points are available after %d hours",
+			mature, cfg.PointsCoolingOffHours)
+	}
+	return nil
+}
+
+// checkWithdrawRules checks withdrawal of sum from locked user with available balance enough for it.
+func (s *psqlStorage) checkWithdrawRules(tx *gorm.DB, user *Users, sum float32) error {
+	if err := s.loyalty.checkCoolingOff(tx, user, sum); err != nil {
+		return err
+	}
+	return s.loyalty.checkWithdrawPeriods(tx, int(user.ID), sum)
+}
@@ -0,0 +1,59 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/gostuding/goMarket/internal/problem"
+)
+
+func TestCheckWithdrawSum(t *testing.T) {
+	cfg := NewLoyaltyConfig()
+	cfg.WithdrawMinSum = 10
+	cfg.WithdrawMaxSum = 1000
+	cfg.WithdrawMaxOrderShare = 0.5
+	tests := []struct {
+		want       *problem.Problem
+		name       string
+		sum        float32
+		orderTotal float32
+	}{
+		{name: "Допустимое списание", sum: 50, orderTotal: 100},
+		{name: "Без суммы заказа", sum: 50, want: ProblemOrderTotalRequired},
+		{name: "Меньше минимума", sum: 5, orderTotal: 100, want: ProblemWithdrawBelowMin},
+		{name: "Больше максимума", sum: 1500, orderTotal: 5000, want: ProblemWithdrawAboveMax},
+		{name: "Больше доли заказа", sum: 60, orderTotal: 100, want: ProblemOrderShare},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := cfg.checkWithdrawSum(tt.sum, tt.orderTotal)
+			if tt.want == nil && err != nil {
+				t.Errorf("checkWithdrawSum() error = %v, want nil", err)
+			}
+			if p, ok := problem.As(err); tt.want != nil && (!ok || p.Code != tt.want.Code) {
+				t.Errorf("checkWithdrawSum() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestFreshPoints(t *testing.T) {
+	tests := []struct {
+		name string
+		lots []PointLots
+		want float32
+	}{
+		{name: "Начисления", lots: []PointLots{{Source: LotAccrual, Remaining: 10}, {Source: LotTier, Remaining: 5}},
+			want: 15},
+		{name: "Возврат списания", lots: []PointLots{{Source: LotRefund, Remaining: 10}}},
+		{name: "Баланс до миграции", lots: []PointLots{{Source: LotLegacy, Remaining: 100},
+			{Source: LotTransfer, Remaining: 20}}, want: 20},
+		{name: "Нет партий"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := freshPoints(tt.lots); got != tt.want {
+				t.Errorf("freshPoints() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+const (
+	gormSpanPrefix = "gorm."
+	gormSpanKey    = "gophermart:span"
+	gormParentKey  = "gophermart:parent_context"
+)
+
+type gormPlugin struct{}
+
+// NewGormPlugin returns gorm plugin that creates span for every query.
+func NewGormPlugin() gorm.Plugin {
+	return gormPlugin{}
+}
+
+func (gormPlugin) Name() string {
+	return "gophermart:tracing"
+}
+
+func (p gormPlugin) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	processors := []struct {
+		name   string
+		before func(string, func(*gorm.DB)) error
+		after  func(string, func(*gorm.DB)) error
+	}{
+		{"create", cb.Create().Before("gorm:create").Register, cb.Create().After("gorm:create").Register},
+		{"query", cb.Query().Before("gorm:query").Register, cb.Query().After("gorm:query").Register},
+		{"update", cb.Update().Before("gorm:update").Register, cb.Update().After("gorm:update").Register},
+		{"delete", cb.Delete().Before("gorm:delete").Register, cb.Delete().After("gorm:delete").Register},
+		{"row", cb.Row().Before("gorm:row").Register, cb.Row().After("gorm:row").Register},
+		{"raw", cb.Raw().Before("gorm:raw").Register, cb.Raw().After("gorm:raw").Register},
+	}
+	for _, item := range processors {
+		if err := item.before(p.Name()+":before_"+item.name, p.before(item.name)); err != nil {
+			return fmt.Errorf("register gorm tracing callback error: %w", err)
+		}
+		if err := item.after(p.Name()+":after_"+item.name, p.after); err != nil {
+			return fmt.Errorf("register gorm tracing callback error: %w", err)
+		}
+	}
+	return nil
+}
+
+func (gormPlugin) before(operation string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		if db.Statement == nil || db.Statement.Context == nil {
+			return
+		}
+		ctx, span := Tracer().Start(db.Statement.Context, gormSpanPrefix+operation,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(semconv.DBSystemPostgreSQL))
+		db.InstanceSet(gormParentKey, db.Statement.Context)
+		db.InstanceSet(gormSpanKey, span)
+		db.Statement.Context = ctx
+	}
+}
+
+func (gormPlugin) after(db *gorm.DB) {
+	if db.Statement == nil {
+		return
+	}
+	value, ok := db.InstanceGet(gormSpanKey)
+	if !ok {
+		return
+	}
+	span, ok := value.(trace.Span)
+	if !ok {
+		return
+	}
+	defer span.End()
+	if parent, ok := db.InstanceGet(gormParentKey); ok {
+		if ctx, ok := parent.(context.Context); ok {
+			db.Statement.Context = ctx
+		}
+	}
+	span.SetAttributes(
+		semconv.DBStatementKey.String(db.Statement.SQL.String()),
+		semconv.DBSQLTableKey.String(db.Statement.Table),
+		attribute.Int64("db.rows_affected", db.RowsAffected),
+	)
+	if db.Error != nil && !errors.Is(db.Error, gorm.ErrRecordNotFound) {
+		span.RecordError(db.Error)
+		span.SetStatus(codes.Error, db.Error.Error())
+	}
+}
@@ -0,0 +1,144 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	ExporterNone   = "none"
+	ExporterOTLP   = "otlp"
+	ExporterStdout = "stdout"
+	ExporterFile   = "file"
+
+	tracerName         = "github.com/gostuding/goMarket"
+	defaultServiceName = "gophermart"
+	defaultFilePath    = "traces.json"
+)
+
+// OrderNumberKey links spans of one order lifecycle: upload, accrual requests and storage updates.
+const OrderNumberKey = attribute.Key("order.number")
+
+type TracingConfig struct {
+	Exporter    string  `json:"exporter"`
+	Endpoint    string  `json:"endpoint"`
+	FilePath    string  `json:"file"`
+	ServiceName string  `json:"service_name"`
+	SampleRatio float64 `json:"sample_ratio"`
+	Insecure    bool    `json:"insecure"`
+}
+
+func NewTracingConfig() *TracingConfig {
+	return &TracingConfig{
+		Exporter:    ExporterNone,
+		FilePath:    defaultFilePath,
+		ServiceName: defaultServiceName,
+		SampleRatio: 1,
+	}
+}
+
+// Validate checks tracing options values.
+func (cfg *TracingConfig) Validate() error {
+	errs := make([]error, 0)
+	switch cfg.Exporter {
+	case ExporterNone, ExporterOTLP, ExporterStdout, ExporterFile:
+	default:
+		errs = append(errs, fmt.Errorf("unknown trace exporter: '%s'", cfg.Exporter))
+	}
+	if cfg.Exporter == ExporterFile && cfg.FilePath == "" {
+		errs = append(errs, errors.New("trace file is empty"))
+	}
+	if cfg.SampleRatio < 0 || cfg.SampleRatio > 1 {
+		errs = append(errs, fmt.Errorf("trace sample ratio must be in [0, 1], got %v", cfg.SampleRatio))
+	}
+	return errors.Join(errs...)
+}
+
+func newExporter(ctx context.Context, cfg *TracingConfig) (sdktrace.SpanExporter, io.Closer, error) {
+	switch cfg.Exporter {
+	case ExporterOTLP:
+		opts := make([]otlptracehttp.Option, 0)
+		if cfg.Endpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpoint(cfg.Endpoint))
+		}
+		if cfg.Insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		exp, err := otlptracehttp.New(ctx, opts...)
+		if err != nil {
+			return nil, nil, fmt.Errorf("create otlp exporter error: %w", err)
+		}
+		return exp, nil, nil
+	case ExporterStdout:
+		exp, err := stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+		if err != nil {
+			return nil, nil, fmt.Errorf("create stdout exporter error: %w", err)
+		}
+		return exp, nil, nil
+	case ExporterFile:
+		file, err := os.OpenFile(cfg.FilePath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600) //nolint:gomnd // <- file mode
+		if err != nil {
+			return nil, nil, fmt.Errorf("open traces file error: %w", err)
+		}
+		exp, err := stdouttrace.New(stdouttrace.WithWriter(file))
+		if err != nil {
+			return nil, nil, errors.Join(fmt.Errorf("create file exporter error: %w", err), file.Close())
+		}
+		return exp, file, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown trace exporter: '%s'", cfg.Exporter)
+	}
+}
+
+// NewProvider sets global tracer provider and W3C trace context propagator.
+// Returned function flushes spans and must be called before exit.
+func NewProvider(ctx context.Context, cfg *TracingConfig) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{}, propagation.Baggage{}))
+	if cfg == nil || cfg.Exporter == ExporterNone || cfg.Exporter == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+	exporter, closer, err := newExporter(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL,
+		semconv.ServiceName(cfg.ServiceName)))
+	if err != nil {
+		return nil, fmt.Errorf("create trace resource error: %w", err)
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	return func(ctx context.Context) error {
+		err := provider.Shutdown(ctx)
+		if closer != nil {
+			err = errors.Join(err, closer.Close())
+		}
+		if err != nil {
+			return fmt.Errorf("tracer provider shutdown error: %w", err)
+		}
+		return nil
+	}, nil
+}
+
+// Tracer returns the service tracer from global provider.
+func Tracer() trace.Tracer {
+	return otel.Tracer(tracerName)
+}